@@ -2,13 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"extendable_storage/internal/config"
 	"extendable_storage/internal/logger"
+	"extendable_storage/internal/repository/bucket"
 	"extendable_storage/internal/repository/file"
+	"extendable_storage/internal/repository/tenant"
+	"extendable_storage/internal/repository/topology"
+	"extendable_storage/internal/repository/upload"
 	"extendable_storage/internal/routes"
+	"extendable_storage/internal/service/auth"
 	"extendable_storage/internal/service/orchestrator"
 	"extendable_storage/internal/service/receiver"
+	"extendable_storage/internal/service/storager"
+	"extendable_storage/internal/storage/cache/disk"
+	"extendable_storage/internal/storage/cache/lru"
 	"extendable_storage/internal/storage/database"
+	"extendable_storage/internal/transport/keeper"
 	"flag"
 	"fmt"
 	"log/slog"
@@ -34,6 +44,9 @@ func main() {
 	if err != nil {
 		appLog.Fatal("unable to init config", err, slog.String("config", *confFile))
 	}
+	if appConf.NodeCalls.Secret == "" {
+		appLog.Fatal("storage node secret is required", errors.New("node_calls.secret is not set"), slog.String("config", *confFile))
+	}
 
 	appLog.Info("create storage connections")
 	dbConn, err := getDBConnect(appLog, &appConf.ConfigDB, appConf.MigratesFolder)
@@ -48,13 +61,79 @@ func main() {
 
 	appLog.Info("init repositories")
 	repoFile := file.InitRepo(dbConn)
+	repoTopology := topology.InitRepo(dbConn)
+	repoBucket := bucket.InitRepo(dbConn)
+	repoUpload := upload.InitRepo(dbConn)
+	repoTenant := tenant.InitRepo(dbConn)
 
 	appLog.Info("init services")
-	serviceDataOrchestrator := orchestrator.NewService(ctx, appLog)
-	serviceReceiver := receiver.NewService(ctx, appLog, serviceDataOrchestrator, repoFile)
+	serviceDataOrchestrator, err := orchestrator.NewService(ctx, appLog, &orchestrator.Config{
+		ReplicationFactor: appConf.Replication.Factor,
+		WriteQuorum:       appConf.Replication.WriteQuorum,
+		Sectors:           appConf.Ring.Sectors,
+		VNodeCapacityMB:   appConf.Ring.VNodeCapacityMB,
+		Calls: orchestrator.CallPolicy{
+			ReadTimeout:     appConf.NodeCalls.ReadTimeout,
+			WriteTimeout:    appConf.NodeCalls.WriteTimeout,
+			TransferTimeout: appConf.NodeCalls.TransferTimeout,
+			MaxAttempts:     appConf.NodeCalls.MaxAttempts,
+			InitialBackoff:  appConf.NodeCalls.InitialBackoff,
+			MaxBackoff:      appConf.NodeCalls.MaxBackoff,
+		},
+	}, repoTopology, keeper.Connector(appConf.NodeCalls.Secret))
+	if err != nil {
+		appLog.Fatal("unable to init orchestrator", err)
+	}
+	for _, node := range appConf.StorageNodes {
+		err = serviceDataOrchestrator.AddDataKeeper(ctx, node.NodeID, keeper.NewClient(node.Address, appConf.NodeCalls.Secret))
+		if errors.Is(err, orchestrator.ErrDataKeeperExists) {
+			appLog.Info("storage node restored from topology", slog.String("node_id", node.NodeID))
+			continue
+		}
+		if errors.Is(err, orchestrator.ErrLayoutMigration) {
+			appLog.Error("storage node can join after circle layout migration, restart required", err, slog.String("node_id", node.NodeID))
+			continue
+		}
+		if err != nil {
+			appLog.Fatal("unable to add storage node", err, slog.String("node_id", node.NodeID))
+		}
+	}
+	if serviceDataOrchestrator.LayoutMigrationRequired() {
+		go func() {
+			if errM := serviceDataOrchestrator.MigrateLayout(ctx, storager.ChunkCatalogs{repoFile, repoUpload}); errM != nil {
+				appLog.Error("circle layout migration failed, restart to continue", errM)
+			}
+		}()
+	}
+	var dataRouter orchestrator.DataRouter = serviceDataOrchestrator
+	if appConf.ChunkCache.SizeMB > 0 {
+		var cacheOpts []lru.ShardedOption
+		if appConf.ChunkCache.TinyLFUItems > 0 {
+			cacheOpts = append(cacheOpts, lru.WithTinyLFU(appConf.ChunkCache.TinyLFUItems))
+		}
+		chunkCache := lru.CreateShardedCache(appConf.ChunkCache.SizeMB, appConf.ChunkCache.TTL, cacheOpts...)
+		var diskCache *disk.Cache
+		if appConf.ChunkCache.DiskDir != "" && appConf.ChunkCache.DiskSizeMB > 0 {
+			var errC error
+			if diskCache, errC = disk.CreateL2Cache(appConf.ChunkCache.DiskDir, appConf.ChunkCache.DiskSizeMB); errC != nil {
+				appLog.Fatal("unable to init disk chunk cache", errC, slog.String("dir", appConf.ChunkCache.DiskDir))
+			}
+			evicted := chunkCache.EvictListener()
+			go diskCache.Drain(ctx, evicted, func(key string, errD error) {
+				appLog.Error("unable to store chunk in disk cache", errD, slog.String("key", key))
+			})
+		}
+		dataRouter = orchestrator.NewCachingRouter(serviceDataOrchestrator, chunkCache, diskCache)
+	}
+	serviceReceiver := receiver.NewService(ctx, appLog, dataRouter, repoFile, repoBucket, repoUpload)
+
+	var authenticator auth.Authenticator
+	if appConf.Auth.Enabled {
+		authenticator = auth.NewService(repoTenant, appConf.Auth.JWTSecret)
+	}
 
 	appLog.Info("init http service")
-	appHTTPServer := routes.InitAppRouter(appLog, serviceReceiver, fmt.Sprintf(":%d", appConf.AppPort))
+	appHTTPServer := routes.InitAppRouter(appLog, serviceReceiver, dataRouter, authenticator, fmt.Sprintf(":%d", appConf.AppPort))
 	defer func() {
 		if err = appHTTPServer.Stop(); err != nil {
 			appLog.Fatal("unable to stop http service", err)
@@ -66,12 +145,31 @@ func main() {
 		}
 	}()
 
+	if appConf.S3.Port != 0 {
+		s3Conf := routes.S3Config{Region: appConf.S3.Region, Keys: make(map[string]string, len(appConf.S3.Keys))}
+		for _, key := range appConf.S3.Keys {
+			s3Conf.Keys[key.AccessKey] = key.SecretKey
+		}
+		appS3Server := routes.InitS3Router(appLog, serviceReceiver, s3Conf, fmt.Sprintf(":%d", appConf.S3.Port))
+		defer func() {
+			if err = appS3Server.Stop(); err != nil {
+				appLog.Fatal("unable to stop s3 service", err)
+			}
+		}()
+		go func() {
+			if err = appS3Server.Run(); err != nil {
+				appLog.Fatal("unable to start s3 service", err)
+			}
+		}()
+	}
+
 	// register app shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c // This blocks the main thread until an interrupt is received
 	cancel()
 	serviceReceiver.Stop()
+	serviceDataOrchestrator.Stop()
 }
 
 func getDBConnect(log logger.AppLogger, cnf *config.DBConf, migratesFolder string) (*database.DBConnect, error) {
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/config"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/repository/file"
+	"extendable_storage/internal/repository/upload"
+	"extendable_storage/internal/service/storager"
+	"extendable_storage/internal/storage/database"
+	"extendable_storage/internal/transport/keeper"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var (
+	confFile = flag.String("config", "configs/storage_node_conf.yml", "Configs file path")
+	appHash  = os.Getenv("GIT_HASH")
+)
+
+func main() {
+	flag.Parse()
+	appLog := logger.NewAppSLogger(appHash)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	appLog.Info("storage node starting", slog.String("conf", *confFile))
+	appConf, err := config.InitStorageNodeConf(*confFile)
+	if err != nil {
+		appLog.Fatal("unable to init config", err, slog.String("config", *confFile))
+	}
+	if appConf.Secret == "" {
+		appLog.Fatal("node secret is required", errors.New("secret is not set"), slog.String("config", *confFile))
+	}
+
+	appLog.Info("init services")
+	serviceStorage := storager.NewService(ctx, &storager.Config{
+		MaxLimitMB: appConf.MaxLimitMB,
+		NodeID:     appConf.NodeID,
+		DataDir:    appConf.DataDir,
+		Sectors:    appConf.Sectors,
+		Scrub: storager.ScrubConfig{
+			Interval:       appConf.Scrub.Interval,
+			BytesPerSecond: appConf.Scrub.BytesPerSecond,
+		},
+	}, appLog)
+	if appConf.Scrub.Interval > 0 {
+		appLog.Info("connect to db for scrubber")
+		dbConn, errC := database.InitDBConnect(&appConf.ConfigDB, "")
+		if errC != nil {
+			appLog.Fatal("unable to connect to db", errC, slog.String("host", appConf.ConfigDB.Address))
+		}
+		defer func() {
+			if err = dbConn.Close(); err != nil {
+				appLog.Fatal("unable to close db connection", err)
+			}
+		}()
+		go serviceStorage.RunScrubber(ctx, storager.ChunkCatalogs{file.InitRepo(dbConn), upload.InitRepo(dbConn)}, nil)
+	}
+
+	appLog.Info("init http service")
+	appHTTPServer := keeper.NewServer(appLog, serviceStorage, fmt.Sprintf(":%d", appConf.AppPort), appConf.Secret)
+	defer func() {
+		if err = appHTTPServer.Stop(); err != nil {
+			appLog.Fatal("unable to stop http service", err)
+		}
+	}()
+	go func() {
+		if err = appHTTPServer.Run(); err != nil {
+			appLog.Fatal("unable to start http service", err)
+		}
+	}()
+
+	// register app shutdown
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c // This blocks the main thread until an interrupt is received
+	cancel()
+}
@@ -5,15 +5,106 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 type AppConfig struct {
-	AppPort        int       `yaml:"app_port"`
-	MigratesFolder string    `yaml:"migrates_folder"`
-	ConfigDB       DBConf    `yaml:"conf_db"`
-	ConfigGraph    GraphConf `yaml:"conf_graph"`
+	AppPort        int               `yaml:"app_port"`
+	MigratesFolder string            `yaml:"migrates_folder"`
+	ConfigDB       DBConf            `yaml:"conf_db"`
+	ConfigGraph    GraphConf         `yaml:"conf_graph"`
+	StorageNodes   []StorageNodeConf `yaml:"storage_nodes"`
+	Replication    ReplicationConf   `yaml:"replication"`
+	Ring           RingConf          `yaml:"ring"`
+	NodeCalls      NodeCallsConf     `yaml:"node_calls"`
+	S3             S3Conf            `yaml:"s3"`
+	ChunkCache     ChunkCacheConf    `yaml:"chunk_cache"`
+	Auth           AuthConf          `yaml:"auth"`
+}
+
+// AuthConf enables authentication of REST API by API keys of tenants and JWT signed with jwt_secret (HS256).
+// Empty jwt_secret disables tokens.
+type AuthConf struct {
+	Enabled   bool   `yaml:"enabled"`
+	JWTSecret string `yaml:"jwt_secret"`
+}
+
+// ChunkCacheConf is a size of in-memory cache of read chunks, zero disables it. Cached chunk expires after ttl
+// if it is set. Non-zero tinylfu_items enables admission filter sized for this number of chunks.
+// Chunks evicted from memory are kept in disk_dir up to disk_size_mb if both are set.
+type ChunkCacheConf struct {
+	SizeMB       int64         `yaml:"size_mb"`
+	TTL          time.Duration `yaml:"ttl"`
+	TinyLFUItems int           `yaml:"tinylfu_items"`
+	DiskDir      string        `yaml:"disk_dir"`
+	DiskSizeMB   int64         `yaml:"disk_size_mb"`
+}
+
+// S3Conf enables S3 compatible API on port, requests are signed with one of keys for region (any if empty)
+type S3Conf struct {
+	Port   int         `yaml:"port"`
+	Region string      `yaml:"region"`
+	Keys   []S3KeyConf `yaml:"keys"`
+}
+
+type S3KeyConf struct {
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+}
+
+// ReplicationConf is a number of chunk copies in the cluster, write quorum is majority of factor if empty
+type ReplicationConf struct {
+	Factor      int `yaml:"factor"`
+	WriteQuorum int `yaml:"write_quorum"`
+}
+
+// RingConf is a layout of consistent hashing circle. Zero sectors means default 360,
+// zero vnode capacity means one position per node
+type RingConf struct {
+	Sectors         uint32 `yaml:"sectors"`
+	VNodeCapacityMB int    `yaml:"vnode_capacity_mb"`
+}
+
+// NodeCallsConf is a deadline and retry policy of calls to storage nodes, durations are like "10s".
+// Empty values mean defaults of orchestrator.
+type NodeCallsConf struct {
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	TransferTimeout time.Duration `yaml:"transfer_timeout"`
+	MaxAttempts     int           `yaml:"max_attempts"`
+	InitialBackoff  time.Duration `yaml:"initial_backoff"`
+	MaxBackoff      time.Duration `yaml:"max_backoff"`
+	// Secret is shared with storage nodes, they reject calls without it
+	Secret string `yaml:"secret"`
+}
+
+// StorageNodeConf is address of remote storage node which joins the cluster on gateway start
+type StorageNodeConf struct {
+	NodeID  string `yaml:"node_id"`
+	Address string `yaml:"address"`
+}
+
+// StorageNodeAppConfig is config of standalone storage node
+type StorageNodeAppConfig struct {
+	AppPort    int    `yaml:"app_port"`
+	NodeID     string `yaml:"node_id"`
+	DataDir    string `yaml:"data_dir"`
+	MaxLimitMB int    `yaml:"max_limit_mb"`
+	Sectors    uint32 `yaml:"sectors"`
+	// Secret is shared with gateway and other nodes, calls without it are rejected
+	Secret string `yaml:"secret"`
+	// ConfigDB is database of the gateway, scrubber reads recorded chunks from it
+	ConfigDB DBConf    `yaml:"conf_db"`
+	Scrub    ScrubConf `yaml:"scrub"`
+}
+
+// ScrubConf is a background check of stored files, zero interval disables it.
+// Zero bytes_per_second means default of storage node.
+type ScrubConf struct {
+	Interval       time.Duration `yaml:"interval"`
+	BytesPerSecond int64         `yaml:"bytes_per_second"`
 }
 
 type GraphConf struct {
@@ -35,9 +126,25 @@ type DBConf struct {
 }
 
 func InitConf(confFile string) (*AppConfig, error) {
+	var cfg AppConfig
+	if err := decodeConf(confFile, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func InitStorageNodeConf(confFile string) (*StorageNodeAppConfig, error) {
+	var cfg StorageNodeAppConfig
+	if err := decodeConf(confFile, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func decodeConf(confFile string, cfg any) error {
 	file, err := os.Open(filepath.Clean(confFile))
 	if err != nil {
-		return nil, fmt.Errorf("error open config file: %w", err)
+		return fmt.Errorf("error open config file: %w", err)
 	}
 	defer func() {
 		if e := file.Close(); e != nil {
@@ -45,10 +152,8 @@ func InitConf(confFile string) (*AppConfig, error) {
 		}
 	}()
 
-	var cfg AppConfig
-	if err = yaml.NewDecoder(file).Decode(&cfg); err != nil {
-		return nil, fmt.Errorf("error decode config file: %w", err)
+	if err = yaml.NewDecoder(file).Decode(cfg); err != nil {
+		return fmt.Errorf("error decode config file: %w", err)
 	}
-
-	return &cfg, nil
+	return nil
 }
@@ -0,0 +1,14 @@
+package entities
+
+import "time"
+
+// Bucket is a namespace of S3 API objects, object is stored as file with id <bucket>/<key>
+type Bucket struct {
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ObjectFileID returns id of the file which keeps object of the bucket
+func ObjectFileID(bucket, key string) string {
+	return bucket + "/" + key
+}
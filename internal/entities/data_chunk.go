@@ -3,12 +3,25 @@ package entities
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"extendable_storage/internal/utils"
 	"fmt"
 	"hash/crc32"
+	"strings"
 )
 
 const (
+	// CircleSectors is default number of sectors on the circle, data stored before sectors became configurable uses it
 	CircleSectors = 360
+	// MaxChunkSize is a size limit of the chunk, bigger files are split into more chunks
+	MaxChunkSize = 64 * 1024 * 1024
+)
+
+// ChunkRole is a role of the chunk in erasure coded file. Chunks of plain files have no role
+type ChunkRole string
+
+const (
+	ChunkRoleData   ChunkRole = "data"
+	ChunkRoleParity ChunkRole = "parity"
 )
 
 type FileChunk struct {
@@ -16,6 +29,14 @@ type FileChunk struct {
 	FileID string `json:"file_id"`
 	// ChunkID hash of the chunk
 	ChunkID string `json:"chunk_id"`
+	// Offset of the chunk in the file
+	Offset int64 `json:"offset,omitempty"`
+	// Size of the chunk in bytes. Data shard of erasure coded file is stored padded, Size is a size of file data in it
+	Size int64 `json:"size,omitempty"`
+	// Role of the shard in erasure coded file
+	Role ChunkRole `json:"role,omitempty"`
+	// Index of the shard in erasure coded file, data shards go first
+	Index int `json:"index,omitempty"`
 }
 
 func (f *FileChunk) String() string {
@@ -26,6 +47,18 @@ func (f *FileChunk) Hash() uint32 {
 	return crc32.ChecksumIEEE([]byte(f.String()))
 }
 
+// Verify checks that data is the content of the chunk. ChunkID is SHA-256 of the content,
+// shard of erasure coded file has its index appended to it
+func (f *FileChunk) Verify(data []byte) bool {
+	hash, _, _ := strings.Cut(f.ChunkID, "_")
+	return hash == utils.HashData(data)
+}
+
+// Sector returns sector of the chunk on the circle with given number of sectors
+func (f *FileChunk) Sector(sectors uint32) uint32 {
+	return f.Hash() % sectors
+}
+
 func (f *FileChunk) Value() (driver.Value, error) {
 	// Marshal the FileChunk struct to a JSON string.
 	value, err := json.Marshal(f)
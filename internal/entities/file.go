@@ -17,4 +17,57 @@ type File struct {
 	UpdatedAt  time.Time    `json:"updated_at" db:"updated_at"`
 	Chunks     []*FileChunk `json:"chunks" db:"-"`
 	ChunksJSON []byte       `json:"-" db:"chunks"`
+	// DataShards and ParityShards describe erasure coding scheme, both are zero for plain files
+	DataShards   int `json:"data_shards,omitempty" db:"data_shards"`
+	ParityShards int `json:"parity_shards,omitempty" db:"parity_shards"`
+	// Length is size of file content, zero for files stored before it was tracked, see Size
+	Length      int64  `json:"size,omitempty" db:"size"`
+	ContentType string `json:"content_type,omitempty" db:"content_type"`
+	// SHA256 and MD5 are hex digests of the whole file content, empty until upload is completed
+	// and for files stored before digests were tracked
+	SHA256 string `json:"sha256,omitempty" db:"sha256"`
+	MD5    string `json:"md5,omitempty" db:"md5"`
+}
+
+// ETag returns quoted SHA-256 of the file content, empty if it is unknown
+func (f *File) ETag() string {
+	if f.SHA256 == "" {
+		return ""
+	}
+	return `"` + f.SHA256 + `"`
+}
+
+// IsErasureCoded checks that file is stored as data and parity shards
+func (f *File) IsErasureCoded() bool {
+	return f.ParityShards > 0
+}
+
+// DataChunks returns chunks which keep file data in order, parity shards are skipped
+func (f *File) DataChunks() []*FileChunk {
+	if !f.IsErasureCoded() {
+		return f.Chunks
+	}
+	result := make([]*FileChunk, 0, f.DataShards)
+	for _, chunk := range f.Chunks {
+		if chunk.Role == ChunkRoleData {
+			result = append(result, chunk)
+		}
+	}
+	return result
+}
+
+// Size returns stored file size or calculates it from chunks for files stored before it was tracked. Returns -1 if chunk sizes are unknown (file stored before sizes were tracked)
+func (f *File) Size() int64 {
+	if f.Length > 0 {
+		return f.Length
+	}
+	size := int64(0)
+	for _, chunk := range f.DataChunks() {
+		// data shards of small erasure coded file can be empty
+		if chunk.Size == 0 && !f.IsErasureCoded() {
+			return -1
+		}
+		size += chunk.Size
+	}
+	return size
 }
@@ -0,0 +1,94 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// JobKind is a topology change which requires moving sectors between nodes
+type JobKind string
+
+const (
+	// JobKindJoin node is added to the circle and loads sectors it owns now
+	JobKindJoin JobKind = "join"
+	// JobKindDrain node is removed from the circle, its sectors are loaded by their new owners
+	JobKindDrain JobKind = "drain"
+)
+
+// SectorState is a step of sector move, steps go in order of declaration
+type SectorState string
+
+const (
+	SectorStatePending SectorState = "pending"
+	SectorStateCopying SectorState = "copying"
+	// SectorStateVerified every target keeps at least as much sector data as source had before copy
+	SectorStateVerified SectorState = "verified"
+	// SectorStateSwitched requests are routed to new owners of the sector
+	SectorStateSwitched SectorState = "switched"
+	// SectorStateSourceDropped stale copies of the sector are dropped, sector move is finished
+	SectorStateSourceDropped SectorState = "source_dropped"
+)
+
+// RebalanceJob is a background move of sectors caused by node joining or leaving the circle.
+// Job is identified by the node, the last job of every node is kept.
+type RebalanceJob struct {
+	ServerID string  `json:"server_id" db:"server_id"`
+	Kind     JobKind `json:"kind" db:"kind"`
+	// Address of remote node, drained node is not in topology anymore when its copies are dropped
+	Address   string    `json:"address" db:"address"`
+	Done      bool      `json:"done" db:"done"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SectorMove is a move of one sector in rebalance job: Targets load sector from Source, then Drops remove their copies
+type SectorMove struct {
+	// JobServerID is a node of the job
+	JobServerID string      `json:"job_server_id" db:"server_id"`
+	Sector      uint32      `json:"sector" db:"sector"`
+	SourceID    string      `json:"source_id" db:"source_id"`
+	Targets     ServerIDs   `json:"targets" db:"targets"`
+	Drops       ServerIDs   `json:"drops" db:"drops"`
+	State       SectorState `json:"state" db:"state"`
+	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// ServerIDs is a list of node ids stored as JSON
+type ServerIDs []string
+
+func (s ServerIDs) Value() (driver.Value, error) {
+	if s == nil {
+		s = ServerIDs{}
+	}
+	return json.Marshal([]string(s))
+}
+
+func (s *ServerIDs) Scan(src interface{}) error {
+	if src == nil {
+		*s = nil
+		return nil
+	}
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, s)
+	case string:
+		return json.Unmarshal([]byte(src), s)
+	default:
+		return fmt.Errorf("invalid data type for ServerIDs: %T", src)
+	}
+}
+
+// Equal checks that lists have the same ids in the same order
+func (s ServerIDs) Equal(other ServerIDs) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for i := range s {
+		if s[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package entities
+
+import "time"
+
+type NodeState string
+
+const (
+	NodeStateNotReady NodeState = "not_ready"
+	NodeStateReady    NodeState = "ready"
+	// NodeStateDraining node still serves data while its sectors are moved to other nodes before removal
+	NodeStateDraining NodeState = "draining"
+)
+
+// RingNode is position of storage node on the consistent hashing circle
+type RingNode struct {
+	Position uint32    `json:"position" db:"position"`
+	ServerID string    `json:"server_id" db:"server_id"`
+	State    NodeState `json:"state" db:"state"`
+	// Address of remote storage node, empty for nodes living in gateway process
+	Address string `json:"address" db:"address"`
+	// Sectors is number of sectors on the circle position belongs to
+	Sectors   uint32    `json:"sectors" db:"sectors"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
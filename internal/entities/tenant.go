@@ -0,0 +1,64 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// Scope is an access level of API key
+type Scope string
+
+const (
+	// ScopeRead allows to read files of the namespace
+	ScopeRead Scope = "read"
+	// ScopeReadWrite allows to read and write files of the namespace
+	ScopeReadWrite Scope = "read_write"
+	// ScopeAdmin allows to access files of any namespace and admin API
+	ScopeAdmin Scope = "admin"
+)
+
+const (
+	namespaceSeparator = ":"
+)
+
+// Tenant owns files with ids "<namespace>:<name>"
+type Tenant struct {
+	Namespace string    `json:"namespace" db:"namespace"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// APIKey authenticates requests of the tenant as "<id>.<secret>", only SHA-256 of the secret is stored
+type APIKey struct {
+	ID         string     `json:"id" db:"id"`
+	Namespace  string     `json:"namespace" db:"namespace"`
+	SecretHash string     `json:"-" db:"secret_hash"`
+	Scope      Scope      `json:"scope" db:"scope"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Principal is an authenticated caller: namespace of its files and access level
+type Principal struct {
+	Namespace string
+	Scope     Scope
+}
+
+func (s Scope) Valid() bool {
+	return s == ScopeRead || s == ScopeReadWrite || s == ScopeAdmin
+}
+
+// CanAccess reports whether principal can read (or write) the file
+func (p *Principal) CanAccess(fileID string, write bool) bool {
+	if p.Scope == ScopeAdmin {
+		return true
+	}
+	if write && p.Scope != ScopeReadWrite {
+		return false
+	}
+	return p.Scope.Valid() && strings.HasPrefix(fileID, p.Namespace+namespaceSeparator)
+}
+
+// NamespaceFileID returns id of the file name in namespace
+func NamespaceFileID(namespace, name string) string {
+	return namespace + namespaceSeparator + name
+}
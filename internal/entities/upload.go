@@ -0,0 +1,30 @@
+package entities
+
+import "time"
+
+// Upload is a multipart upload of the file. Status is new while parts are uploaded, complete when file is created
+// from parts and purge when upload is aborted or abandoned.
+type Upload struct {
+	ID          string     `json:"id" db:"id"`
+	FileID      string     `json:"file_id" db:"file_id"`
+	Status      FileStatus `json:"status" db:"status"`
+	ContentType string     `json:"content_type,omitempty" db:"content_type"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// UploadPart is an uploaded part of multipart upload. Status is new while part is uploaded, complete when all its
+// chunks are saved and purge when it is replaced by another copy of the part or upload is aborted.
+type UploadPart struct {
+	ID         string       `json:"-" db:"id"`
+	UploadID   string       `json:"-" db:"upload_id"`
+	Number     int          `json:"number" db:"part_number"`
+	Status     FileStatus   `json:"-" db:"status"`
+	Size       int64        `json:"size" db:"size"`
+	SHA256     string       `json:"sha256" db:"sha256"`
+	MD5        string       `json:"md5" db:"md5"`
+	Chunks     []*FileChunk `json:"-" db:"-"`
+	ChunksJSON []byte       `json:"-" db:"chunks"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at" db:"updated_at"`
+}
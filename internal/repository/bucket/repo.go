@@ -0,0 +1,52 @@
+package bucket
+
+import (
+	"context"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/storage/database"
+)
+
+type Repo struct {
+	db database.DBConnector
+}
+
+func InitRepo(db database.DBConnector) *Repo {
+	return &Repo{db: db}
+}
+
+// CreateBucket inserts bucket, reports false if bucket already exists
+func (r *Repo) CreateBucket(ctx context.Context, name string) (bool, error) {
+	res, err := r.db.Client().ExecContext(ctx, `
+		INSERT INTO buckets (name, created_at) VALUES ($1, NOW())
+		ON CONFLICT (name) DO NOTHING`, name)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
+
+// GetBucket returns bucket by name, sql.ErrNoRows if it doesn't exist
+func (r *Repo) GetBucket(ctx context.Context, name string) (*entities.Bucket, error) {
+	var bucket entities.Bucket
+	if err := r.db.Client().GetContext(ctx, &bucket, `SELECT * FROM buckets WHERE name = $1`, name); err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+func (r *Repo) ListBuckets(ctx context.Context) ([]*entities.Bucket, error) {
+	var buckets []*entities.Bucket
+	if err := r.db.Client().SelectContext(ctx, &buckets, `SELECT * FROM buckets ORDER BY name`); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+func (r *Repo) DeleteBucket(ctx context.Context, name string) error {
+	_, err := r.db.Client().ExecContext(ctx, `DELETE FROM buckets WHERE name = $1`, name)
+	return err
+}
@@ -0,0 +1,47 @@
+package bucket_test
+
+import (
+	"database/sql"
+	testhelpers "extendable_storage/internal/test_helpers"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_BucketsCRUD(t *testing.T) {
+	// given
+	container := testhelpers.GetClean(t)
+
+	// when
+	created, err := container.RepoBucket.CreateBucket(container.Ctx, "photos")
+	require.NoError(t, err)
+	require.True(t, created)
+	created, err = container.RepoBucket.CreateBucket(container.Ctx, "docs")
+	require.NoError(t, err)
+	require.True(t, created)
+
+	// then
+	bucket, err := container.RepoBucket.GetBucket(container.Ctx, "photos")
+	require.NoError(t, err)
+	require.Equal(t, "photos", bucket.Name)
+	require.False(t, bucket.CreatedAt.IsZero())
+	buckets, err := container.RepoBucket.ListBuckets(container.Ctx)
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+	require.Equal(t, "docs", buckets[0].Name)
+
+	t.Run("should not create existing bucket", func(t *testing.T) {
+		created, err = container.RepoBucket.CreateBucket(container.Ctx, "photos")
+		require.NoError(t, err)
+		require.False(t, created)
+	})
+
+	t.Run("should delete bucket", func(t *testing.T) {
+		// when
+		require.NoError(t, container.RepoBucket.DeleteBucket(container.Ctx, "photos"))
+
+		// then
+		_, err = container.RepoBucket.GetBucket(container.Ctx, "photos")
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
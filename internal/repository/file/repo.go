@@ -2,12 +2,19 @@ package file
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"extendable_storage/internal/entities"
 	"extendable_storage/internal/storage/database"
+	"fmt"
 	"time"
 )
 
+const (
+	iterateBatchSize = 100
+)
+
 type Repo struct {
 	db database.DBConnector
 }
@@ -17,22 +24,105 @@ func InitRepo(db database.DBConnector) *Repo {
 }
 
 func (r *Repo) SaveFileChunks(ctx context.Context, fileID string, chunks []*entities.FileChunk) error {
+	created, err := r.CreateFile(ctx, &entities.File{ID: fileID, Chunks: chunks})
+	if err == nil && !created {
+		return fmt.Errorf("file %s already exists", fileID)
+	}
+	return err
+}
+
+// CreateFile inserts new file with its chunks, erasure coding scheme, size and content type.
+// Reports false if file with the same id exists.
+func (r *Repo) CreateFile(ctx context.Context, file *entities.File) (bool, error) {
+	chunksJSON, err := json.Marshal(file.Chunks)
+	if err != nil {
+		return false, err
+	}
+	toSave := entities.File{
+		ID:           file.ID,
+		Status:       entities.FileStatusNew,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		Chunks:       file.Chunks,
+		ChunksJSON:   chunksJSON,
+		DataShards:   file.DataShards,
+		ParityShards: file.ParityShards,
+		Length:       file.Length,
+		ContentType:  file.ContentType,
+	}
+	res, err := r.db.Client().ExecContext(ctx, `
+		INSERT INTO files (id, status, created_at, updated_at, chunks, data_shards, parity_shards, size, content_type)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO NOTHING`,
+		toSave.ID, toSave.Status, toSave.CreatedAt, toSave.UpdatedAt, toSave.ChunksJSON, toSave.DataShards, toSave.ParityShards,
+		toSave.Length, toSave.ContentType)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
+
+// CompleteFile marks file as complete and stores digests of its content
+func (r *Repo) CompleteFile(ctx context.Context, fileID, sha256Sum, md5Sum string) error {
+	_, err := r.db.Client().ExecContext(ctx, `UPDATE files SET status = $1, sha256 = $2, md5 = $3, updated_at = NOW() WHERE id = $4`,
+		entities.FileStatusComplete, sha256Sum, md5Sum, fileID)
+	return err
+}
+
+// ReplaceFile completes file saved under tmpID and gives it fileID in one transaction. Complete file which had
+// fileID gets retiredID and purge status, it is returned to purge its chunks, nil if fileID was free.
+// Reports false if fileID is taken by unfinished file.
+func (r *Repo) ReplaceFile(ctx context.Context, fileID, tmpID, retiredID, sha256Sum, md5Sum string) (*entities.File, bool, error) {
+	tx, err := r.db.Client().BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("error begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	var replaced *entities.File
+	var existing entities.File
+	err = tx.QueryRowxContext(ctx, `SELECT * FROM files WHERE id = $1 FOR UPDATE`, fileID).StructScan(&existing)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, false, fmt.Errorf("error get replaced file: %w", err)
+	case existing.Status != entities.FileStatusComplete:
+		return nil, false, nil
+	default:
+		if err = json.Unmarshal(existing.ChunksJSON, &existing.Chunks); err != nil {
+			return nil, false, err
+		}
+		if _, err = tx.ExecContext(ctx, `UPDATE files SET id = $1, status = $2, updated_at = NOW() WHERE id = $3`,
+			retiredID, entities.FileStatusPurge, fileID); err != nil {
+			return nil, false, fmt.Errorf("error retire replaced file: %w", err)
+		}
+		existing.ID = retiredID
+		existing.Status = entities.FileStatusPurge
+		replaced = &existing
+	}
+	res, err := tx.ExecContext(ctx, `
+		UPDATE files SET id = $1, status = $2, sha256 = $3, md5 = $4, updated_at = NOW() WHERE id = $5 AND status = $6`,
+		fileID, entities.FileStatusComplete, sha256Sum, md5Sum, tmpID, entities.FileStatusNew)
+	if err != nil {
+		return nil, false, fmt.Errorf("error complete file: %w", err)
+	}
+	if updated, errR := res.RowsAffected(); errR != nil || updated == 0 {
+		return nil, false, fmt.Errorf("error complete file %s: %w", tmpID, sql.ErrNoRows)
+	}
+	return replaced, true, tx.Commit()
+}
+
+func (r *Repo) UpdateFileChunks(ctx context.Context, fileID string, chunks []*entities.FileChunk) error {
 	chunksJSON, err := json.Marshal(chunks)
 	if err != nil {
 		return err
 	}
-	toSave := entities.File{
-		ID:         fileID,
-		Status:     entities.FileStatusNew,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
-		Chunks:     chunks,
-		ChunksJSON: chunksJSON,
-	}
-	_, err = r.db.Client().ExecContext(ctx, `
-		INSERT INTO files (id, status, created_at, updated_at, chunks)
-		VALUES ($1, $2, $3, $4, $5)`,
-		toSave.ID, toSave.Status, toSave.CreatedAt, toSave.UpdatedAt, toSave.ChunksJSON)
+	_, err = r.db.Client().ExecContext(ctx, `UPDATE files SET chunks = $1, updated_at = NOW() WHERE id = $2`, chunksJSON, fileID)
 	return err
 }
 
@@ -42,15 +132,22 @@ func (r *Repo) SetFileStatus(ctx context.Context, fileID string, status entities
 }
 
 func (r *Repo) GetFileChunks(ctx context.Context, fileID string) ([]*entities.FileChunk, error) {
+	file, err := r.GetFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return file.Chunks, nil
+}
+
+func (r *Repo) GetFile(ctx context.Context, fileID string) (*entities.File, error) {
 	var file entities.File
 	if err := r.db.Client().QueryRowxContext(ctx, `SELECT * FROM files WHERE id = $1`, fileID).StructScan(&file); err != nil {
 		return nil, err
 	}
-	var result []*entities.FileChunk
-	if err := json.Unmarshal(file.ChunksJSON, &result); err != nil {
+	if err := json.Unmarshal(file.ChunksJSON, &file.Chunks); err != nil {
 		return nil, err
 	}
-	return result, nil
+	return &file, nil
 }
 
 func (r *Repo) GetChunksByStatus(ctx context.Context, status entities.FileStatus) ([]*entities.File, error) {
@@ -61,6 +158,36 @@ func (r *Repo) GetChunksUpdatedBeforeDataWithStatus(ctx context.Context, status
 	return r.getFiles(ctx, `SELECT * FROM files WHERE status = $1 AND updated_at < $2`, status, updatedBefore)
 }
 
+// IterateChunks calls fn with chunks of every file, files are loaded in batches ordered by id
+func (r *Repo) IterateChunks(ctx context.Context, fn func(chunks []*entities.FileChunk) error) error {
+	lastID := ""
+	for {
+		files, err := r.getFiles(ctx, `SELECT * FROM files WHERE id > $1 ORDER BY id LIMIT $2`, lastID, iterateBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err = fn(file.Chunks); err != nil {
+				return err
+			}
+			lastID = file.ID
+		}
+		if len(files) < iterateBatchSize {
+			return nil
+		}
+	}
+}
+
+// ListFiles returns up to limit completed files which id starts with prefix and goes after startAfter.
+// Files are ordered by bytes of id, as S3 API lists objects.
+func (r *Repo) ListFiles(ctx context.Context, prefix, startAfter string, limit int) ([]*entities.File, error) {
+	return r.getFiles(ctx, `
+		SELECT * FROM files
+		WHERE status = $1 AND starts_with(id, $2) AND id COLLATE "C" > $3
+		ORDER BY id COLLATE "C" LIMIT $4`,
+		entities.FileStatusComplete, prefix, startAfter, limit)
+}
+
 func (r *Repo) getFiles(ctx context.Context, query string, params ...any) ([]*entities.File, error) {
 	rows, err := r.db.Client().QueryxContext(ctx, query, params...)
 	if err != nil {
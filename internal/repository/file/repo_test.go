@@ -39,18 +39,78 @@ func TestRepo_FileChunksCRUD(t *testing.T) {
 	require.Equal(t, fileID, files[0].ID)
 	require.Equal(t, chunks, files[0].Chunks)
 
+	t.Run("should not create file twice", func(t *testing.T) {
+		created, errC := container.RepoFile.CreateFile(container.Ctx, &entities.File{ID: fileID, Chunks: chunks})
+		require.NoError(t, errC)
+		require.False(t, created)
+	})
+
 	t.Run("should serve by date and status", func(t *testing.T) {
 		files, err = container.RepoFile.GetChunksUpdatedBeforeDataWithStatus(container.Ctx, entities.FileStatusNew, time.Now().Add(1*time.Hour))
 		require.NoError(t, err)
 		require.Len(t, files, 1)
 	})
 
+	t.Run("should complete file with digests", func(t *testing.T) {
+		// when
+		require.NoError(t, container.RepoFile.CompleteFile(container.Ctx, fileID, "sha", "md5"))
+
+		// then
+		file, errG := container.RepoFile.GetFile(container.Ctx, fileID)
+		require.NoError(t, errG)
+		require.Equal(t, entities.FileStatusComplete, file.Status)
+		require.Equal(t, "sha", file.SHA256)
+		require.Equal(t, "md5", file.MD5)
+	})
+
+	t.Run("should replace complete file", func(t *testing.T) {
+		// given
+		tmpID, retiredID := uuid.NewString(), uuid.NewString()
+		newChunks := []*entities.FileChunk{{FileID: tmpID, ChunkID: uuid.NewString()}}
+		require.NoError(t, container.RepoFile.SaveFileChunks(container.Ctx, tmpID, newChunks))
+
+		// when
+		replaced, ok, errR := container.RepoFile.ReplaceFile(container.Ctx, fileID, tmpID, retiredID, "new sha", "new md5")
+
+		// then
+		require.NoError(t, errR)
+		require.True(t, ok)
+		require.Equal(t, retiredID, replaced.ID)
+		require.Equal(t, chunks, replaced.Chunks)
+		file, errG := container.RepoFile.GetFile(container.Ctx, fileID)
+		require.NoError(t, errG)
+		require.Equal(t, entities.FileStatusComplete, file.Status)
+		require.Equal(t, newChunks, file.Chunks)
+		require.Equal(t, "new sha", file.SHA256)
+		files, err = container.RepoFile.GetChunksByStatus(container.Ctx, entities.FileStatusPurge)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, retiredID, files[0].ID)
+		_, errG = container.RepoFile.GetFileChunks(container.Ctx, tmpID)
+		require.Error(t, errG)
+	})
+
+	t.Run("should not replace unfinished file", func(t *testing.T) {
+		// given
+		pendingID, tmpID := uuid.NewString(), uuid.NewString()
+		require.NoError(t, container.RepoFile.SaveFileChunks(container.Ctx, pendingID, chunks))
+		require.NoError(t, container.RepoFile.SaveFileChunks(container.Ctx, tmpID, chunks))
+
+		// when
+		replaced, ok, errR := container.RepoFile.ReplaceFile(container.Ctx, pendingID, tmpID, uuid.NewString(), "sha", "md5")
+
+		// then
+		require.NoError(t, errR)
+		require.False(t, ok)
+		require.Nil(t, replaced)
+	})
+
 	t.Run("should delete file", func(t *testing.T) {
 		// when
 		require.NoError(t, container.RepoFile.DeleteFile(container.Ctx, fileID))
 
 		// then
-		files, err = container.RepoFile.GetChunksByStatus(container.Ctx, entities.FileStatusNew)
+		files, err = container.RepoFile.GetChunksByStatus(container.Ctx, entities.FileStatusComplete)
 		require.NoError(t, err)
 		require.Len(t, files, 0)
 	})
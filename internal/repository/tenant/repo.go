@@ -0,0 +1,71 @@
+package tenant
+
+import (
+	"context"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/storage/database"
+	"time"
+)
+
+type Repo struct {
+	db database.DBConnector
+}
+
+func InitRepo(db database.DBConnector) *Repo {
+	return &Repo{db: db}
+}
+
+// CreateTenant inserts tenant, reports false if namespace is already taken
+func (r *Repo) CreateTenant(ctx context.Context, namespace string) (bool, error) {
+	res, err := r.db.Client().ExecContext(ctx, `
+		INSERT INTO tenants (namespace, created_at) VALUES ($1, NOW())
+		ON CONFLICT (namespace) DO NOTHING`, namespace)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
+
+// GetTenant returns tenant by namespace, sql.ErrNoRows if it doesn't exist
+func (r *Repo) GetTenant(ctx context.Context, namespace string) (*entities.Tenant, error) {
+	var tenant entities.Tenant
+	if err := r.db.Client().GetContext(ctx, &tenant, `SELECT * FROM tenants WHERE namespace = $1`, namespace); err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (r *Repo) CreateKey(ctx context.Context, key *entities.APIKey) error {
+	key.CreatedAt = time.Now()
+	_, err := r.db.Client().ExecContext(ctx, `
+		INSERT INTO api_keys (id, namespace, secret_hash, scope, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		key.ID, key.Namespace, key.SecretHash, key.Scope, key.CreatedAt)
+	return err
+}
+
+// GetKey returns key by id including revoked one, sql.ErrNoRows if it doesn't exist
+func (r *Repo) GetKey(ctx context.Context, id string) (*entities.APIKey, error) {
+	var key entities.APIKey
+	if err := r.db.Client().GetContext(ctx, &key, `SELECT * FROM api_keys WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeKey marks key revoked, reports false if key doesn't exist or is already revoked
+func (r *Repo) RevokeKey(ctx context.Context, id string) (bool, error) {
+	res, err := r.db.Client().ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated > 0, nil
+}
@@ -0,0 +1,51 @@
+package tenant_test
+
+import (
+	"database/sql"
+	"extendable_storage/internal/entities"
+	testhelpers "extendable_storage/internal/test_helpers"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_TenantsCRUD(t *testing.T) {
+	// given
+	container := testhelpers.GetClean(t)
+	created, err := container.RepoTenant.CreateTenant(container.Ctx, "acme")
+	require.NoError(t, err)
+	require.True(t, created)
+	key := &entities.APIKey{ID: "key1", Namespace: "acme", SecretHash: "hash", Scope: entities.ScopeRead}
+
+	// when
+	require.NoError(t, container.RepoTenant.CreateKey(container.Ctx, key))
+	createdAgain, err := container.RepoTenant.CreateTenant(container.Ctx, "acme")
+	require.NoError(t, err)
+
+	// then
+	require.False(t, createdAgain)
+	tenant, err := container.RepoTenant.GetTenant(container.Ctx, "acme")
+	require.NoError(t, err)
+	require.Equal(t, "acme", tenant.Namespace)
+	stored, err := container.RepoTenant.GetKey(container.Ctx, "key1")
+	require.NoError(t, err)
+	require.Equal(t, "hash", stored.SecretHash)
+	require.Equal(t, entities.ScopeRead, stored.Scope)
+	require.Nil(t, stored.RevokedAt)
+
+	t.Run("should revoke key", func(t *testing.T) {
+		revoked, err := container.RepoTenant.RevokeKey(container.Ctx, "key1")
+		require.NoError(t, err)
+		require.True(t, revoked)
+		revoked, err = container.RepoTenant.RevokeKey(container.Ctx, "key1")
+		require.NoError(t, err)
+		require.False(t, revoked)
+		stored, err = container.RepoTenant.GetKey(container.Ctx, "key1")
+		require.NoError(t, err)
+		require.NotNil(t, stored.RevokedAt)
+	})
+	t.Run("should not find missing key", func(t *testing.T) {
+		_, err = container.RepoTenant.GetKey(container.Ctx, "key2")
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
@@ -0,0 +1,98 @@
+package topology
+
+import (
+	"context"
+	"extendable_storage/internal/entities"
+	"fmt"
+	"time"
+)
+
+// CreateJob stores new rebalance job with its sector moves, previous job of the node is replaced
+func (r *Repo) CreateJob(ctx context.Context, job *entities.RebalanceJob, moves []*entities.SectorMove) error {
+	tx, err := r.db.Client().BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err = tx.ExecContext(ctx, `DELETE FROM rebalance_jobs WHERE server_id = $1`, job.ServerID); err != nil {
+		return fmt.Errorf("error delete previous job: %w", err)
+	}
+	job.CreatedAt = time.Now()
+	job.UpdatedAt = job.CreatedAt
+	if _, err = tx.ExecContext(ctx, `
+		INSERT INTO rebalance_jobs (server_id, kind, address, done, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		job.ServerID, job.Kind, job.Address, job.Done, job.CreatedAt, job.UpdatedAt); err != nil {
+		return fmt.Errorf("error save job: %w", err)
+	}
+	for _, move := range moves {
+		move.JobServerID = job.ServerID
+		if _, err = tx.ExecContext(ctx, `
+			INSERT INTO rebalance_sectors (server_id, sector, source_id, targets, drops, state, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())`,
+			move.JobServerID, move.Sector, move.SourceID, move.Targets, move.Drops, move.State); err != nil {
+			return fmt.Errorf("error save sector move: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) GetJob(ctx context.Context, serverID string) (*entities.RebalanceJob, error) {
+	var job entities.RebalanceJob
+	if err := r.db.Client().GetContext(ctx, &job, `SELECT * FROM rebalance_jobs WHERE server_id = $1`, serverID); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJobs returns jobs ordered by creation, only unfinished ones if activeOnly is set
+func (r *Repo) GetJobs(ctx context.Context, activeOnly bool) ([]*entities.RebalanceJob, error) {
+	var jobs []*entities.RebalanceJob
+	err := r.db.Client().SelectContext(ctx, &jobs, `
+		SELECT * FROM rebalance_jobs WHERE NOT (done AND $1) ORDER BY created_at`, activeOnly)
+	return jobs, err
+}
+
+func (r *Repo) FinishJob(ctx context.Context, serverID string) error {
+	_, err := r.db.Client().ExecContext(ctx, `UPDATE rebalance_jobs SET done = TRUE, updated_at = NOW() WHERE server_id = $1`, serverID)
+	return err
+}
+
+// GetSectorMoves returns sector moves of the job ordered by sector
+func (r *Repo) GetSectorMoves(ctx context.Context, serverID string) ([]*entities.SectorMove, error) {
+	var moves []*entities.SectorMove
+	err := r.db.Client().SelectContext(ctx, &moves, `SELECT * FROM rebalance_sectors WHERE server_id = $1 ORDER BY sector`, serverID)
+	return moves, err
+}
+
+// SetSectorsState sets state of job sectors in range [from, to]
+func (r *Repo) SetSectorsState(ctx context.Context, serverID string, from, to uint32, state entities.SectorState) error {
+	_, err := r.db.Client().ExecContext(ctx, `
+		UPDATE rebalance_sectors SET state = $1, updated_at = NOW() WHERE server_id = $2 AND sector BETWEEN $3 AND $4`,
+		state, serverID, from, to)
+	return err
+}
+
+// CountSectorStates returns number of job sectors in every state
+func (r *Repo) CountSectorStates(ctx context.Context, serverID string) (map[entities.SectorState]int, error) {
+	rows, err := r.db.Client().QueryxContext(ctx, `
+		SELECT state, COUNT(*) FROM rebalance_sectors WHERE server_id = $1 GROUP BY state`, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[entities.SectorState]int)
+	for rows.Next() {
+		var (
+			state entities.SectorState
+			count int
+		)
+		if errS := rows.Scan(&state, &count); errS != nil {
+			return nil, errS
+		}
+		result[state] = count
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,73 @@
+package topology
+
+import (
+	"context"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/storage/database"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Repo struct {
+	db database.DBConnector
+}
+
+func InitRepo(db database.DBConnector) *Repo {
+	return &Repo{db: db}
+}
+
+func (r *Repo) SaveNode(ctx context.Context, node *entities.RingNode) error {
+	return saveNode(ctx, r.db.Client(), node)
+}
+
+// ReplaceNodes replaces whole topology in one transaction, used when circle is rebuilt with other number of sectors
+func (r *Repo) ReplaceNodes(ctx context.Context, nodes []*entities.RingNode) error {
+	tx, err := r.db.Client().BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err = tx.ExecContext(ctx, `DELETE FROM ring_nodes`); err != nil {
+		return fmt.Errorf("error delete nodes: %w", err)
+	}
+	for _, node := range nodes {
+		if err = saveNode(ctx, tx, node); err != nil {
+			return fmt.Errorf("error save node: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func saveNode(ctx context.Context, db sqlx.ExecerContext, node *entities.RingNode) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ring_nodes (position, server_id, state, address, sectors, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (position) DO UPDATE SET server_id = $2, state = $3, address = $4, sectors = $5, updated_at = NOW()`,
+		node.Position, node.ServerID, node.State, node.Address, node.Sectors)
+	return err
+}
+
+func (r *Repo) DeleteNode(ctx context.Context, position uint32) error {
+	_, err := r.db.Client().ExecContext(ctx, `DELETE FROM ring_nodes WHERE position = $1`, position)
+	return err
+}
+
+func (r *Repo) GetNodes(ctx context.Context) ([]*entities.RingNode, error) {
+	rows, err := r.db.Client().QueryxContext(ctx, `SELECT * FROM ring_nodes ORDER BY position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []*entities.RingNode
+	for rows.Next() {
+		var node entities.RingNode
+		if errS := rows.StructScan(&node); errS != nil {
+			return nil, errS
+		}
+		result = append(result, &node)
+	}
+	return result, rows.Err()
+}
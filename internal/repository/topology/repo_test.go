@@ -0,0 +1,126 @@
+package topology_test
+
+import (
+	"extendable_storage/internal/entities"
+	testhelpers "extendable_storage/internal/test_helpers"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_NodesCRUD(t *testing.T) {
+	// given
+	container := testhelpers.GetClean(t)
+	node := &entities.RingNode{
+		Position: 179,
+		ServerID: "NODE_A",
+		State:    entities.NodeStateNotReady,
+		Address:  "127.0.0.1:8001",
+		Sectors:  entities.CircleSectors,
+	}
+
+	// when
+	require.NoError(t, container.RepoTopology.SaveNode(container.Ctx, node))
+	node.State = entities.NodeStateReady
+	require.NoError(t, container.RepoTopology.SaveNode(container.Ctx, node))
+
+	// then
+	nodes, err := container.RepoTopology.GetNodes(container.Ctx)
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	require.Equal(t, node.Position, nodes[0].Position)
+	require.Equal(t, node.ServerID, nodes[0].ServerID)
+	require.Equal(t, entities.NodeStateReady, nodes[0].State)
+	require.Equal(t, node.Address, nodes[0].Address)
+	require.Equal(t, node.Sectors, nodes[0].Sectors)
+
+	t.Run("should replace nodes", func(t *testing.T) {
+		// given
+		replacement := []*entities.RingNode{
+			{Position: 1000, ServerID: "NODE_A", State: entities.NodeStateReady, Sectors: 1 << 16},
+			{Position: 5000, ServerID: "NODE_A", State: entities.NodeStateReady, Sectors: 1 << 16},
+		}
+
+		// when
+		require.NoError(t, container.RepoTopology.ReplaceNodes(container.Ctx, replacement))
+
+		// then
+		nodes, err = container.RepoTopology.GetNodes(container.Ctx)
+		require.NoError(t, err)
+		require.Len(t, nodes, 2)
+		require.Equal(t, uint32(1000), nodes[0].Position)
+		require.Equal(t, uint32(1<<16), nodes[1].Sectors)
+	})
+
+	t.Run("should delete node", func(t *testing.T) {
+		// when
+		for _, n := range nodes {
+			require.NoError(t, container.RepoTopology.DeleteNode(container.Ctx, n.Position))
+		}
+
+		// then
+		nodes, err = container.RepoTopology.GetNodes(container.Ctx)
+		require.NoError(t, err)
+		require.Empty(t, nodes)
+	})
+}
+
+func TestRepo_JobsCRUD(t *testing.T) {
+	// given
+	container := testhelpers.GetClean(t)
+	job := &entities.RebalanceJob{ServerID: "NODE_B", Kind: entities.JobKindJoin, Address: "127.0.0.1:8002"}
+	moves := []*entities.SectorMove{
+		{Sector: 10, SourceID: "NODE_A", Targets: entities.ServerIDs{"NODE_B"}, State: entities.SectorStatePending},
+		{Sector: 11, SourceID: "NODE_A", Targets: entities.ServerIDs{"NODE_B"}, Drops: entities.ServerIDs{"NODE_A"}, State: entities.SectorStatePending},
+		{Sector: 12, SourceID: "NODE_A", Targets: entities.ServerIDs{"NODE_B"}, State: entities.SectorStatePending},
+	}
+
+	// when
+	require.NoError(t, container.RepoTopology.CreateJob(container.Ctx, job, moves))
+	require.NoError(t, container.RepoTopology.SetSectorsState(container.Ctx, job.ServerID, 10, 11, entities.SectorStateVerified))
+
+	// then
+	storedMoves, err := container.RepoTopology.GetSectorMoves(container.Ctx, job.ServerID)
+	require.NoError(t, err)
+	require.Len(t, storedMoves, 3)
+	require.Equal(t, entities.SectorStateVerified, storedMoves[1].State)
+	require.Equal(t, entities.ServerIDs{"NODE_A"}, storedMoves[1].Drops)
+	require.Equal(t, entities.SectorStatePending, storedMoves[2].State)
+	states, err := container.RepoTopology.CountSectorStates(container.Ctx, job.ServerID)
+	require.NoError(t, err)
+	require.Equal(t, map[entities.SectorState]int{entities.SectorStateVerified: 2, entities.SectorStatePending: 1}, states)
+	jobs, err := container.RepoTopology.GetJobs(container.Ctx, true)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, job.Address, jobs[0].Address)
+
+	t.Run("should finish job", func(t *testing.T) {
+		// when
+		require.NoError(t, container.RepoTopology.FinishJob(container.Ctx, job.ServerID))
+
+		// then
+		jobs, err = container.RepoTopology.GetJobs(container.Ctx, true)
+		require.NoError(t, err)
+		require.Empty(t, jobs)
+		stored, errG := container.RepoTopology.GetJob(container.Ctx, job.ServerID)
+		require.NoError(t, errG)
+		require.True(t, stored.Done)
+	})
+
+	t.Run("should replace previous job of node", func(t *testing.T) {
+		// given
+		job.Kind = entities.JobKindDrain
+
+		// when
+		require.NoError(t, container.RepoTopology.CreateJob(container.Ctx, job, moves[:1]))
+
+		// then
+		stored, errG := container.RepoTopology.GetJob(container.Ctx, job.ServerID)
+		require.NoError(t, errG)
+		require.False(t, stored.Done)
+		require.Equal(t, entities.JobKindDrain, stored.Kind)
+		storedMoves, err = container.RepoTopology.GetSectorMoves(container.Ctx, job.ServerID)
+		require.NoError(t, err)
+		require.Len(t, storedMoves, 1)
+	})
+}
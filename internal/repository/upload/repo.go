@@ -0,0 +1,250 @@
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/storage/database"
+	"fmt"
+	"time"
+)
+
+const (
+	iterateBatchSize = 100
+)
+
+type Repo struct {
+	db database.DBConnector
+}
+
+func InitRepo(db database.DBConnector) *Repo {
+	return &Repo{db: db}
+}
+
+func (r *Repo) CreateUpload(ctx context.Context, upload *entities.Upload) error {
+	upload.Status = entities.FileStatusNew
+	upload.CreatedAt = time.Now()
+	upload.UpdatedAt = upload.CreatedAt
+	_, err := r.db.Client().ExecContext(ctx, `
+		INSERT INTO uploads (id, file_id, status, content_type, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		upload.ID, upload.FileID, upload.Status, upload.ContentType, upload.CreatedAt, upload.UpdatedAt)
+	return err
+}
+
+// GetUpload returns upload by id, sql.ErrNoRows if it doesn't exist
+func (r *Repo) GetUpload(ctx context.Context, uploadID string) (*entities.Upload, error) {
+	var upload entities.Upload
+	if err := r.db.Client().GetContext(ctx, &upload, `SELECT * FROM uploads WHERE id = $1`, uploadID); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// CreatePart inserts new part of the upload, upload is touched so it isn't considered abandoned
+func (r *Repo) CreatePart(ctx context.Context, part *entities.UploadPart) error {
+	tx, err := r.db.Client().BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	part.Status = entities.FileStatusNew
+	part.CreatedAt = time.Now()
+	part.UpdatedAt = part.CreatedAt
+	if _, err = tx.ExecContext(ctx, `
+		INSERT INTO upload_parts (id, upload_id, part_number, status, size, chunks, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, '[]', $6, $7)`,
+		part.ID, part.UploadID, part.Number, part.Status, part.Size, part.CreatedAt, part.UpdatedAt); err != nil {
+		return fmt.Errorf("error save part: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE uploads SET updated_at = NOW() WHERE id = $1`, part.UploadID); err != nil {
+		return fmt.Errorf("error touch upload: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) UpdatePartChunks(ctx context.Context, partID string, chunks []*entities.FileChunk) error {
+	chunksJSON, err := json.Marshal(chunks)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Client().ExecContext(ctx, `UPDATE upload_parts SET chunks = $1, updated_at = NOW() WHERE id = $2`, chunksJSON, partID)
+	return err
+}
+
+// CompletePart marks part as complete with digests of its content, previous copies of the part are marked for purge.
+// Reports false if part is not uploaded anymore: upload is completed or aborted meanwhile.
+func (r *Repo) CompletePart(ctx context.Context, part *entities.UploadPart) (bool, error) {
+	tx, err := r.db.Client().BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("error begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	res, err := tx.ExecContext(ctx, `
+		UPDATE upload_parts SET status = $1, sha256 = $2, md5 = $3, updated_at = NOW() WHERE id = $4 AND status = $5`,
+		entities.FileStatusComplete, part.SHA256, part.MD5, part.ID, entities.FileStatusNew)
+	if err != nil {
+		return false, fmt.Errorf("error complete part: %w", err)
+	}
+	if updated, errR := res.RowsAffected(); errR != nil || updated == 0 {
+		return false, errR
+	}
+	if _, err = tx.ExecContext(ctx, `
+		UPDATE upload_parts SET status = $1, updated_at = NOW()
+		WHERE upload_id = $2 AND part_number = $3 AND status = $4 AND id <> $5`,
+		entities.FileStatusPurge, part.UploadID, part.Number, entities.FileStatusComplete, part.ID); err != nil {
+		return false, fmt.Errorf("error replace part: %w", err)
+	}
+	part.Status = entities.FileStatusComplete
+	return true, tx.Commit()
+}
+
+func (r *Repo) SetPartStatus(ctx context.Context, partID string, status entities.FileStatus) error {
+	_, err := r.db.Client().ExecContext(ctx, `UPDATE upload_parts SET status = $1, updated_at = NOW() WHERE id = $2`, status, partID)
+	return err
+}
+
+// GetParts returns completed parts of the upload ordered by number
+func (r *Repo) GetParts(ctx context.Context, uploadID string) ([]*entities.UploadPart, error) {
+	return r.getParts(ctx, `SELECT * FROM upload_parts WHERE upload_id = $1 AND status = $2 ORDER BY part_number`,
+		uploadID, entities.FileStatusComplete)
+}
+
+// GetPartsToPurge returns parts marked for purge and parts which upload is stuck since updatedBefore
+func (r *Repo) GetPartsToPurge(ctx context.Context, updatedBefore time.Time) ([]*entities.UploadPart, error) {
+	return r.getParts(ctx, `
+		SELECT * FROM upload_parts WHERE status = $1 OR (status = $2 AND updated_at < $3)`,
+		entities.FileStatusPurge, entities.FileStatusNew, updatedBefore)
+}
+
+func (r *Repo) DeletePart(ctx context.Context, partID string) error {
+	_, err := r.db.Client().ExecContext(ctx, `DELETE FROM upload_parts WHERE id = $1`, partID)
+	return err
+}
+
+// CompleteUpload creates completed file from parts of the upload in one transaction. Parts which become the file
+// are removed from the upload, the rest ones are marked for purge. Reports completed false if upload is not
+// in progress and fileCreated false if file with the same id exists, upload is left unchanged then.
+func (r *Repo) CompleteUpload(ctx context.Context, uploadID string, file *entities.File, partIDs []string) (
+	completed, fileCreated bool, err error) {
+	chunksJSON, err := json.Marshal(file.Chunks)
+	if err != nil {
+		return false, false, err
+	}
+	tx, err := r.db.Client().BeginTxx(ctx, nil)
+	if err != nil {
+		return false, false, fmt.Errorf("error begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	res, err := tx.ExecContext(ctx, `UPDATE uploads SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`,
+		entities.FileStatusComplete, uploadID, entities.FileStatusNew)
+	if err != nil {
+		return false, false, fmt.Errorf("error complete upload: %w", err)
+	}
+	if updated, errR := res.RowsAffected(); errR != nil || updated == 0 {
+		return false, false, errR
+	}
+	res, err = tx.ExecContext(ctx, `
+		INSERT INTO files (id, status, created_at, updated_at, chunks, data_shards, parity_shards, size, content_type, sha256, md5)
+		VALUES ($1, $2, NOW(), NOW(), $3, 0, 0, $4, $5, $6, $7)
+		ON CONFLICT (id) DO NOTHING`,
+		file.ID, entities.FileStatusComplete, chunksJSON, file.Length, file.ContentType, file.SHA256, file.MD5)
+	if err != nil {
+		return false, false, fmt.Errorf("error save file: %w", err)
+	}
+	if inserted, errR := res.RowsAffected(); errR != nil || inserted == 0 {
+		return true, false, errR
+	}
+	for _, partID := range partIDs {
+		if _, err = tx.ExecContext(ctx, `DELETE FROM upload_parts WHERE id = $1`, partID); err != nil {
+			return false, false, fmt.Errorf("error delete used part: %w", err)
+		}
+	}
+	if _, err = tx.ExecContext(ctx, `UPDATE upload_parts SET status = $1, updated_at = NOW() WHERE upload_id = $2`,
+		entities.FileStatusPurge, uploadID); err != nil {
+		return false, false, fmt.Errorf("error mark unused parts for purge: %w", err)
+	}
+	return true, true, tx.Commit()
+}
+
+// AbortUpload marks upload in progress and its parts for purge, reports false if upload is not in progress
+func (r *Repo) AbortUpload(ctx context.Context, uploadID string) (bool, error) {
+	aborted, err := r.abortUploads(ctx, `id = $3`, uploadID)
+	return aborted > 0, err
+}
+
+// AbortUploadsUpdatedBefore marks uploads in progress which are not updated since updatedBefore and their parts
+// for purge, returns number of aborted uploads
+func (r *Repo) AbortUploadsUpdatedBefore(ctx context.Context, updatedBefore time.Time) (int, error) {
+	return r.abortUploads(ctx, `updated_at < $3`, updatedBefore)
+}
+
+func (r *Repo) abortUploads(ctx context.Context, condition string, param any) (int, error) {
+	tx, err := r.db.Client().BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	var aborted []string
+	if err = tx.SelectContext(ctx, &aborted, `
+		UPDATE uploads SET status = $1, updated_at = NOW() WHERE status = $2 AND `+condition+` RETURNING id`,
+		entities.FileStatusPurge, entities.FileStatusNew, param); err != nil {
+		return 0, fmt.Errorf("error abort uploads: %w", err)
+	}
+	for _, id := range aborted {
+		if _, err = tx.ExecContext(ctx, `UPDATE upload_parts SET status = $1, updated_at = NOW() WHERE upload_id = $2`,
+			entities.FileStatusPurge, id); err != nil {
+			return 0, fmt.Errorf("error mark parts for purge: %w", err)
+		}
+	}
+	return len(aborted), tx.Commit()
+}
+
+// DeleteFinishedUploads deletes completed and aborted uploads which parts are purged
+func (r *Repo) DeleteFinishedUploads(ctx context.Context) error {
+	_, err := r.db.Client().ExecContext(ctx, `
+		DELETE FROM uploads u WHERE status <> $1 AND NOT EXISTS (SELECT 1 FROM upload_parts p WHERE p.upload_id = u.id)`,
+		entities.FileStatusNew)
+	return err
+}
+
+// IterateChunks calls fn with chunks of every part, parts are loaded in batches ordered by id
+func (r *Repo) IterateChunks(ctx context.Context, fn func(chunks []*entities.FileChunk) error) error {
+	lastID := ""
+	for {
+		parts, err := r.getParts(ctx, `SELECT * FROM upload_parts WHERE id > $1 ORDER BY id LIMIT $2`, lastID, iterateBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, part := range parts {
+			if err = fn(part.Chunks); err != nil {
+				return err
+			}
+			lastID = part.ID
+		}
+		if len(parts) < iterateBatchSize {
+			return nil
+		}
+	}
+}
+
+func (r *Repo) getParts(ctx context.Context, query string, params ...any) ([]*entities.UploadPart, error) {
+	var parts []*entities.UploadPart
+	if err := r.db.Client().SelectContext(ctx, &parts, query, params...); err != nil {
+		return nil, err
+	}
+	for _, part := range parts {
+		if err := json.Unmarshal(part.ChunksJSON, &part.Chunks); err != nil {
+			return nil, err
+		}
+	}
+	return parts, nil
+}
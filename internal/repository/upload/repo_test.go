@@ -0,0 +1,121 @@
+package upload_test
+
+import (
+	"database/sql"
+	"extendable_storage/internal/entities"
+	testhelpers "extendable_storage/internal/test_helpers"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo_UploadsCRUD(t *testing.T) {
+	// given
+	container := testhelpers.GetClean(t)
+	upload := &entities.Upload{ID: uuid.NewString(), FileID: "photos/a.jpg", ContentType: "image/jpeg"}
+	require.NoError(t, container.RepoUpload.CreateUpload(container.Ctx, upload))
+	newPart := func(number int) *entities.UploadPart {
+		part := &entities.UploadPart{ID: uuid.NewString(), UploadID: upload.ID, Number: number, Size: 10}
+		require.NoError(t, container.RepoUpload.CreatePart(container.Ctx, part))
+		chunks := []*entities.FileChunk{{FileID: part.ID, ChunkID: uuid.NewString(), Size: 10}}
+		require.NoError(t, container.RepoUpload.UpdatePartChunks(container.Ctx, part.ID, chunks))
+		part.Chunks, part.SHA256, part.MD5 = chunks, "sha", "md5"
+		completed, err := container.RepoUpload.CompletePart(container.Ctx, part)
+		require.NoError(t, err)
+		require.True(t, completed)
+		return part
+	}
+
+	// when
+	replaced := newPart(1)
+	first := newPart(1)
+	second := newPart(2)
+
+	// then
+	stored, err := container.RepoUpload.GetUpload(container.Ctx, upload.ID)
+	require.NoError(t, err)
+	require.Equal(t, "photos/a.jpg", stored.FileID)
+	require.Equal(t, entities.FileStatusNew, stored.Status)
+	parts, err := container.RepoUpload.GetParts(container.Ctx, upload.ID)
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	require.Equal(t, first.ID, parts[0].ID)
+	require.Equal(t, first.Chunks, parts[0].Chunks)
+	require.Equal(t, second.ID, parts[1].ID)
+	toPurge, err := container.RepoUpload.GetPartsToPurge(container.Ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, toPurge, 1)
+	require.Equal(t, replaced.ID, toPurge[0].ID)
+
+	t.Run("should complete upload", func(t *testing.T) {
+		// given
+		file := &entities.File{ID: upload.FileID, Chunks: append(first.Chunks, second.Chunks...), Length: 20, MD5: "md5-2"}
+
+		// when
+		completed, created, err := container.RepoUpload.CompleteUpload(container.Ctx, upload.ID, file, []string{first.ID})
+		require.NoError(t, err)
+		require.True(t, completed)
+		require.True(t, created)
+		completed, _, err = container.RepoUpload.CompleteUpload(container.Ctx, upload.ID, file, []string{first.ID})
+		require.NoError(t, err)
+		require.False(t, completed)
+
+		// then
+		stored, err := container.RepoFile.GetFile(container.Ctx, upload.FileID)
+		require.NoError(t, err)
+		require.Equal(t, entities.FileStatusComplete, stored.Status)
+		require.Equal(t, "md5-2", stored.MD5)
+		require.Len(t, stored.Chunks, 2)
+		toPurge, err := container.RepoUpload.GetPartsToPurge(container.Ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Len(t, toPurge, 2)
+		for _, part := range toPurge {
+			require.NoError(t, container.RepoUpload.DeletePart(container.Ctx, part.ID))
+		}
+		require.NoError(t, container.RepoUpload.DeleteFinishedUploads(container.Ctx))
+		_, err = container.RepoUpload.GetUpload(container.Ctx, upload.ID)
+		require.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("should not complete upload of existing file", func(t *testing.T) {
+		// given
+		late := &entities.Upload{ID: uuid.NewString(), FileID: upload.FileID}
+		require.NoError(t, container.RepoUpload.CreateUpload(container.Ctx, late))
+		file := &entities.File{ID: late.FileID, Chunks: []*entities.FileChunk{}}
+
+		// when
+		completed, created, err := container.RepoUpload.CompleteUpload(container.Ctx, late.ID, file, nil)
+
+		// then
+		require.NoError(t, err)
+		require.True(t, completed)
+		require.False(t, created)
+		stored, err := container.RepoUpload.GetUpload(container.Ctx, late.ID)
+		require.NoError(t, err)
+		require.Equal(t, entities.FileStatusNew, stored.Status, "upload is left unchanged")
+		aborted, err := container.RepoUpload.AbortUpload(container.Ctx, late.ID)
+		require.NoError(t, err)
+		require.True(t, aborted)
+	})
+
+	t.Run("should abort abandoned upload", func(t *testing.T) {
+		// given
+		abandoned := &entities.Upload{ID: uuid.NewString(), FileID: "photos/b.jpg"}
+		require.NoError(t, container.RepoUpload.CreateUpload(container.Ctx, abandoned))
+
+		// when
+		aborted, err := container.RepoUpload.AbortUploadsUpdatedBefore(container.Ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		require.Zero(t, aborted)
+		aborted, err = container.RepoUpload.AbortUploadsUpdatedBefore(container.Ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		// then
+		require.Equal(t, 1, aborted)
+		stored, err := container.RepoUpload.GetUpload(container.Ctx, abandoned.ID)
+		require.NoError(t, err)
+		require.Equal(t, entities.FileStatusPurge, stored.Status)
+	})
+}
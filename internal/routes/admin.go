@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"errors"
+	"extendable_storage/internal/service/orchestrator"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (s *Server) listRebalance(ctx *fiber.Ctx) error {
+	progress, err := s.router.ListRebalanceProgress(ctx.UserContext())
+	if err != nil {
+		s.log.Error("error list rebalance jobs", err)
+		return ctx.Status(fiber.StatusInternalServerError).SendString("internal error")
+	}
+	return ctx.JSON(progress)
+}
+
+func (s *Server) getRebalance(ctx *fiber.Ctx) error {
+	serverID := ctx.Params("id")
+	progress, err := s.router.GetRebalanceProgress(ctx.UserContext(), serverID)
+	switch {
+	case errors.Is(err, orchestrator.ErrRebalanceJobAbsent):
+		return ctx.Status(fiber.StatusNotFound).SendString(err.Error())
+	case err != nil:
+		s.log.Error("error get rebalance job", err, slog.String("server_id", serverID))
+		return ctx.Status(fiber.StatusInternalServerError).SendString("internal error")
+	}
+	return ctx.JSON(progress)
+}
+
+// getCacheStats returns hit and miss counters of chunk cache, 404 if cache is disabled
+func (s *Server) getCacheStats(ctx *fiber.Ctx) error {
+	cached, ok := s.router.(interface {
+		CacheStats() orchestrator.CacheStats
+	})
+	if !ok {
+		return ctx.Status(fiber.StatusNotFound).SendString("chunk cache is disabled")
+	}
+	return ctx.JSON(cached.CacheStats())
+}
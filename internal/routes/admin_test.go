@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/orchestrator"
+	"extendable_storage/internal/service/receiver"
+	"extendable_storage/internal/storage/cache/lru"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_Admin(t *testing.T) {
+	// given
+	mck := gomock.NewController(t)
+	router := orchestrator.NewMockDataRouter(mck)
+	srv := InitAppRouter(logger.NewAppSLogger("test"), receiver.NewMockDataReceiver(mck), router, nil, ":0")
+	progress := &orchestrator.RebalanceProgress{
+		ServerID: "NODE_A",
+		Kind:     entities.JobKindJoin,
+		Sectors:  3,
+		States:   map[entities.SectorState]int{entities.SectorStateVerified: 1, entities.SectorStatePending: 2},
+	}
+
+	t.Run("should return rebalance progress", func(t *testing.T) {
+		// given
+		router.EXPECT().GetRebalanceProgress(gomock.Any(), "NODE_A").Return(progress, nil)
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/admin/rebalance/NODE_A", nil))
+
+		// then
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var received orchestrator.RebalanceProgress
+		require.NoError(t, json.Unmarshal(body, &received))
+		require.Equal(t, *progress, received)
+	})
+	t.Run("should return not found for node without job", func(t *testing.T) {
+		// given
+		router.EXPECT().GetRebalanceProgress(gomock.Any(), "NODE_B").Return(nil, fmt.Errorf("wrapped: %w", orchestrator.ErrRebalanceJobAbsent))
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/admin/rebalance/NODE_B", nil))
+
+		// then
+		require.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+	t.Run("should list rebalance jobs", func(t *testing.T) {
+		// given
+		router.EXPECT().ListRebalanceProgress(gomock.Any()).Return([]*orchestrator.RebalanceProgress{progress}, nil)
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/admin/rebalance", nil))
+
+		// then
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var received []*orchestrator.RebalanceProgress
+		require.NoError(t, json.Unmarshal(body, &received))
+		require.Equal(t, []*orchestrator.RebalanceProgress{progress}, received)
+	})
+	t.Run("should return not found if cache is disabled", func(t *testing.T) {
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/admin/cache", nil))
+
+		// then
+		require.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestServer_AdminCache(t *testing.T) {
+	// given
+	mck := gomock.NewController(t)
+	router := orchestrator.NewMockDataRouter(mck)
+	cache := lru.CreateShardedCache(1, 0)
+	cachingRouter := orchestrator.NewCachingRouter(router, cache, nil)
+	srv := InitAppRouter(logger.NewAppSLogger("test"), receiver.NewMockDataReceiver(mck), cachingRouter, nil, ":0")
+	chunk := &entities.FileChunk{FileID: "a", ChunkID: "b"}
+	router.EXPECT().GetFileChunk(gomock.Any(), chunk).Return([]byte("data"), nil)
+	for i := 0; i < 3; i++ {
+		_, err := cachingRouter.GetFileChunk(context.Background(), chunk)
+		require.NoError(t, err)
+	}
+
+	// when
+	resp := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/admin/cache", nil))
+
+	// then
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	var received orchestrator.CacheStats
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&received))
+	require.Equal(t, orchestrator.CacheStats{Hits: 2, Misses: 1}, received)
+}
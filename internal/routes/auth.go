@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/auth"
+	"log/slog"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	headerAPIKey   = "X-API-Key"
+	localPrincipal = "principal"
+)
+
+type createTenantRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+type createKeyRequest struct {
+	Scope entities.Scope `json:"scope"`
+}
+
+type createKeyResponse struct {
+	Key string `json:"key"`
+}
+
+// authenticate resolves caller from X-API-Key header or bearer token, request without valid credentials gets 401
+func (s *Server) authenticate(ctx *fiber.Ctx) error {
+	var (
+		principal *entities.Principal
+		err       error
+	)
+	if key := ctx.Get(headerAPIKey); key != "" {
+		principal, err = s.auth.AuthenticateKey(ctx.UserContext(), key)
+	} else if token, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer "); ok {
+		principal, err = s.auth.AuthenticateToken(ctx.UserContext(), token)
+	} else {
+		err = auth.ErrUnauthenticated
+	}
+	if errors.Is(err, auth.ErrUnauthenticated) {
+		ctx.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return ctx.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+	if err != nil {
+		s.log.Error("error authenticate request", err)
+		return ctx.Status(fiber.StatusInternalServerError).SendString("internal error")
+	}
+	ctx.Locals(localPrincipal, principal)
+	return ctx.Next()
+}
+
+// fileAccess passes request to file of id param if caller can read (or write) it
+func (s *Server) fileAccess(write bool) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if !s.allowed(ctx, ctx.Params("id"), write) {
+			return ctx.Status(fiber.StatusForbidden).SendString("forbidden")
+		}
+		return ctx.Next()
+	}
+}
+
+// uploadAccess passes request to upload of upload param if caller can write the file of the upload
+func (s *Server) uploadAccess(ctx *fiber.Ctx) error {
+	if s.auth == nil {
+		return ctx.Next()
+	}
+	uploadID := ctx.Params("upload")
+	upload, err := s.service.GetUpload(ctx.UserContext(), uploadID)
+	if err != nil {
+		return s.handleUploadError(ctx, err, uploadID)
+	}
+	if !s.allowed(ctx, upload.FileID, true) {
+		return ctx.Status(fiber.StatusForbidden).SendString("forbidden")
+	}
+	return ctx.Next()
+}
+
+// adminAccess passes request to admin API if caller has admin scope
+func (s *Server) adminAccess(ctx *fiber.Ctx) error {
+	if principal := s.principal(ctx); principal != nil && principal.Scope != entities.ScopeAdmin {
+		return ctx.Status(fiber.StatusForbidden).SendString("forbidden")
+	}
+	return ctx.Next()
+}
+
+// allowed reports whether caller can access the file, any file is allowed if authentication is disabled
+func (s *Server) allowed(ctx *fiber.Ctx, fileID string, write bool) bool {
+	if s.auth == nil {
+		return true
+	}
+	principal := s.principal(ctx)
+	return principal != nil && principal.CanAccess(fileID, write)
+}
+
+func (s *Server) principal(ctx *fiber.Ctx) *entities.Principal {
+	principal, _ := ctx.Locals(localPrincipal).(*entities.Principal)
+	return principal
+}
+
+func (s *Server) createTenant(ctx *fiber.Ctx) error {
+	var req createTenantRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("invalid request body")
+	}
+	tenant, err := s.auth.CreateTenant(ctx.UserContext(), req.Namespace)
+	if err != nil {
+		return s.handleAuthError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(tenant)
+}
+
+// createKey creates API key of the tenant, the key is returned only in this response
+func (s *Server) createKey(ctx *fiber.Ctx) error {
+	var req createKeyRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("invalid request body")
+	}
+	key, err := s.auth.CreateKey(ctx.UserContext(), ctx.Params("namespace"), req.Scope)
+	if err != nil {
+		return s.handleAuthError(ctx, err)
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(createKeyResponse{Key: key})
+}
+
+func (s *Server) revokeKey(ctx *fiber.Ctx) error {
+	if err := s.auth.RevokeKey(ctx.UserContext(), ctx.Params("id")); err != nil {
+		return s.handleAuthError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (s *Server) handleAuthError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidNamespace), errors.Is(err, auth.ErrInvalidScope):
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	case errors.Is(err, auth.ErrTenantExists):
+		return ctx.Status(fiber.StatusConflict).SendString(err.Error())
+	case errors.Is(err, auth.ErrTenantNotFound), errors.Is(err, auth.ErrKeyNotFound):
+		return ctx.Status(fiber.StatusNotFound).SendString(err.Error())
+	default:
+		s.log.Error("error process tenant request", err, slog.String("path", ctx.Path()))
+		return ctx.Status(fiber.StatusInternalServerError).SendString("internal error")
+	}
+}
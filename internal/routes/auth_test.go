@@ -0,0 +1,142 @@
+package routes
+
+import (
+	"encoding/json"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/auth"
+	"extendable_storage/internal/service/orchestrator"
+	"extendable_storage/internal/service/receiver"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_Auth(t *testing.T) {
+	// given
+	mck := gomock.NewController(t)
+	service := receiver.NewMockDataReceiver(mck)
+	router := orchestrator.NewMockDataRouter(mck)
+	authenticator := auth.NewMockAuthenticator(mck)
+	srv := InitAppRouter(logger.NewAppSLogger("test"), service, router, authenticator, ":0")
+	principals := map[string]*entities.Principal{
+		"reader": {Namespace: "acme", Scope: entities.ScopeRead},
+		"writer": {Namespace: "acme", Scope: entities.ScopeReadWrite},
+		"admin":  {Scope: entities.ScopeAdmin},
+	}
+	authenticator.EXPECT().AuthenticateKey(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ any, key string) (*entities.Principal, error) {
+			if principal, ok := principals[key]; ok {
+				return principal, nil
+			}
+			return nil, auth.ErrUnauthenticated
+		})
+	request := func(method, path, key string, body io.Reader) *http.Request {
+		req := httptest.NewRequest(method, path, body)
+		if key != "" {
+			req.Header.Set(headerAPIKey, key)
+		}
+		return req
+	}
+	file := &entities.File{ID: "acme:a.txt", Status: entities.FileStatusComplete}
+
+	t.Run("should reject request without credentials", func(t *testing.T) {
+		// when
+		resp := doRequest(t, srv, request(http.MethodGet, "/files/acme:a.txt", "", nil))
+		respWrongKey := doRequest(t, srv, request(http.MethodGet, "/files/acme:a.txt", "unknown", nil))
+		respPing := doRequest(t, srv, request(http.MethodGet, "/", "", nil))
+
+		// then
+		require.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+		require.Equal(t, "Bearer", resp.Header.Get(fiber.HeaderWWWAuthenticate))
+		require.Equal(t, fiber.StatusUnauthorized, respWrongKey.StatusCode)
+		require.Equal(t, fiber.StatusOK, respPing.StatusCode)
+	})
+	t.Run("should authenticate bearer token", func(t *testing.T) {
+		// given
+		authenticator.EXPECT().AuthenticateToken(gomock.Any(), "token").Return(principals["reader"], nil)
+		service.EXPECT().GetFileInfo(gomock.Any(), "acme:a.txt").Return(file, nil)
+		service.EXPECT().GetFileStream(gomock.Any(), "acme:a.txt").Return(io.NopCloser(strings.NewReader("data")), int64(4), nil)
+		req := request(http.MethodGet, "/files/acme:a.txt", "", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer token")
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+	t.Run("should allow only files of namespace", func(t *testing.T) {
+		// when
+		resp := doRequest(t, srv, request(http.MethodGet, "/files/other:a.txt", "reader", nil))
+		respPrefix := doRequest(t, srv, request(http.MethodGet, "/files/acme-corp:a.txt", "reader", nil))
+
+		// then
+		require.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+		require.Equal(t, fiber.StatusForbidden, respPrefix.StatusCode)
+	})
+	t.Run("should check scope of the key", func(t *testing.T) {
+		// given
+		service.EXPECT().SaveFileStream(gomock.Any(), "acme:b.txt", gomock.Any(), int64(4), gomock.Any()).Return(nil)
+
+		// when
+		respReader := doRequest(t, srv, request(http.MethodPut, "/files/acme:b.txt", "reader", strings.NewReader("data")))
+		respWriter := doRequest(t, srv, request(http.MethodPut, "/files/acme:b.txt", "writer", strings.NewReader("data")))
+		respAdmin := doRequest(t, srv, request(http.MethodGet, "/admin/rebalance", "writer", nil))
+
+		// then
+		require.Equal(t, fiber.StatusForbidden, respReader.StatusCode)
+		require.Equal(t, fiber.StatusCreated, respWriter.StatusCode)
+		require.Equal(t, fiber.StatusForbidden, respAdmin.StatusCode)
+	})
+	t.Run("should check namespace of upload", func(t *testing.T) {
+		// given
+		service.EXPECT().GetUpload(gomock.Any(), "u1").Return(&entities.Upload{ID: "u1", FileID: "other:a.txt"}, nil)
+		service.EXPECT().GetUpload(gomock.Any(), "u2").Return(&entities.Upload{ID: "u2", FileID: "acme:a.txt"}, nil)
+		service.EXPECT().AbortUpload(gomock.Any(), "u2").Return(nil)
+
+		// when
+		respOther := doRequest(t, srv, request(http.MethodDelete, "/uploads/u1", "writer", nil))
+		respOwn := doRequest(t, srv, request(http.MethodDelete, "/uploads/u2", "writer", nil))
+
+		// then
+		require.Equal(t, fiber.StatusForbidden, respOther.StatusCode)
+		require.Equal(t, fiber.StatusNoContent, respOwn.StatusCode)
+	})
+	t.Run("should manage tenants and keys by admin", func(t *testing.T) {
+		// given
+		authenticator.EXPECT().CreateTenant(gomock.Any(), "acme").Return(&entities.Tenant{Namespace: "acme"}, nil)
+		authenticator.EXPECT().CreateTenant(gomock.Any(), "Bad").Return(nil, auth.ErrInvalidNamespace)
+		authenticator.EXPECT().CreateKey(gomock.Any(), "acme", entities.ScopeRead).Return("id.secret", nil)
+		authenticator.EXPECT().RevokeKey(gomock.Any(), "id").Return(fmt.Errorf("wrapped: %w", auth.ErrKeyNotFound))
+		jsonRequest := func(method, path, body string) *http.Request {
+			req := request(method, path, "admin", strings.NewReader(body))
+			req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			return req
+		}
+
+		// when
+		respTenant := doRequest(t, srv, jsonRequest(http.MethodPost, "/admin/tenants", `{"namespace":"acme"}`))
+		respInvalid := doRequest(t, srv, jsonRequest(http.MethodPost, "/admin/tenants", `{"namespace":"Bad"}`))
+		respKey := doRequest(t, srv, jsonRequest(http.MethodPost, "/admin/tenants/acme/keys", `{"scope":"read"}`))
+		respRevoke := doRequest(t, srv, request(http.MethodDelete, "/admin/keys/id", "admin", nil))
+		respNotAdmin := doRequest(t, srv, request(http.MethodDelete, "/admin/keys/id", "writer", nil))
+
+		// then
+		require.Equal(t, fiber.StatusCreated, respTenant.StatusCode)
+		require.Equal(t, fiber.StatusBadRequest, respInvalid.StatusCode)
+		require.Equal(t, fiber.StatusCreated, respKey.StatusCode)
+		var key createKeyResponse
+		require.NoError(t, json.NewDecoder(respKey.Body).Decode(&key))
+		require.Equal(t, "id.secret", key.Key)
+		require.Equal(t, fiber.StatusNotFound, respRevoke.StatusCode)
+		require.Equal(t, fiber.StatusForbidden, respNotAdmin.StatusCode)
+	})
+}
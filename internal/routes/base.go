@@ -2,6 +2,8 @@ package routes
 
 import (
 	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/auth"
+	"extendable_storage/internal/service/orchestrator"
 	"extendable_storage/internal/service/receiver"
 	"log/slog"
 
@@ -9,19 +11,28 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
+const (
+	bodyBufferSize = 4 * 1024 * 1024 // request body above this size is streamed instead of buffered
+)
+
 type Server struct {
 	appAddr    string
 	log        logger.AppLogger
 	service    receiver.DataReceiver
+	router     orchestrator.DataRouter
+	auth       auth.Authenticator
 	httpEngine *fiber.App
 }
 
-// InitAppRouter initializes the HTTP Server.
-func InitAppRouter(log logger.AppLogger, service receiver.DataReceiver, address string) *Server {
+// InitAppRouter initializes the HTTP Server. Requests are authenticated by authenticator, nil disables authentication.
+func InitAppRouter(log logger.AppLogger, service receiver.DataReceiver, router orchestrator.DataRouter, authenticator auth.Authenticator,
+	address string) *Server {
 	app := &Server{
 		appAddr:    address,
-		httpEngine: fiber.New(fiber.Config{}),
+		httpEngine: fiber.New(fiber.Config{BodyLimit: bodyBufferSize, StreamRequestBody: true}),
 		service:    service,
+		router:     router,
+		auth:       authenticator,
 		log:        log.With(slog.String("service", "http")),
 	}
 	app.httpEngine.Use(recover.New())
@@ -33,6 +44,26 @@ func (s *Server) initRoutes() {
 	s.httpEngine.Get("/", func(ctx *fiber.Ctx) error {
 		return ctx.SendString("pong")
 	})
+	if s.auth != nil {
+		s.httpEngine.Use(s.authenticate)
+	}
+	s.httpEngine.Get("/files/:id", s.fileAccess(false), s.getFile)
+	s.httpEngine.Put("/files/:id", s.fileAccess(true), s.saveFile)
+	s.httpEngine.Post("/files/:id", s.fileAccess(true), s.saveFile)
+	s.httpEngine.Post("/files/:id/uploads", s.fileAccess(true), s.initiateUpload)
+	s.httpEngine.Put("/uploads/:upload/parts/:number", s.uploadAccess, s.savePart)
+	s.httpEngine.Get("/uploads/:upload/parts", s.uploadAccess, s.listParts)
+	s.httpEngine.Post("/uploads/:upload/complete", s.uploadAccess, s.completeUpload)
+	s.httpEngine.Delete("/uploads/:upload", s.uploadAccess, s.abortUpload)
+	admin := s.httpEngine.Group("/admin", s.adminAccess)
+	admin.Get("/rebalance", s.listRebalance)
+	admin.Get("/rebalance/:id", s.getRebalance)
+	admin.Get("/cache", s.getCacheStats)
+	if s.auth != nil {
+		admin.Post("/tenants", s.createTenant)
+		admin.Post("/tenants/:namespace/keys", s.createKey)
+		admin.Delete("/keys/:id", s.revokeKey)
+	}
 }
 
 // Run starts the HTTP Server.
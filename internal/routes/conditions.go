@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"extendable_storage/internal/entities"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// checkPreconditions evaluates If-Match and If-None-Match headers of read request against the file.
+// Returns status to respond with, zero means file should be served.
+func checkPreconditions(ctx *fiber.Ctx, file *entities.File) int {
+	etag := file.ETag()
+	if header := ctx.Get(fiber.HeaderIfMatch); header != "" && !etagMatches(header, etag, false) {
+		return fiber.StatusPreconditionFailed
+	}
+	if header := ctx.Get(fiber.HeaderIfNoneMatch); header != "" && etagMatches(header, etag, true) {
+		return fiber.StatusNotModified
+	}
+	return 0
+}
+
+// etagMatches reports whether comma separated list of entity tags contains etag, "*" matches any file.
+// Weak comparison ignores W/ prefix, strong one never matches weak tags. Unknown etag matches only "*".
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if etag != "" && tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtagMatches(t *testing.T) {
+	table := map[string]struct {
+		header string
+		etag   string
+		weak   bool
+		match  bool
+	}{
+		"same tag":               {header: `"abc"`, etag: `"abc"`, match: true},
+		"other tag":              {header: `"def"`, etag: `"abc"`},
+		"tag in list":            {header: `"def", "abc"`, etag: `"abc"`, match: true},
+		"any tag":                {header: "*", etag: `"abc"`, match: true},
+		"any tag of legacy file": {header: "*", match: true},
+		"tag of legacy file":     {header: `"abc"`},
+		"weak tag strong":        {header: `W/"abc"`, etag: `"abc"`},
+		"weak tag weak":          {header: `W/"abc"`, etag: `"abc"`, weak: true, match: true},
+		"unquoted tag":           {header: "abc", etag: `"abc"`},
+	}
+	for name, tc := range table {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.match, etagMatches(tc.header, tc.etag, tc.weak))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/receiver"
+	"fmt"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// getFile serves file with its ETag and content type, If-Match and If-None-Match headers are checked before
+func (s *Server) getFile(ctx *fiber.Ctx) error {
+	fileID := ctx.Params("id")
+	file, err := s.service.GetFileInfo(ctx.UserContext(), fileID)
+	if err != nil {
+		return s.handleError(ctx, err, fileID)
+	}
+	ctx.Set(fiber.HeaderAcceptRanges, "bytes")
+	if etag := file.ETag(); etag != "" {
+		ctx.Set(fiber.HeaderETag, etag)
+	}
+	if status := checkPreconditions(ctx, file); status != 0 {
+		return ctx.SendStatus(status)
+	}
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = fiber.MIMEOctetStream
+	}
+	ctx.Set(fiber.HeaderContentType, contentType)
+	if rangeHeader := ctx.Get(fiber.HeaderRange); rangeHeader != "" {
+		return s.getFileRange(ctx, file, rangeHeader)
+	}
+	return s.sendFile(ctx, fileID)
+}
+
+func (s *Server) sendFile(ctx *fiber.Ctx, fileID string) error {
+	data, size, err := s.service.GetFileStream(ctx.UserContext(), fileID)
+	if err != nil {
+		return s.handleError(ctx, err, fileID)
+	}
+	if size < 0 {
+		// size is unknown, send with chunked transfer encoding
+		return ctx.SendStream(data)
+	}
+	return ctx.SendStream(data, int(size))
+}
+
+// getFileRange serves part of the file requested with Range header.
+// Unsupported or invalid range header is ignored and whole file is sent.
+func (s *Server) getFileRange(ctx *fiber.Ctx, file *entities.File, rangeHeader string) error {
+	size := file.Size()
+	if size < 0 {
+		return s.sendFile(ctx, file.ID)
+	}
+	offset, length, err := parseRange(rangeHeader, size)
+	switch {
+	case errors.Is(err, errRangeInvalid):
+		return s.sendFile(ctx, file.ID)
+	case errors.Is(err, errRangeNotSatisfiable):
+		ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", size))
+		return ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	}
+	data, err := s.service.GetFileRange(ctx.UserContext(), file.ID, offset, length)
+	if err != nil {
+		return s.handleError(ctx, err, file.ID)
+	}
+	ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+	ctx.Status(fiber.StatusPartialContent)
+	return ctx.SendStream(data, int(length))
+}
+
+func (s *Server) saveFile(ctx *fiber.Ctx) error {
+	fileID := ctx.Params("id")
+	size := ctx.Request().Header.ContentLength()
+	if size < 0 {
+		return ctx.Status(fiber.StatusLengthRequired).SendString("content length is required")
+	}
+	opts, err := parseSaveOptions(ctx.Get(headerErasureCoding))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	opts.ContentType = ctx.Get(fiber.HeaderContentType)
+	// body is streamed, so big files are never buffered in memory
+	body := ctx.Context().RequestBodyStream()
+	if err = s.service.SaveFileStream(ctx.UserContext(), fileID, body, int64(size), opts); err != nil {
+		// files are immutable, "If-None-Match: *" asks to create file only if it doesn't exist
+		if errors.Is(err, receiver.ErrFileAlreadyExists) && ctx.Get(fiber.HeaderIfNoneMatch) == "*" {
+			return ctx.Status(fiber.StatusPreconditionFailed).SendString(err.Error())
+		}
+		return s.handleError(ctx, err, fileID)
+	}
+	return ctx.SendStatus(fiber.StatusCreated)
+}
+
+// handleError maps receiver errors to http status codes
+func (s *Server) handleError(ctx *fiber.Ctx, err error, fileID string) error {
+	switch {
+	case errors.Is(err, receiver.ErrFileAlreadyExists):
+		return ctx.Status(fiber.StatusConflict).SendString(err.Error())
+	case errors.Is(err, receiver.ErrInvalidSaveOptions):
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	case errors.Is(err, receiver.ErrFileTooLarge):
+		return ctx.Status(fiber.StatusRequestEntityTooLarge).SendString(err.Error())
+	case errors.Is(err, receiver.ErrNotEnoughKeepers):
+		return ctx.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+	case errors.Is(err, receiver.ErrRangeNotSatisfiable):
+		return ctx.SendStatus(fiber.StatusRequestedRangeNotSatisfiable)
+	case errors.Is(err, sql.ErrNoRows):
+		return ctx.Status(fiber.StatusNotFound).SendString("file not found")
+	default:
+		s.log.Error("error process file request", err, slog.String("file_id", fileID))
+		return ctx.Status(fiber.StatusInternalServerError).SendString("internal error")
+	}
+}
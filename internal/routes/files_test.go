@@ -0,0 +1,274 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/orchestrator"
+	"extendable_storage/internal/service/receiver"
+	"extendable_storage/internal/utils"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_Files(t *testing.T) {
+	// given
+	mck := gomock.NewController(t)
+	service := receiver.NewMockDataReceiver(mck)
+	srv := InitAppRouter(logger.NewAppSLogger("test"), service, orchestrator.NewMockDataRouter(mck), nil, ":0")
+	payload := []byte("some file content")
+	fileInfo := &entities.File{
+		ID:          "abc",
+		Chunks:      []*entities.FileChunk{{Size: int64(len(payload))}},
+		ContentType: "text/plain",
+		SHA256:      utils.HashData(payload),
+	}
+
+	t.Run("should save file", func(t *testing.T) {
+		// given
+		service.EXPECT().SaveFileStream(gomock.Any(), "abc", gomock.Any(), int64(len(payload)), receiver.SaveOptions{}).
+			DoAndReturn(func(_ context.Context, _ string, data io.Reader, _ int64, _ receiver.SaveOptions) error {
+				received, err := io.ReadAll(data)
+				require.NoError(t, err)
+				require.Equal(t, payload, received)
+				return nil
+			})
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodPut, "/files/abc", bytes.NewReader(payload)))
+
+		// then
+		require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	})
+	t.Run("should return conflict for existing file", func(t *testing.T) {
+		// given
+		service.EXPECT().SaveFileStream(gomock.Any(), "abc", gomock.Any(), int64(len(payload)), gomock.Any()).
+			Return(fmt.Errorf("wrapped: %w", receiver.ErrFileAlreadyExists))
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodPost, "/files/abc", bytes.NewReader(payload)))
+
+		// then
+		require.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	})
+	t.Run("should save erasure coded file", func(t *testing.T) {
+		// given
+		service.EXPECT().SaveFileStream(gomock.Any(), "ec", gomock.Any(), int64(len(payload)), receiver.SaveOptions{DataShards: 6, ParityShards: 3}).
+			Return(nil)
+		req := httptest.NewRequest(http.MethodPut, "/files/ec", bytes.NewReader(payload))
+		req.Header.Set(headerErasureCoding, "6+3")
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	})
+	t.Run("should reject invalid erasure coding scheme", func(t *testing.T) {
+		// given
+		req := httptest.NewRequest(http.MethodPut, "/files/ec", bytes.NewReader(payload))
+		req.Header.Set(headerErasureCoding, "6")
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("should reject too large erasure coded file", func(t *testing.T) {
+		// given
+		service.EXPECT().SaveFileStream(gomock.Any(), "big", gomock.Any(), int64(len(payload)), receiver.SaveOptions{DataShards: 6, ParityShards: 3}).
+			Return(receiver.ErrFileTooLarge)
+		req := httptest.NewRequest(http.MethodPut, "/files/big", bytes.NewReader(payload))
+		req.Header.Set(headerErasureCoding, "6+3")
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+	t.Run("should save content type", func(t *testing.T) {
+		// given
+		service.EXPECT().SaveFileStream(gomock.Any(), "text", gomock.Any(), int64(len(payload)), receiver.SaveOptions{ContentType: "text/plain"}).
+			Return(nil)
+		req := httptest.NewRequest(http.MethodPut, "/files/text", bytes.NewReader(payload))
+		req.Header.Set(fiber.HeaderContentType, "text/plain")
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	})
+	t.Run("should fail precondition for existing file", func(t *testing.T) {
+		// given
+		service.EXPECT().SaveFileStream(gomock.Any(), "abc", gomock.Any(), int64(len(payload)), gomock.Any()).
+			Return(fmt.Errorf("wrapped: %w", receiver.ErrFileAlreadyExists))
+		req := httptest.NewRequest(http.MethodPut, "/files/abc", bytes.NewReader(payload))
+		req.Header.Set(fiber.HeaderIfNoneMatch, "*")
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusPreconditionFailed, resp.StatusCode)
+	})
+	t.Run("should serve file", func(t *testing.T) {
+		// given
+		service.EXPECT().GetFileInfo(gomock.Any(), "abc").Return(fileInfo, nil)
+		service.EXPECT().GetFileStream(gomock.Any(), "abc").
+			Return(io.NopCloser(bytes.NewReader(payload)), int64(len(payload)), nil)
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/files/abc", http.NoBody))
+
+		// then
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/plain", resp.Header.Get(fiber.HeaderContentType))
+		require.Equal(t, fileInfo.ETag(), resp.Header.Get(fiber.HeaderETag))
+		require.Equal(t, fmt.Sprintf("%d", len(payload)), resp.Header.Get(fiber.HeaderContentLength))
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload, body)
+	})
+	t.Run("should serve legacy file as octet stream without etag", func(t *testing.T) {
+		// given
+		service.EXPECT().GetFileInfo(gomock.Any(), "legacy").Return(&entities.File{ID: "legacy"}, nil)
+		service.EXPECT().GetFileStream(gomock.Any(), "legacy").
+			Return(io.NopCloser(bytes.NewReader(payload)), int64(-1), nil)
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/files/legacy", http.NoBody))
+
+		// then
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		require.Equal(t, fiber.MIMEOctetStream, resp.Header.Get(fiber.HeaderContentType))
+		require.Empty(t, resp.Header.Get(fiber.HeaderETag))
+	})
+	t.Run("should return not modified for matching etag", func(t *testing.T) {
+		// given
+		service.EXPECT().GetFileInfo(gomock.Any(), "abc").Return(fileInfo, nil)
+		req := httptest.NewRequest(http.MethodGet, "/files/abc", http.NoBody)
+		req.Header.Set(fiber.HeaderIfNoneMatch, fileInfo.ETag())
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusNotModified, resp.StatusCode)
+		require.Equal(t, fileInfo.ETag(), resp.Header.Get(fiber.HeaderETag))
+	})
+	t.Run("should fail precondition for other etag", func(t *testing.T) {
+		// given
+		service.EXPECT().GetFileInfo(gomock.Any(), "abc").Return(fileInfo, nil)
+		req := httptest.NewRequest(http.MethodGet, "/files/abc", http.NoBody)
+		req.Header.Set(fiber.HeaderIfMatch, `"other"`)
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusPreconditionFailed, resp.StatusCode)
+	})
+	t.Run("should serve file for matching etag", func(t *testing.T) {
+		// given
+		service.EXPECT().GetFileInfo(gomock.Any(), "abc").Return(fileInfo, nil)
+		service.EXPECT().GetFileStream(gomock.Any(), "abc").
+			Return(io.NopCloser(bytes.NewReader(payload)), int64(len(payload)), nil)
+		req := httptest.NewRequest(http.MethodGet, "/files/abc", http.NoBody)
+		req.Header.Set(fiber.HeaderIfMatch, fileInfo.ETag())
+		req.Header.Set(fiber.HeaderIfNoneMatch, `"other"`)
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+	})
+	t.Run("should stream big file", func(t *testing.T) {
+		// given
+		bigPayload := make([]byte, 3*bodyBufferSize)
+		_, err := rand.Read(bigPayload)
+		require.NoError(t, err)
+		service.EXPECT().SaveFileStream(gomock.Any(), "big", gomock.Any(), int64(len(bigPayload)), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, data io.Reader, _ int64, _ receiver.SaveOptions) error {
+				received, errR := io.ReadAll(data)
+				require.NoError(t, errR)
+				require.Equal(t, bigPayload, received)
+				return nil
+			})
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodPut, "/files/big", bytes.NewReader(bigPayload)))
+
+		// then
+		require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	})
+	t.Run("should serve file range", func(t *testing.T) {
+		// given
+		service.EXPECT().GetFileInfo(gomock.Any(), "abc").Return(&entities.File{
+			ID:     "abc",
+			Chunks: []*entities.FileChunk{{Size: 10}, {Offset: 10, Size: int64(len(payload) - 10)}},
+		}, nil)
+		service.EXPECT().GetFileRange(gomock.Any(), "abc", int64(5), int64(6)).
+			Return(io.NopCloser(bytes.NewReader(payload[5:11])), nil)
+		req := httptest.NewRequest(http.MethodGet, "/files/abc", http.NoBody)
+		req.Header.Set(fiber.HeaderRange, "bytes=5-10")
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusPartialContent, resp.StatusCode)
+		require.Equal(t, fmt.Sprintf("bytes 5-10/%d", len(payload)), resp.Header.Get(fiber.HeaderContentRange))
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload[5:11], body)
+	})
+	t.Run("should reject unsatisfiable range", func(t *testing.T) {
+		// given
+		service.EXPECT().GetFileInfo(gomock.Any(), "abc").Return(&entities.File{
+			ID:     "abc",
+			Chunks: []*entities.FileChunk{{Size: int64(len(payload))}},
+		}, nil)
+		req := httptest.NewRequest(http.MethodGet, "/files/abc", http.NoBody)
+		req.Header.Set(fiber.HeaderRange, "bytes=1000-")
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+		require.Equal(t, fmt.Sprintf("bytes */%d", len(payload)), resp.Header.Get(fiber.HeaderContentRange))
+	})
+	t.Run("should return not found for unknown file", func(t *testing.T) {
+		// given
+		service.EXPECT().GetFileInfo(gomock.Any(), "unknown").Return(nil, fmt.Errorf("wrapped: %w", sql.ErrNoRows))
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/files/unknown", http.NoBody))
+
+		// then
+		require.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func doRequest(t *testing.T, srv *Server, req *http.Request) *http.Response {
+	resp, err := srv.httpEngine.Test(req, -1)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, resp.Body.Close())
+	})
+	return resp
+}
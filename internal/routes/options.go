@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"errors"
+	"extendable_storage/internal/service/receiver"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerErasureCoding = "X-Erasure-Coding"
+)
+
+var errErasureCodingInvalid = errors.New("erasure coding header should be in format <data>+<parity>, e.g. 6+3")
+
+// parseSaveOptions builds save options from X-Erasure-Coding header. Empty header means plain chunks.
+func parseSaveOptions(erasureHeader string) (receiver.SaveOptions, error) {
+	if erasureHeader == "" {
+		return receiver.SaveOptions{}, nil
+	}
+	dataPart, parityPart, ok := strings.Cut(strings.TrimSpace(erasureHeader), "+")
+	if !ok {
+		return receiver.SaveOptions{}, errErasureCodingInvalid
+	}
+	dataShards, err := strconv.Atoi(dataPart)
+	if err != nil || dataShards < 1 {
+		return receiver.SaveOptions{}, errErasureCodingInvalid
+	}
+	parityShards, err := strconv.Atoi(parityPart)
+	if err != nil || parityShards < 1 {
+		return receiver.SaveOptions{}, errErasureCodingInvalid
+	}
+	return receiver.SaveOptions{DataShards: dataShards, ParityShards: parityShards}, nil
+}
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"extendable_storage/internal/service/receiver"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSaveOptions(t *testing.T) {
+	table := map[string]struct {
+		header string
+		opts   receiver.SaveOptions
+		err    error
+	}{
+		"no header":        {header: "", opts: receiver.SaveOptions{}},
+		"erasure coding":   {header: "6+3", opts: receiver.SaveOptions{DataShards: 6, ParityShards: 3}},
+		"with spaces":      {header: " 4+2 ", opts: receiver.SaveOptions{DataShards: 4, ParityShards: 2}},
+		"missing parity":   {header: "6", err: errErasureCodingInvalid},
+		"zero parity":      {header: "6+0", err: errErasureCodingInvalid},
+		"negative data":    {header: "-1+3", err: errErasureCodingInvalid},
+		"not a number":     {header: "a+b", err: errErasureCodingInvalid},
+		"too many symbols": {header: "6+3+1", err: errErasureCodingInvalid},
+	}
+	for name, tc := range table {
+		t.Run(name, func(t *testing.T) {
+			opts, err := parseSaveOptions(tc.header)
+			require.ErrorIs(t, err, tc.err)
+			require.Equal(t, tc.opts, opts)
+		})
+	}
+}
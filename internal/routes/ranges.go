@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	errRangeInvalid        = errors.New("invalid range")
+	errRangeNotSatisfiable = errors.New("range not satisfiable")
+)
+
+// parseRange parses RFC 7233 Range header value for the file of given size and returns offset and length of the range.
+// Only single byte range is supported, errRangeInvalid is returned for anything else, so header should be ignored.
+func parseRange(header string, size int64) (offset, length int64, err error) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, errRangeInvalid
+	}
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, errRangeInvalid
+	}
+	if startStr == "" {
+		// suffix range: last N bytes of the file
+		suffix, errP := strconv.ParseInt(endStr, 10, 64)
+		if errP != nil || suffix < 0 {
+			return 0, 0, errRangeInvalid
+		}
+		if suffix == 0 || size == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		suffix = min(suffix, size)
+		return size - suffix, suffix, nil
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, errRangeInvalid
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, errRangeInvalid
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+	return start, end - start + 1, nil
+}
@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRange(t *testing.T) {
+	table := map[string]struct {
+		header string
+		offset int64
+		length int64
+		err    error
+	}{
+		"full range":          {header: "bytes=0-99", offset: 0, length: 100},
+		"middle range":        {header: "bytes=10-19", offset: 10, length: 10},
+		"open range":          {header: "bytes=90-", offset: 90, length: 10},
+		"suffix range":        {header: "bytes=-5", offset: 95, length: 5},
+		"suffix bigger file":  {header: "bytes=-500", offset: 0, length: 100},
+		"end after file":      {header: "bytes=50-500", offset: 50, length: 50},
+		"start after file":    {header: "bytes=100-", err: errRangeNotSatisfiable},
+		"empty suffix":        {header: "bytes=-0", err: errRangeNotSatisfiable},
+		"multiple ranges":     {header: "bytes=0-1,5-6", err: errRangeInvalid},
+		"unknown unit":        {header: "items=0-1", err: errRangeInvalid},
+		"end before start":    {header: "bytes=5-1", err: errRangeInvalid},
+		"not a number":        {header: "bytes=a-b", err: errRangeInvalid},
+		"missing range delim": {header: "bytes=5", err: errRangeInvalid},
+	}
+	for name, tc := range table {
+		t.Run(name, func(t *testing.T) {
+			offset, length, err := parseRange(tc.header, 100)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.offset, offset)
+			require.Equal(t, tc.length, length)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package routes
+
+import (
+	"encoding/xml"
+	"errors"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/receiver"
+	"log/slog"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/google/uuid"
+)
+
+const (
+	s3Namespace  = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3TimeFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// S3Config sets region of S3 API and credentials it accepts: secret key by access key id.
+// Empty Region accepts requests signed for any region.
+type S3Config struct {
+	Region string
+	Keys   map[string]string
+}
+
+// S3Server serves subset of S3 REST API on top of receiver: buckets, PutObject, GetObject, HeadObject,
+// DeleteObject, ListObjectsV2 and multipart uploads. Only path-style requests signed with AWS Signature Version 4 are accepted.
+type S3Server struct {
+	appAddr    string
+	log        logger.AppLogger
+	service    receiver.DataReceiver
+	conf       S3Config
+	httpEngine *fiber.App
+}
+
+// InitS3Router initializes the S3 API Server.
+func InitS3Router(log logger.AppLogger, service receiver.DataReceiver, conf S3Config, address string) *S3Server {
+	app := &S3Server{
+		appAddr: address,
+		httpEngine: fiber.New(fiber.Config{
+			BodyLimit:             bodyBufferSize,
+			StreamRequestBody:     true,
+			DisableStartupMessage: true,
+		}),
+		service: service,
+		conf:    conf,
+		log:     log.With(slog.String("service", "s3")),
+	}
+	app.httpEngine.Use(recover.New())
+	app.initRoutes()
+	return app
+}
+
+func (s *S3Server) initRoutes() {
+	s.httpEngine.Use(func(ctx *fiber.Ctx) error {
+		ctx.Set("x-amz-request-id", uuid.NewString())
+		return ctx.Next()
+	})
+	s.httpEngine.Get("/", s.signed(s.listBuckets))
+	// object key is empty for requests to bucket itself
+	s.httpEngine.Head("/:bucket/*", s.signed(s.route(s.headBucket, s.headObject)))
+	s.httpEngine.Get("/:bucket/*", s.signed(s.route(s.listObjects, s.multipart(s.getObject, s.listParts))))
+	s.httpEngine.Put("/:bucket/*", s.signed(s.route(s.createBucket, s.multipart(s.putObject, s.uploadPart))))
+	s.httpEngine.Delete("/:bucket/*", s.signed(s.route(s.deleteBucket, s.multipart(s.deleteObject, s.abortMultipartUpload))))
+	s.httpEngine.Post("/:bucket/*", s.signed(s.route(s.notImplemented, s.multipart(s.createMultipartUpload, s.completeMultipartUpload))))
+	s.httpEngine.Use(s.notImplemented)
+}
+
+func (s *S3Server) notImplemented(ctx *fiber.Ctx) error {
+	return s.sendError(ctx, errS3NotImplemented)
+}
+
+// route calls bucketHandler for requests without object key and objectHandler otherwise
+func (s *S3Server) route(bucketHandler, objectHandler fiber.Handler) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if ctx.Params("*") == "" {
+			return bucketHandler(ctx)
+		}
+		return objectHandler(ctx)
+	}
+}
+
+// Run starts the S3 API Server.
+func (s *S3Server) Run() error {
+	s.log.Info("Starting S3 server", slog.String("port", s.appAddr))
+	return s.httpEngine.Listen(s.appAddr)
+}
+
+func (s *S3Server) Stop() error {
+	return s.httpEngine.Shutdown()
+}
+
+// objectKey returns unescaped object key of the request, fiber keeps route params escaped
+func objectKey(ctx *fiber.Ctx) (string, error) {
+	key, err := url.PathUnescape(ctx.Params("*"))
+	if err != nil {
+		return "", errS3InvalidURI
+	}
+	return key, nil
+}
+
+// s3Error is an error answered in S3 format
+type s3Error struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *s3Error) Error() string {
+	return e.code + ": " + e.message
+}
+
+var (
+	errS3AccessDenied          = &s3Error{fiber.StatusForbidden, "AccessDenied", "Access Denied"}
+	errS3InvalidAccessKeyID    = &s3Error{fiber.StatusForbidden, "InvalidAccessKeyId", "The AWS access key Id you provided does not exist in our records."}
+	errS3SignatureDoesNotMatch = &s3Error{fiber.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided."}
+	errS3RequestTimeTooSkewed  = &s3Error{fiber.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large."}
+	errS3AuthorizationHeader   = &s3Error{fiber.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization header is malformed."}
+	errS3InvalidURI            = &s3Error{fiber.StatusBadRequest, "InvalidURI", "Couldn't parse the specified URI."}
+	errS3InvalidArgument       = &s3Error{fiber.StatusBadRequest, "InvalidArgument", "Invalid Argument"}
+	errS3InvalidBucketName     = &s3Error{fiber.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid."}
+	errS3ContentSHA256Mismatch = &s3Error{fiber.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed."}
+	errS3NoSuchBucket          = &s3Error{fiber.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist."}
+	errS3NoSuchKey             = &s3Error{fiber.StatusNotFound, "NoSuchKey", "The specified key does not exist."}
+	errS3BucketAlreadyOwned    = &s3Error{fiber.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it."}
+	errS3BucketNotEmpty        = &s3Error{fiber.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty."}
+	errS3OperationAborted      = &s3Error{fiber.StatusConflict, "OperationAborted", "A conflicting operation is currently in progress against this resource."}
+	errS3MissingContentLength  = &s3Error{fiber.StatusLengthRequired, "MissingContentLength", "You must provide the Content-Length HTTP header."}
+	errS3PreconditionFailed    = &s3Error{fiber.StatusPreconditionFailed, "PreconditionFailed", "At least one of the preconditions you specified did not hold."}
+	errS3InvalidRange          = &s3Error{fiber.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable."}
+	errS3Internal              = &s3Error{fiber.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again."}
+	errS3NotImplemented        = &s3Error{fiber.StatusNotImplemented, "NotImplemented", "A header or query you provided implies functionality that is not implemented."}
+)
+
+type s3ErrorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// sendError answers S3 error, receiver errors are mapped to S3 codes and unknown ones are logged as internal
+func (s *S3Server) sendError(ctx *fiber.Ctx, err error) error {
+	var s3Err *s3Error
+	switch {
+	case errors.As(err, &s3Err):
+	case errors.Is(err, receiver.ErrBucketNotFound):
+		s3Err = errS3NoSuchBucket
+	case errors.Is(err, receiver.ErrBucketAlreadyExists):
+		s3Err = errS3BucketAlreadyOwned
+	case errors.Is(err, receiver.ErrBucketNotEmpty):
+		s3Err = errS3BucketNotEmpty
+	case errors.Is(err, receiver.ErrFileAlreadyExists):
+		s3Err = errS3OperationAborted
+	case errors.Is(err, receiver.ErrRangeNotSatisfiable):
+		s3Err = errS3InvalidRange
+	case errors.Is(err, receiver.ErrUploadNotFound):
+		s3Err = errS3NoSuchUpload
+	case errors.Is(err, receiver.ErrInvalidPart):
+		s3Err = errS3InvalidPart
+	default:
+		s.log.Error("error process s3 request", err,
+			slog.String("method", ctx.Method()), slog.String("path", ctx.Path()))
+		s3Err = errS3Internal
+	}
+	ctx.Status(s3Err.status)
+	// HEAD responses have no body, so status is all client gets
+	if ctx.Method() == fiber.MethodHead {
+		return nil
+	}
+	return sendXML(ctx, s3ErrorResponse{
+		Code:      s3Err.code,
+		Message:   s3Err.message,
+		Resource:  ctx.Path(),
+		RequestID: string(ctx.Response().Header.Peek("x-amz-request-id")),
+	})
+}
+
+func sendXML(ctx *fiber.Ctx, body any) error {
+	data, err := xml.Marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationXML)
+	return ctx.Send(append([]byte(xml.Header), data...))
+}
+
+// s3URLEncode escapes key for responses requested with encoding-type=url, slashes are kept
+func s3URLEncode(key string) string {
+	return strings.ReplaceAll(url.QueryEscape(key), "%2F", "/")
+}
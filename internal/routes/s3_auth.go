@@ -0,0 +1,243 @@
+package routes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	sigV4Algorithm     = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat    = "20060102T150405Z"
+	sigV4MaxSkew       = 15 * time.Minute
+	unsignedPayload    = "UNSIGNED-PAYLOAD"
+	headerAmzDate      = "X-Amz-Date"
+	headerAmzContent   = "X-Amz-Content-Sha256"
+	localPayloadSHA256 = "payload_sha256"
+)
+
+// sigV4Auth is a parsed Authorization header of AWS Signature Version 4
+type sigV4Auth struct {
+	accessKey     string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+}
+
+// signed checks request signature before handler, declared SHA-256 of the payload is passed in locals
+func (s *S3Server) signed(handler fiber.Handler) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		payloadHash, err := s.verifySignature(ctx, time.Now())
+		if err != nil {
+			return s.sendError(ctx, err)
+		}
+		ctx.Locals(localPayloadSHA256, payloadHash)
+		return handler(ctx)
+	}
+}
+
+// verifySignature checks AWS Signature Version 4 of the request sent in Authorization header and returns
+// declared SHA-256 of the payload. Presigned URLs and aws-chunked payloads are not supported.
+func (s *S3Server) verifySignature(ctx *fiber.Ctx, now time.Time) (string, error) {
+	header := ctx.Get(fiber.HeaderAuthorization)
+	if header == "" {
+		if ctx.Query("X-Amz-Algorithm") != "" {
+			return "", errS3NotImplemented
+		}
+		return "", errS3AccessDenied
+	}
+	auth, err := parseSigV4Auth(header)
+	if err != nil {
+		return "", err
+	}
+	secret, ok := s.conf.Keys[auth.accessKey]
+	if !ok {
+		return "", errS3InvalidAccessKeyID
+	}
+	if auth.service != "s3" || (s.conf.Region != "" && auth.region != s.conf.Region) {
+		return "", errS3AuthorizationHeader
+	}
+	amzDate := ctx.Get(headerAmzDate)
+	signedAt, err := time.Parse(sigV4TimeFormat, amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, auth.date) {
+		return "", errS3AccessDenied
+	}
+	if skew := now.Sub(signedAt); skew > sigV4MaxSkew || skew < -sigV4MaxSkew {
+		return "", errS3RequestTimeTooSkewed
+	}
+	payloadHash := ctx.Get(headerAmzContent)
+	switch {
+	case payloadHash == "":
+		return "", errS3InvalidArgument
+	case strings.HasPrefix(payloadHash, "STREAMING-"):
+		return "", errS3NotImplemented
+	}
+
+	canonical, err := canonicalRequest(ctx, auth.signedHeaders, payloadHash)
+	if err != nil {
+		return "", err
+	}
+	scope := strings.Join([]string{auth.date, auth.region, auth.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hashHex([]byte(canonical))}, "\n")
+	key := hmacSHA256([]byte("AWS4"+secret), auth.date)
+	for _, part := range []string{auth.region, auth.service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(auth.signature)) {
+		return "", errS3SignatureDoesNotMatch
+	}
+	return payloadHash, nil
+}
+
+// parseSigV4Auth parses "AWS4-HMAC-SHA256 Credential=AK/date/region/service/aws4_request,
+// SignedHeaders=host;x-amz-date, Signature=hex" header
+func parseSigV4Auth(header string) (*sigV4Auth, error) {
+	algorithm, params, found := strings.Cut(header, " ")
+	if algorithm != sigV4Algorithm {
+		return nil, errS3AccessDenied
+	}
+	if !found {
+		return nil, errS3AuthorizationHeader
+	}
+	auth := &sigV4Auth{}
+	var credential string
+	for _, param := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch name {
+		case "Credential":
+			credential = value
+		case "SignedHeaders":
+			auth.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			auth.signature = value
+		}
+	}
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" || auth.signature == "" || len(auth.signedHeaders) == 0 {
+		return nil, errS3AuthorizationHeader
+	}
+	auth.accessKey, auth.date, auth.region, auth.service = scope[0], scope[1], scope[2], scope[3]
+	return auth, nil
+}
+
+// canonicalRequest builds canonical request of SigV4. Path is taken as it is sent,
+// S3 clients encode it once and don't normalize it.
+func canonicalRequest(ctx *fiber.Ctx, signedHeaders []string, payloadHash string) (string, error) {
+	query, err := canonicalQuery(string(ctx.Request().URI().QueryString()))
+	if err != nil {
+		return "", err
+	}
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var value string
+		if name == "host" {
+			value = string(ctx.Request().Host())
+		} else {
+			value = ctx.Get(name)
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	path := string(ctx.Request().URI().PathOriginal())
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	return strings.Join([]string{
+		ctx.Method(),
+		path,
+		query,
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n"), nil
+}
+
+// canonicalQuery sorts query parameters by name and value and encodes them as RFC 3986 requires
+func canonicalQuery(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+	params := strings.Split(raw, "&")
+	for i, param := range params {
+		name, value, _ := strings.Cut(param, "=")
+		name, errN := url.PathUnescape(name)
+		value, errV := url.PathUnescape(value)
+		if errN != nil || errV != nil {
+			return "", errS3InvalidURI
+		}
+		params[i] = uriEncode(name) + "=" + uriEncode(value)
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&"), nil
+}
+
+// uriEncode escapes everything except unreserved characters of RFC 3986
+func uriEncode(value string) string {
+	var result strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			result.WriteByte(c)
+			continue
+		}
+		result.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+	return result.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// payloadVerifier checks SHA-256 of size bytes of the body against signed one. Mismatch is returned
+// instead of the last bytes, so reader which stops right at the size still gets the error.
+type payloadVerifier struct {
+	body     io.Reader
+	hash     hash.Hash
+	expected string
+	left     int64
+}
+
+func newPayloadVerifier(body io.Reader, size int64, expected string) *payloadVerifier {
+	return &payloadVerifier{body: body, hash: sha256.New(), expected: expected, left: size}
+}
+
+func (v *payloadVerifier) Read(p []byte) (int, error) {
+	if v.left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > v.left {
+		p = p[:v.left]
+	}
+	n, err := v.body.Read(p)
+	v.hash.Write(p[:n])
+	v.left -= int64(n)
+	if v.left > 0 {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+	if hex.EncodeToString(v.hash.Sum(nil)) != v.expected {
+		return 0, errS3ContentSHA256Mismatch
+	}
+	return n, nil
+}
@@ -0,0 +1,207 @@
+package routes
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"extendable_storage/internal/entities"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	s3MaxKeys      = 1000
+	s3ListBatch    = 1000
+	s3OwnerID      = "extendable_storage"
+	s3StorageClass = "STANDARD"
+)
+
+var bucketNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+type s3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type s3Bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type s3ListBucketsResult struct {
+	XMLName xml.Name   `xml:"ListAllMyBucketsResult"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Owner   s3Owner    `xml:"Owner"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3ListObjectsV2Result struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	Xmlns                 string           `xml:"xmlns,attr"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	EncodingType          string           `xml:"EncodingType,omitempty"`
+	KeyCount              int              `xml:"KeyCount"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	Contents              []s3Object       `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+func (s *S3Server) listBuckets(ctx *fiber.Ctx) error {
+	buckets, err := s.service.ListBuckets(ctx.UserContext())
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	result := s3ListBucketsResult{Xmlns: s3Namespace, Owner: s3Owner{ID: s3OwnerID, DisplayName: s3OwnerID}}
+	for _, bucket := range buckets {
+		result.Buckets = append(result.Buckets, s3Bucket{
+			Name:         bucket.Name,
+			CreationDate: bucket.CreatedAt.UTC().Format(s3TimeFormat),
+		})
+	}
+	return sendXML(ctx, result)
+}
+
+// createBucket creates bucket, location constraint in the body is ignored
+func (s *S3Server) createBucket(ctx *fiber.Ctx) error {
+	name := ctx.Params("bucket")
+	if !bucketNameRe.MatchString(name) || strings.Contains(name, "..") {
+		return s.sendError(ctx, errS3InvalidBucketName)
+	}
+	if err := s.service.CreateBucket(ctx.UserContext(), name); err != nil {
+		return s.sendError(ctx, err)
+	}
+	ctx.Set(fiber.HeaderLocation, "/"+name)
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func (s *S3Server) headBucket(ctx *fiber.Ctx) error {
+	if _, err := s.service.GetBucket(ctx.UserContext(), ctx.Params("bucket")); err != nil {
+		return s.sendError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func (s *S3Server) deleteBucket(ctx *fiber.Ctx) error {
+	if err := s.service.DeleteBucket(ctx.UserContext(), ctx.Params("bucket")); err != nil {
+		return s.sendError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// listObjects serves ListObjectsV2, keys sharing part of the key after prefix up to delimiter are rolled up
+// into common prefix. Continuation token is the last key of the page.
+func (s *S3Server) listObjects(ctx *fiber.Ctx) error {
+	if ctx.Query("list-type") != "2" {
+		return s.sendError(ctx, errS3NotImplemented)
+	}
+	bucket := ctx.Params("bucket")
+	if _, err := s.service.GetBucket(ctx.UserContext(), bucket); err != nil {
+		return s.sendError(ctx, err)
+	}
+	result := s3ListObjectsV2Result{
+		Xmlns:             s3Namespace,
+		Name:              bucket,
+		Prefix:            ctx.Query("prefix"),
+		Delimiter:         ctx.Query("delimiter"),
+		StartAfter:        ctx.Query("start-after"),
+		ContinuationToken: ctx.Query("continuation-token"),
+		EncodingType:      ctx.Query("encoding-type"),
+		MaxKeys:           s3MaxKeys,
+	}
+	if maxKeys := ctx.Query("max-keys"); maxKeys != "" {
+		value, err := strconv.Atoi(maxKeys)
+		if err != nil || value < 0 {
+			return s.sendError(ctx, errS3InvalidArgument)
+		}
+		result.MaxKeys = min(value, s3MaxKeys)
+	}
+	if result.EncodingType != "" && result.EncodingType != "url" {
+		return s.sendError(ctx, errS3InvalidArgument)
+	}
+	after := result.StartAfter
+	if result.ContinuationToken != "" {
+		token, err := base64.RawURLEncoding.DecodeString(result.ContinuationToken)
+		if err != nil {
+			return s.sendError(ctx, errS3InvalidArgument)
+		}
+		after = string(token)
+	}
+
+	lastPrefix := ""
+	for done := result.MaxKeys == 0; !done; {
+		startAfter := ""
+		if after != "" {
+			startAfter = entities.ObjectFileID(bucket, after)
+		}
+		files, err := s.service.ListFiles(ctx.UserContext(), entities.ObjectFileID(bucket, result.Prefix), startAfter, s3ListBatch)
+		if err != nil {
+			return s.sendError(ctx, err)
+		}
+		done = len(files) < s3ListBatch
+		for _, file := range files {
+			key := strings.TrimPrefix(file.ID, bucket+"/")
+			commonPrefix := ""
+			if result.Delimiter != "" {
+				if i := strings.Index(key[len(result.Prefix):], result.Delimiter); i >= 0 {
+					commonPrefix = key[:len(result.Prefix)+i+len(result.Delimiter)]
+				}
+			}
+			// the rest of keys of the last common prefix are skipped, so page never ends inside common prefix
+			if commonPrefix == "" || commonPrefix != lastPrefix {
+				if result.KeyCount == result.MaxKeys {
+					result.IsTruncated, done = true, true
+					result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(after))
+					break
+				}
+				result.KeyCount++
+				if commonPrefix != "" {
+					lastPrefix = commonPrefix
+					result.CommonPrefixes = append(result.CommonPrefixes, s3CommonPrefix{Prefix: result.encode(commonPrefix)})
+				} else {
+					result.Contents = append(result.Contents, s3Object{
+						Key:          result.encode(key),
+						LastModified: file.UpdatedAt.UTC().Format(s3TimeFormat),
+						ETag:         s3ETag(file),
+						Size:         file.Size(),
+						StorageClass: s3StorageClass,
+					})
+				}
+			}
+			after = key
+		}
+	}
+	if result.EncodingType != "" {
+		result.Prefix = result.encode(result.Prefix)
+		result.Delimiter = result.encode(result.Delimiter)
+		result.StartAfter = result.encode(result.StartAfter)
+	}
+	return sendXML(ctx, result)
+}
+
+// encode escapes value if client asked for url encoding type
+func (r *s3ListObjectsV2Result) encode(value string) string {
+	if r.EncodingType == "" {
+		return value
+	}
+	return s3URLEncode(value)
+}
@@ -0,0 +1,277 @@
+package routes
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/receiver"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	s3MaxParts = 1000
+)
+
+var (
+	errS3NoSuchUpload = &s3Error{fiber.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist."}
+	errS3InvalidPart  = &s3Error{fiber.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found."}
+	errS3MalformedXML = &s3Error{fiber.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed."}
+)
+
+type s3InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type s3Part struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified,omitempty"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size,omitempty"`
+}
+
+type s3ListPartsResult struct {
+	XMLName              xml.Name `xml:"ListPartsResult"`
+	Xmlns                string   `xml:"xmlns,attr"`
+	Bucket               string   `xml:"Bucket"`
+	Key                  string   `xml:"Key"`
+	UploadID             string   `xml:"UploadId"`
+	PartNumberMarker     int      `xml:"PartNumberMarker"`
+	NextPartNumberMarker int      `xml:"NextPartNumberMarker"`
+	MaxParts             int      `xml:"MaxParts"`
+	IsTruncated          bool     `xml:"IsTruncated"`
+	Parts                []s3Part `xml:"Part"`
+}
+
+type s3CompleteMultipartUpload struct {
+	Parts []s3Part `xml:"Part"`
+}
+
+type s3CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// multipart calls uploadHandler for requests to multipart upload (with uploadId) and objectHandler otherwise
+func (s *S3Server) multipart(objectHandler, uploadHandler fiber.Handler) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if ctx.Query("uploadId") != "" {
+			return uploadHandler(ctx)
+		}
+		return objectHandler(ctx)
+	}
+}
+
+func (s *S3Server) createMultipartUpload(ctx *fiber.Ctx) error {
+	if !ctx.Context().QueryArgs().Has("uploads") {
+		return s.sendError(ctx, errS3NotImplemented)
+	}
+	bucket := ctx.Params("bucket")
+	key, err := objectKey(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	if _, err = s.service.GetBucket(ctx.UserContext(), bucket); err != nil {
+		return s.sendError(ctx, err)
+	}
+	opts := receiver.SaveOptions{ContentType: ctx.Get(fiber.HeaderContentType)}
+	uploadID, err := s.service.InitiateUpload(ctx.UserContext(), entities.ObjectFileID(bucket, key), opts)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	return sendXML(ctx, s3InitiateMultipartUploadResult{Xmlns: s3Namespace, Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (s *S3Server) uploadPart(ctx *fiber.Ctx) error {
+	if ctx.Get("X-Amz-Copy-Source") != "" {
+		return s.sendError(ctx, errS3NotImplemented)
+	}
+	number, err := strconv.Atoi(ctx.Query("partNumber"))
+	if err != nil {
+		return s.sendError(ctx, errS3InvalidArgument)
+	}
+	upload, err := s.objectUpload(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	size := ctx.Request().Header.ContentLength()
+	if size < 0 {
+		return s.sendError(ctx, errS3MissingContentLength)
+	}
+	body := ctx.Context().RequestBodyStream()
+	if payloadHash, _ := ctx.Locals(localPayloadSHA256).(string); payloadHash != unsignedPayload {
+		body = newPayloadVerifier(body, int64(size), payloadHash)
+	}
+	part, err := s.service.SavePart(ctx.UserContext(), upload.ID, number, body, int64(size))
+	if errors.Is(err, receiver.ErrInvalidPart) {
+		return s.sendError(ctx, errS3InvalidArgument)
+	}
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	ctx.Set(fiber.HeaderETag, `"`+part.MD5+`"`)
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+// listParts lists uploaded parts after part-number-marker, up to max-parts at once
+func (s *S3Server) listParts(ctx *fiber.Ctx) error {
+	upload, err := s.objectUpload(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	result := s3ListPartsResult{
+		Xmlns:    s3Namespace,
+		Bucket:   ctx.Params("bucket"),
+		UploadID: upload.ID,
+		MaxParts: s3MaxParts,
+	}
+	result.Key, _ = objectKey(ctx)
+	if maxParts := ctx.Query("max-parts"); maxParts != "" {
+		value, errA := strconv.Atoi(maxParts)
+		if errA != nil || value < 0 {
+			return s.sendError(ctx, errS3InvalidArgument)
+		}
+		result.MaxParts = min(value, s3MaxParts)
+	}
+	if marker := ctx.Query("part-number-marker"); marker != "" {
+		if result.PartNumberMarker, err = strconv.Atoi(marker); err != nil {
+			return s.sendError(ctx, errS3InvalidArgument)
+		}
+	}
+	parts, err := s.service.ListParts(ctx.UserContext(), upload.ID)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	for _, part := range parts {
+		if part.Number <= result.PartNumberMarker {
+			continue
+		}
+		if len(result.Parts) == result.MaxParts {
+			result.IsTruncated = true
+			break
+		}
+		result.Parts = append(result.Parts, s3Part{
+			PartNumber:   part.Number,
+			LastModified: part.UpdatedAt.UTC().Format(s3TimeFormat),
+			ETag:         `"` + part.MD5 + `"`,
+			Size:         part.Size,
+		})
+		result.NextPartNumberMarker = part.Number
+	}
+	return sendXML(ctx, result)
+}
+
+// completeMultipartUpload creates object from listed parts, ETag of every part must match the uploaded one.
+// Existing object is deleted before, as PutObject does.
+func (s *S3Server) completeMultipartUpload(ctx *fiber.Ctx) error {
+	upload, err := s.objectUpload(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	body, err := signedBody(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	var req s3CompleteMultipartUpload
+	if err = xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		return s.sendError(ctx, errS3MalformedXML)
+	}
+	parts, err := s.service.ListParts(ctx.UserContext(), upload.ID)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	uploaded := make(map[int]string, len(parts))
+	for _, part := range parts {
+		uploaded[part.Number] = part.MD5
+	}
+	numbers := make([]int, 0, len(req.Parts))
+	for _, part := range req.Parts {
+		if md5Sum, ok := uploaded[part.PartNumber]; !ok || strings.Trim(part.ETag, `"`) != md5Sum {
+			return s.sendError(ctx, errS3InvalidPart)
+		}
+		numbers = append(numbers, part.PartNumber)
+	}
+	if !sort.IntsAreSorted(numbers) {
+		return s.sendError(ctx, &s3Error{fiber.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order."})
+	}
+
+	existing, err := s.service.GetFileInfo(ctx.UserContext(), upload.FileID)
+	switch {
+	case err == nil && existing.Status == entities.FileStatusComplete:
+		if err = s.service.DeleteFile(ctx.UserContext(), upload.FileID); err != nil {
+			return s.sendError(ctx, err)
+		}
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return s.sendError(ctx, err)
+	}
+	if err = s.service.CompleteUpload(ctx.UserContext(), upload.ID, numbers); err != nil {
+		return s.sendError(ctx, err)
+	}
+	file, err := s.service.GetFileInfo(ctx.UserContext(), upload.FileID)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	key, _ := objectKey(ctx)
+	return sendXML(ctx, s3CompleteMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: ctx.BaseURL() + string(ctx.Request().URI().PathOriginal()),
+		Bucket:   ctx.Params("bucket"),
+		Key:      key,
+		ETag:     s3ETag(file),
+	})
+}
+
+func (s *S3Server) abortMultipartUpload(ctx *fiber.Ctx) error {
+	upload, err := s.objectUpload(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	if err = s.service.AbortUpload(ctx.UserContext(), upload.ID); err != nil {
+		return s.sendError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// objectUpload returns upload of uploadId which uploads requested object, NoSuchUpload otherwise
+func (s *S3Server) objectUpload(ctx *fiber.Ctx) (*entities.Upload, error) {
+	bucket := ctx.Params("bucket")
+	key, err := objectKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := s.service.GetUpload(ctx.UserContext(), ctx.Query("uploadId"))
+	if errors.Is(err, receiver.ErrUploadNotFound) {
+		if _, errB := s.service.GetBucket(ctx.UserContext(), bucket); errB != nil {
+			return nil, errB
+		}
+		return nil, errS3NoSuchUpload
+	}
+	if err != nil {
+		return nil, err
+	}
+	if upload.FileID != entities.ObjectFileID(bucket, key) || upload.Status != entities.FileStatusNew {
+		return nil, errS3NoSuchUpload
+	}
+	return upload, nil
+}
+
+// signedBody returns request body checked against signed payload hash
+func signedBody(ctx *fiber.Ctx) ([]byte, error) {
+	body := ctx.Body()
+	if payloadHash, _ := ctx.Locals(localPayloadSHA256).(string); payloadHash != unsignedPayload && hashHex(body) != payloadHash {
+		return nil, errS3ContentSHA256Mismatch
+	}
+	return body, nil
+}
@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"database/sql"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/receiver"
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// putObject stores object as file with id "bucket/key". Existing object is replaced by ReplaceFileStream: new one
+// is saved aside and swapped with it at once, so the old object is served until then and kept if saving fails.
+func (s *S3Server) putObject(ctx *fiber.Ctx) error {
+	if ctx.Get("X-Amz-Copy-Source") != "" {
+		return s.sendError(ctx, errS3NotImplemented)
+	}
+	bucket := ctx.Params("bucket")
+	key, err := objectKey(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	if _, err = s.service.GetBucket(ctx.UserContext(), bucket); err != nil {
+		return s.sendError(ctx, err)
+	}
+	size := ctx.Request().Header.ContentLength()
+	if size < 0 {
+		return s.sendError(ctx, errS3MissingContentLength)
+	}
+	fileID := entities.ObjectFileID(bucket, key)
+	save := s.service.SaveFileStream
+	existing, err := s.service.GetFileInfo(ctx.UserContext(), fileID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return s.sendError(ctx, err)
+	case ctx.Get(fiber.HeaderIfNoneMatch) == "*":
+		return s.sendError(ctx, errS3PreconditionFailed)
+	case existing.Status == entities.FileStatusComplete:
+		// old object is served until the new one is saved, unfinished upload is left as it is and save fails
+		save = s.service.ReplaceFileStream
+	}
+
+	body := ctx.Context().RequestBodyStream()
+	if payloadHash, _ := ctx.Locals(localPayloadSHA256).(string); payloadHash != unsignedPayload {
+		body = newPayloadVerifier(body, int64(size), payloadHash)
+	}
+	opts := receiver.SaveOptions{ContentType: ctx.Get(fiber.HeaderContentType)}
+	if err = save(ctx.UserContext(), fileID, body, int64(size), opts); err != nil {
+		return s.sendError(ctx, err)
+	}
+	file, err := s.service.GetFileInfo(ctx.UserContext(), fileID)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	if etag := s3ETag(file); etag != "" {
+		ctx.Set(fiber.HeaderETag, etag)
+	}
+	return ctx.SendStatus(fiber.StatusOK)
+}
+
+func (s *S3Server) headObject(ctx *fiber.Ctx) error {
+	file, err := s.objectInfo(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	if status := s.setObjectHeaders(ctx, file); status != 0 {
+		return ctx.SendStatus(status)
+	}
+	ctx.Response().Header.SetContentLength(int(file.Size()))
+	return nil
+}
+
+// getObject serves object, single byte range is supported and invalid Range header is ignored
+func (s *S3Server) getObject(ctx *fiber.Ctx) error {
+	file, err := s.objectInfo(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	if status := s.setObjectHeaders(ctx, file); status == fiber.StatusPreconditionFailed {
+		return s.sendError(ctx, errS3PreconditionFailed)
+	} else if status != 0 {
+		return ctx.SendStatus(status)
+	}
+	size := file.Size()
+	if rangeHeader := ctx.Get(fiber.HeaderRange); rangeHeader != "" && size >= 0 {
+		offset, length, errR := parseRange(rangeHeader, size)
+		switch {
+		case errors.Is(errR, errRangeNotSatisfiable):
+			return s.sendError(ctx, errS3InvalidRange)
+		case errR == nil:
+			data, errG := s.service.GetFileRange(ctx.UserContext(), file.ID, offset, length)
+			if errG != nil {
+				return s.sendError(ctx, errG)
+			}
+			ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+			ctx.Status(fiber.StatusPartialContent)
+			return ctx.SendStream(data, int(length))
+		}
+	}
+	data, size, err := s.service.GetFileStream(ctx.UserContext(), file.ID)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	if size < 0 {
+		return ctx.SendStream(data)
+	}
+	return ctx.SendStream(data, int(size))
+}
+
+// deleteObject deletes object, missing key is not an error as in S3
+func (s *S3Server) deleteObject(ctx *fiber.Ctx) error {
+	bucket := ctx.Params("bucket")
+	key, err := objectKey(ctx)
+	if err != nil {
+		return s.sendError(ctx, err)
+	}
+	if _, err = s.service.GetBucket(ctx.UserContext(), bucket); err != nil {
+		return s.sendError(ctx, err)
+	}
+	err = s.service.DeleteFile(ctx.UserContext(), entities.ObjectFileID(bucket, key))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return s.sendError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// objectInfo returns completed file of the requested object, NoSuchKey or NoSuchBucket otherwise
+func (s *S3Server) objectInfo(ctx *fiber.Ctx) (*entities.File, error) {
+	bucket := ctx.Params("bucket")
+	key, err := objectKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	file, err := s.service.GetFileInfo(ctx.UserContext(), entities.ObjectFileID(bucket, key))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil && file.Status == entities.FileStatusComplete {
+		return file, nil
+	}
+	if _, err = s.service.GetBucket(ctx.UserContext(), bucket); err != nil {
+		return nil, err
+	}
+	return nil, errS3NoSuchKey
+}
+
+// setObjectHeaders sets metadata headers of the object and checks If-Match and If-None-Match against its ETag.
+// Returns status to respond with, zero means object should be served.
+func (s *S3Server) setObjectHeaders(ctx *fiber.Ctx, file *entities.File) int {
+	etag := s3ETag(file)
+	if etag != "" {
+		ctx.Set(fiber.HeaderETag, etag)
+	}
+	ctx.Set(fiber.HeaderLastModified, file.UpdatedAt.UTC().Format(http.TimeFormat))
+	ctx.Set(fiber.HeaderAcceptRanges, "bytes")
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = fiber.MIMEOctetStream
+	}
+	ctx.Set(fiber.HeaderContentType, contentType)
+	if header := ctx.Get(fiber.HeaderIfMatch); header != "" && !etagMatches(header, etag, false) {
+		return fiber.StatusPreconditionFailed
+	}
+	if header := ctx.Get(fiber.HeaderIfNoneMatch); header != "" && etagMatches(header, etag, true) {
+		return fiber.StatusNotModified
+	}
+	return 0
+}
+
+// s3ETag returns quoted MD5 of the object as S3 clients expect it, empty if it is unknown
+func s3ETag(file *entities.File) string {
+	if file.MD5 == "" {
+		return ""
+	}
+	return `"` + file.MD5 + `"`
+}
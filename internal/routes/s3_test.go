@@ -0,0 +1,585 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec // S3 ETag is MD5 of the object
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/receiver"
+	"extendable_storage/internal/utils"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-east-1"
+)
+
+func TestS3Server(t *testing.T) {
+	// given
+	mck := gomock.NewController(t)
+	service := receiver.NewMockDataReceiver(mck)
+	newS3Backend(service)
+	newS3UploadsBackend(service)
+	srv := InitS3Router(logger.NewAppSLogger("test"), service,
+		S3Config{Region: testRegion, Keys: map[string]string{testAccessKey: testSecretKey}}, ":0")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = srv.httpEngine.Listener(ln)
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, srv.Stop())
+	})
+	client := newS3Client(ln.Addr().String(), testSecretKey)
+	ctx := context.Background()
+	payload := []byte("some object content")
+	payloadMD5 := md5.Sum(payload) //nolint:gosec // S3 ETag is MD5 of the object
+	etag := `"` + hex.EncodeToString(payloadMD5[:]) + `"`
+
+	t.Run("should create and list buckets", func(t *testing.T) {
+		// when
+		_, err := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("photos")})
+		require.NoError(t, err)
+		_, errAgain := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("photos")})
+		_, errName := client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String("Bad_Name")})
+		_, errHead := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String("photos")})
+		list, errList := client.ListBuckets(ctx, &s3.ListBucketsInput{})
+
+		// then
+		require.Equal(t, "BucketAlreadyOwnedByYou", errorCode(errAgain))
+		require.Equal(t, "InvalidBucketName", errorCode(errName))
+		require.NoError(t, errHead)
+		require.NoError(t, errList)
+		require.Len(t, list.Buckets, 1)
+		require.Equal(t, "photos", aws.ToString(list.Buckets[0].Name))
+	})
+	t.Run("should put, head and get object", func(t *testing.T) {
+		// when
+		put, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String("photos"),
+			Key:         aws.String("2024/summer trip/a+b.txt"),
+			Body:        bytes.NewReader(payload),
+			ContentType: aws.String("text/plain"),
+		})
+		require.NoError(t, err)
+		head, errHead := client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String("photos"), Key: aws.String("2024/summer trip/a+b.txt"),
+		})
+		get, errGet := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String("photos"), Key: aws.String("2024/summer trip/a+b.txt"),
+		})
+
+		// then
+		require.Equal(t, etag, aws.ToString(put.ETag))
+		require.NoError(t, errHead)
+		require.Equal(t, int64(len(payload)), aws.ToInt64(head.ContentLength))
+		require.Equal(t, etag, aws.ToString(head.ETag))
+		require.Equal(t, "text/plain", aws.ToString(head.ContentType))
+		require.NoError(t, errGet)
+		received, err := io.ReadAll(get.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload, received)
+	})
+	t.Run("should get object range", func(t *testing.T) {
+		// when
+		get, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String("photos"), Key: aws.String("2024/summer trip/a+b.txt"), Range: aws.String("bytes=5-10"),
+		})
+
+		// then
+		require.NoError(t, err)
+		received, err := io.ReadAll(get.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload[5:11], received)
+		require.Equal(t, fmt.Sprintf("bytes 5-10/%d", len(payload)), aws.ToString(get.ContentRange))
+	})
+	t.Run("should overwrite object", func(t *testing.T) {
+		// given
+		newPayload := []byte("new content")
+
+		// when
+		_, err := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String("photos"), Key: aws.String("2024/summer trip/a+b.txt"), Body: bytes.NewReader(newPayload),
+		})
+		require.NoError(t, err)
+		get, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String("photos"), Key: aws.String("2024/summer trip/a+b.txt"),
+		})
+
+		// then
+		require.NoError(t, err)
+		received, err := io.ReadAll(get.Body)
+		require.NoError(t, err)
+		require.Equal(t, newPayload, received)
+	})
+	t.Run("failed overwrite should keep object", func(t *testing.T) {
+		// given
+		// body is changed after request is signed, so it doesn't match declared payload hash
+		tamper := func(stack *middleware.Stack) error {
+			return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("tamper",
+				func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (
+					middleware.FinalizeOutput, middleware.Metadata, error) {
+					req, _ := in.Request.(*smithyhttp.Request)
+					tampered, err := req.SetStream(bytes.NewReader([]byte("bad content")))
+					if err != nil {
+						return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+					}
+					in.Request = tampered
+					return next.HandleFinalize(ctx, in)
+				}), middleware.After)
+		}
+
+		// when
+		_, errPut := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String("photos"), Key: aws.String("2024/summer trip/a+b.txt"), Body: bytes.NewReader([]byte("new content")),
+		}, s3.WithAPIOptions(tamper))
+		get, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String("photos"), Key: aws.String("2024/summer trip/a+b.txt"),
+		})
+
+		// then
+		require.Equal(t, "XAmzContentSHA256Mismatch", errorCode(errPut))
+		require.NoError(t, err)
+		received, err := io.ReadAll(get.Body)
+		require.NoError(t, err)
+		require.Equal(t, []byte("new content"), received)
+	})
+	t.Run("should answer missing objects and buckets", func(t *testing.T) {
+		// when
+		_, errKey := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("photos"), Key: aws.String("missing")})
+		_, errHead := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("photos"), Key: aws.String("missing")})
+		_, errBucket := client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String("videos"), Key: aws.String("a"), Body: bytes.NewReader(payload),
+		})
+
+		// then
+		var noSuchKey *types.NoSuchKey
+		require.ErrorAs(t, errKey, &noSuchKey)
+		var notFound *types.NotFound
+		require.ErrorAs(t, errHead, &notFound)
+		require.Equal(t, "NoSuchBucket", errorCode(errBucket))
+	})
+	t.Run("should list objects with delimiter and pages", func(t *testing.T) {
+		// given
+		for _, key := range []string{"2024/autumn/c.txt", "2024/autumn/d.txt", "2024/e.txt", "2025/f.txt", "root.txt"} {
+			_, err := client.PutObject(ctx, &s3.PutObjectInput{
+				Bucket: aws.String("photos"), Key: aws.String(key), Body: bytes.NewReader(payload),
+			})
+			require.NoError(t, err)
+		}
+
+		// when
+		prefixed, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket: aws.String("photos"), Prefix: aws.String("2024/"), Delimiter: aws.String("/"),
+		})
+		require.NoError(t, err)
+		var pages [][]string
+		paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+			Bucket: aws.String("photos"), Delimiter: aws.String("/"), MaxKeys: aws.Int32(2),
+		})
+		for paginator.HasMorePages() {
+			page, errP := paginator.NextPage(ctx)
+			require.NoError(t, errP)
+			var names []string
+			for _, prefix := range page.CommonPrefixes {
+				names = append(names, aws.ToString(prefix.Prefix))
+			}
+			for _, object := range page.Contents {
+				names = append(names, aws.ToString(object.Key))
+			}
+			pages = append(pages, names)
+		}
+
+		// then
+		require.Equal(t, []string{"2024/e.txt"}, objectKeys(prefixed.Contents))
+		require.Len(t, prefixed.CommonPrefixes, 2)
+		require.Equal(t, "2024/autumn/", aws.ToString(prefixed.CommonPrefixes[0].Prefix))
+		require.Equal(t, "2024/summer trip/", aws.ToString(prefixed.CommonPrefixes[1].Prefix))
+		require.Equal(t, etag, aws.ToString(prefixed.Contents[0].ETag))
+		require.Equal(t, [][]string{{"2024/", "2025/"}, {"root.txt"}}, pages)
+	})
+	t.Run("should upload object in parts", func(t *testing.T) {
+		// given
+		partData := [][]byte{bytes.Repeat([]byte("a"), 100), bytes.Repeat([]byte("b"), 50)}
+		created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String("photos"), Key: aws.String("big.bin"), ContentType: aws.String("video/mp4"),
+		})
+		require.NoError(t, err)
+
+		// when
+		var completed []types.CompletedPart
+		for i, data := range partData {
+			part, errU := client.UploadPart(ctx, &s3.UploadPartInput{
+				Bucket: aws.String("photos"), Key: aws.String("big.bin"), UploadId: created.UploadId,
+				PartNumber: aws.Int32(int32(i + 1)), Body: bytes.NewReader(data),
+			})
+			require.NoError(t, errU)
+			completed = append(completed, types.CompletedPart{ETag: part.ETag, PartNumber: aws.Int32(int32(i + 1))})
+		}
+		parts, errList := client.ListParts(ctx, &s3.ListPartsInput{
+			Bucket: aws.String("photos"), Key: aws.String("big.bin"), UploadId: created.UploadId,
+		})
+		_, errWrongETag := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket: aws.String("photos"), Key: aws.String("big.bin"), UploadId: created.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: []types.CompletedPart{{ETag: aws.String(`"x"`), PartNumber: aws.Int32(1)}}},
+		})
+		complete, errComplete := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket: aws.String("photos"), Key: aws.String("big.bin"), UploadId: created.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+		})
+		get, errGet := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("photos"), Key: aws.String("big.bin")})
+
+		// then
+		require.NoError(t, errList)
+		require.Len(t, parts.Parts, 2)
+		require.Equal(t, int64(50), aws.ToInt64(parts.Parts[1].Size))
+		require.Equal(t, "InvalidPart", errorCode(errWrongETag))
+		require.NoError(t, errComplete)
+		require.Equal(t, `"multipart-2"`, aws.ToString(complete.ETag))
+		require.NoError(t, errGet)
+		received, err := io.ReadAll(get.Body)
+		require.NoError(t, err)
+		require.Equal(t, bytes.Join(partData, nil), received)
+		require.Equal(t, "video/mp4", aws.ToString(get.ContentType))
+	})
+	t.Run("should abort multipart upload", func(t *testing.T) {
+		// given
+		created, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String("photos"), Key: aws.String("aborted.bin"),
+		})
+		require.NoError(t, err)
+
+		// when
+		_, errAbort := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+			Bucket: aws.String("photos"), Key: aws.String("aborted.bin"), UploadId: created.UploadId,
+		})
+		_, errUpload := client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket: aws.String("photos"), Key: aws.String("aborted.bin"), UploadId: created.UploadId,
+			PartNumber: aws.Int32(1), Body: bytes.NewReader(payload),
+		})
+
+		// then
+		require.NoError(t, errAbort)
+		require.Equal(t, "NoSuchUpload", errorCode(errUpload))
+	})
+	t.Run("should delete objects and bucket", func(t *testing.T) {
+		// when
+		_, errNotEmpty := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String("photos")})
+		list, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("photos")})
+		require.NoError(t, err)
+		for _, object := range list.Contents {
+			_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("photos"), Key: object.Key})
+			require.NoError(t, err)
+		}
+		_, errMissing := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("photos"), Key: aws.String("missing")})
+		_, errDelete := client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String("photos")})
+		_, errHead := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String("photos")})
+
+		// then
+		require.Equal(t, "BucketNotEmpty", errorCode(errNotEmpty))
+		require.Len(t, list.Contents, 7)
+		require.NoError(t, errMissing)
+		require.NoError(t, errDelete)
+		var notFound *types.NotFound
+		require.ErrorAs(t, errHead, &notFound)
+	})
+	t.Run("should reject request with wrong signature", func(t *testing.T) {
+		// given
+		wrongClient := newS3Client(ln.Addr().String(), "wrong secret")
+
+		// when
+		_, err := wrongClient.ListBuckets(ctx, &s3.ListBucketsInput{})
+
+		// then
+		require.Equal(t, "SignatureDoesNotMatch", errorCode(err))
+	})
+	t.Run("should reject unsigned request", func(t *testing.T) {
+		// when
+		resp, err := http.Get("http://" + ln.Addr().String() + "/")
+
+		// then
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+}
+
+func TestPayloadVerifier(t *testing.T) {
+	// given
+	payload := []byte("some object content")
+
+	t.Run("should pass payload with signed hash", func(t *testing.T) {
+		// when
+		received := make([]byte, len(payload))
+		_, err := io.ReadFull(newPayloadVerifier(bytes.NewReader(payload), int64(len(payload)), utils.HashData(payload)), received)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, payload, received)
+	})
+	t.Run("should fail last read of tampered payload", func(t *testing.T) {
+		// when
+		received := make([]byte, len(payload))
+		_, err := io.ReadFull(newPayloadVerifier(bytes.NewReader(payload), int64(len(payload)), utils.HashData([]byte("other"))), received)
+
+		// then
+		require.ErrorIs(t, err, errS3ContentSHA256Mismatch)
+	})
+}
+
+func newS3Client(address, secret string) *s3.Client {
+	return s3.New(s3.Options{
+		Region:       testRegion,
+		BaseEndpoint: aws.String("http://" + address),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(testAccessKey, secret, ""),
+		Retryer:      aws.NopRetryer{},
+	})
+}
+
+// newS3Backend makes service keep buckets and files in memory
+func newS3Backend(service *receiver.MockDataReceiver) {
+	var (
+		mu      sync.Mutex
+		buckets = make(map[string]*entities.Bucket)
+		files   = make(map[string]*entities.File)
+		content = make(map[string][]byte)
+	)
+	service.EXPECT().CreateBucket(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, name string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if _, ok := buckets[name]; ok {
+				return receiver.ErrBucketAlreadyExists
+			}
+			buckets[name] = &entities.Bucket{Name: name, CreatedAt: time.Now()}
+			return nil
+		})
+	service.EXPECT().GetBucket(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, name string) (*entities.Bucket, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if bucket, ok := buckets[name]; ok {
+				return bucket, nil
+			}
+			return nil, receiver.ErrBucketNotFound
+		})
+	service.EXPECT().ListBuckets(gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context) ([]*entities.Bucket, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			result := make([]*entities.Bucket, 0, len(buckets))
+			for _, bucket := range buckets {
+				result = append(result, bucket)
+			}
+			return result, nil
+		})
+	service.EXPECT().DeleteBucket(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, name string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for id := range files {
+				if strings.HasPrefix(id, name+"/") {
+					return receiver.ErrBucketNotEmpty
+				}
+			}
+			delete(buckets, name)
+			return nil
+		})
+	// files are untouched until the whole content is read, like new version is saved aside by receiver
+	saveFile := func(replace bool) func(context.Context, string, io.Reader, int64, receiver.SaveOptions) error {
+		return func(_ context.Context, fileID string, data io.Reader, size int64, opts receiver.SaveOptions) error {
+			received := make([]byte, size)
+			if _, err := io.ReadFull(data, received); err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if file, ok := files[fileID]; ok && (!replace || file.Status != entities.FileStatusComplete) {
+				return receiver.ErrFileAlreadyExists
+			}
+			sum := md5.Sum(received) //nolint:gosec // S3 ETag is MD5 of the object
+			files[fileID] = &entities.File{
+				ID: fileID, Status: entities.FileStatusComplete, UpdatedAt: time.Now(),
+				Length: size, ContentType: opts.ContentType, MD5: hex.EncodeToString(sum[:]),
+			}
+			content[fileID] = received
+			return nil
+		}
+	}
+	service.EXPECT().SaveFileStream(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(saveFile(false))
+	service.EXPECT().ReplaceFileStream(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(saveFile(true))
+	service.EXPECT().GetFileInfo(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, fileID string) (*entities.File, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if file, ok := files[fileID]; ok {
+				return file, nil
+			}
+			return nil, fmt.Errorf("error get file: %w", sql.ErrNoRows)
+		})
+	service.EXPECT().GetFileStream(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, fileID string) (io.ReadCloser, int64, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return io.NopCloser(bytes.NewReader(content[fileID])), int64(len(content[fileID])), nil
+		})
+	service.EXPECT().GetFileRange(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, fileID string, offset, length int64) (io.ReadCloser, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return io.NopCloser(bytes.NewReader(content[fileID][offset : offset+length])), nil
+		})
+	service.EXPECT().DeleteFile(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, fileID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if _, ok := files[fileID]; !ok {
+				return fmt.Errorf("error get file: %w", sql.ErrNoRows)
+			}
+			delete(files, fileID)
+			delete(content, fileID)
+			return nil
+		})
+	service.EXPECT().ListFiles(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, prefix, startAfter string, limit int) ([]*entities.File, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			var result []*entities.File
+			for id, file := range files {
+				if strings.HasPrefix(id, prefix) && id > startAfter {
+					result = append(result, file)
+				}
+			}
+			sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+			if len(result) > limit {
+				result = result[:limit]
+			}
+			return result, nil
+		})
+}
+
+// newS3UploadsBackend makes service keep multipart uploads in memory, completed upload is saved as file
+func newS3UploadsBackend(service *receiver.MockDataReceiver) {
+	var (
+		mu      sync.Mutex
+		uploads = make(map[string]*entities.Upload)
+		parts   = make(map[string]map[int][]byte)
+	)
+	service.EXPECT().InitiateUpload(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, fileID string, opts receiver.SaveOptions) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			id := fmt.Sprintf("upload-%d", len(uploads))
+			// fiber header values are valid only within the request
+			uploads[id] = &entities.Upload{ID: id, FileID: fileID, Status: entities.FileStatusNew, ContentType: strings.Clone(opts.ContentType)}
+			parts[id] = make(map[int][]byte)
+			return id, nil
+		})
+	service.EXPECT().GetUpload(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, uploadID string) (*entities.Upload, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if upload, ok := uploads[uploadID]; ok {
+				return upload, nil
+			}
+			return nil, receiver.ErrUploadNotFound
+		})
+	service.EXPECT().SavePart(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, uploadID string, number int, data io.Reader, size int64) (*entities.UploadPart, error) {
+			received := make([]byte, size)
+			if _, err := io.ReadFull(data, received); err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			parts[uploadID][number] = received
+			return uploadPart(number, received), nil
+		})
+	service.EXPECT().ListParts(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, uploadID string) ([]*entities.UploadPart, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			var result []*entities.UploadPart
+			for number, data := range parts[uploadID] {
+				result = append(result, uploadPart(number, data))
+			}
+			sort.Slice(result, func(i, j int) bool { return result[i].Number < result[j].Number })
+			return result, nil
+		})
+	service.EXPECT().CompleteUpload(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, uploadID string, numbers []int) error {
+			mu.Lock()
+			upload := uploads[uploadID]
+			upload.Status = entities.FileStatusComplete
+			var data []byte
+			for _, number := range numbers {
+				data = append(data, parts[uploadID][number]...)
+			}
+			mu.Unlock()
+			if err := service.SaveFileStream(ctx, upload.FileID, bytes.NewReader(data), int64(len(data)),
+				receiver.SaveOptions{ContentType: upload.ContentType}); err != nil {
+				return err
+			}
+			file, err := service.GetFileInfo(ctx, upload.FileID)
+			if err != nil {
+				return err
+			}
+			file.MD5 = fmt.Sprintf("multipart-%d", len(numbers))
+			return nil
+		})
+	service.EXPECT().AbortUpload(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, uploadID string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			uploads[uploadID].Status = entities.FileStatusPurge
+			return nil
+		})
+}
+
+func uploadPart(number int, data []byte) *entities.UploadPart {
+	sum := md5.Sum(data) //nolint:gosec // S3 ETag is MD5 of the object
+	return &entities.UploadPart{Number: number, Size: int64(len(data)), MD5: hex.EncodeToString(sum[:]), UpdatedAt: time.Now()}
+}
+
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+func objectKeys(objects []types.Object) []string {
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		keys = append(keys, aws.ToString(object.Key))
+	}
+	return keys
+}
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"errors"
+	"extendable_storage/internal/service/receiver"
+	"log/slog"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type initiateUploadResponse struct {
+	UploadID string `json:"upload_id"`
+}
+
+// completeUploadRequest lists numbers of parts which make the file, all uploaded parts are used if it is empty
+type completeUploadRequest struct {
+	Parts []int `json:"parts"`
+}
+
+func (s *Server) initiateUpload(ctx *fiber.Ctx) error {
+	fileID := ctx.Params("id")
+	opts, err := parseSaveOptions(ctx.Get(headerErasureCoding))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	opts.ContentType = ctx.Get(fiber.HeaderContentType)
+	uploadID, err := s.service.InitiateUpload(ctx.UserContext(), fileID, opts)
+	if err != nil {
+		return s.handleError(ctx, err, fileID)
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(initiateUploadResponse{UploadID: uploadID})
+}
+
+// savePart saves part of the upload, ETag of the part is its SHA-256
+func (s *Server) savePart(ctx *fiber.Ctx) error {
+	uploadID := ctx.Params("upload")
+	number, err := strconv.Atoi(ctx.Params("number"))
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString("invalid part number")
+	}
+	size := ctx.Request().Header.ContentLength()
+	if size < 0 {
+		return ctx.Status(fiber.StatusLengthRequired).SendString("content length is required")
+	}
+	part, err := s.service.SavePart(ctx.UserContext(), uploadID, number, ctx.Context().RequestBodyStream(), int64(size))
+	if err != nil {
+		return s.handleUploadError(ctx, err, uploadID)
+	}
+	ctx.Set(fiber.HeaderETag, `"`+part.SHA256+`"`)
+	return ctx.JSON(part)
+}
+
+func (s *Server) listParts(ctx *fiber.Ctx) error {
+	uploadID := ctx.Params("upload")
+	parts, err := s.service.ListParts(ctx.UserContext(), uploadID)
+	if err != nil {
+		return s.handleUploadError(ctx, err, uploadID)
+	}
+	return ctx.JSON(parts)
+}
+
+func (s *Server) completeUpload(ctx *fiber.Ctx) error {
+	uploadID := ctx.Params("upload")
+	var req completeUploadRequest
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return ctx.Status(fiber.StatusBadRequest).SendString("invalid request body")
+		}
+	}
+	if err := s.service.CompleteUpload(ctx.UserContext(), uploadID, req.Parts); err != nil {
+		return s.handleUploadError(ctx, err, uploadID)
+	}
+	return ctx.SendStatus(fiber.StatusCreated)
+}
+
+func (s *Server) abortUpload(ctx *fiber.Ctx) error {
+	uploadID := ctx.Params("upload")
+	if err := s.service.AbortUpload(ctx.UserContext(), uploadID); err != nil {
+		return s.handleUploadError(ctx, err, uploadID)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// handleUploadError maps upload errors to http status codes, the rest ones are mapped as file errors
+func (s *Server) handleUploadError(ctx *fiber.Ctx, err error, uploadID string) error {
+	switch {
+	case errors.Is(err, receiver.ErrUploadNotFound):
+		return ctx.Status(fiber.StatusNotFound).SendString(err.Error())
+	case errors.Is(err, receiver.ErrInvalidPart):
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	case errors.Is(err, receiver.ErrFileAlreadyExists):
+		return ctx.Status(fiber.StatusConflict).SendString(err.Error())
+	default:
+		s.log.Error("error process upload request", err, slog.String("upload_id", uploadID))
+		return ctx.Status(fiber.StatusInternalServerError).SendString("internal error")
+	}
+}
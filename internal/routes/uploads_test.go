@@ -0,0 +1,122 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/orchestrator"
+	"extendable_storage/internal/service/receiver"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_Uploads(t *testing.T) {
+	// given
+	mck := gomock.NewController(t)
+	service := receiver.NewMockDataReceiver(mck)
+	srv := InitAppRouter(logger.NewAppSLogger("test"), service, orchestrator.NewMockDataRouter(mck), nil, ":0")
+	payload := []byte("some part content")
+
+	t.Run("should initiate upload", func(t *testing.T) {
+		// given
+		service.EXPECT().InitiateUpload(gomock.Any(), "abc", receiver.SaveOptions{ContentType: "text/plain"}).Return("u1", nil)
+		req := httptest.NewRequest(http.MethodPost, "/files/abc/uploads", nil)
+		req.Header.Set(fiber.HeaderContentType, "text/plain")
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+		var received initiateUploadResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&received))
+		require.Equal(t, "u1", received.UploadID)
+	})
+	t.Run("should save part", func(t *testing.T) {
+		// given
+		service.EXPECT().SavePart(gomock.Any(), "u1", 3, gomock.Any(), int64(len(payload))).
+			DoAndReturn(func(_ context.Context, _ string, number int, data io.Reader, size int64) (*entities.UploadPart, error) {
+				received, err := io.ReadAll(data)
+				require.NoError(t, err)
+				require.Equal(t, payload, received)
+				return &entities.UploadPart{Number: number, Size: size, SHA256: "sha"}, nil
+			})
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodPut, "/uploads/u1/parts/3", bytes.NewReader(payload)))
+
+		// then
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		require.Equal(t, `"sha"`, resp.Header.Get(fiber.HeaderETag))
+	})
+	t.Run("should return bad request for invalid part", func(t *testing.T) {
+		// given
+		service.EXPECT().SavePart(gomock.Any(), "u1", 0, gomock.Any(), gomock.Any()).
+			Return(nil, fmt.Errorf("wrapped: %w", receiver.ErrInvalidPart))
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodPut, "/uploads/u1/parts/0", bytes.NewReader(payload)))
+
+		// then
+		require.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+	t.Run("should list parts", func(t *testing.T) {
+		// given
+		parts := []*entities.UploadPart{{Number: 1, Size: 5, SHA256: "a"}, {Number: 3, Size: 7, SHA256: "b"}}
+		service.EXPECT().ListParts(gomock.Any(), "u1").Return(parts, nil)
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodGet, "/uploads/u1/parts", nil))
+
+		// then
+		require.Equal(t, fiber.StatusOK, resp.StatusCode)
+		var received []*entities.UploadPart
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&received))
+		require.Equal(t, parts, received)
+	})
+	t.Run("should complete upload with listed parts", func(t *testing.T) {
+		// given
+		service.EXPECT().CompleteUpload(gomock.Any(), "u1", []int{1, 3}).Return(nil)
+		req := httptest.NewRequest(http.MethodPost, "/uploads/u1/complete", strings.NewReader(`{"parts":[1,3]}`))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+		// when
+		resp := doRequest(t, srv, req)
+
+		// then
+		require.Equal(t, fiber.StatusCreated, resp.StatusCode)
+	})
+	t.Run("should complete upload with all parts", func(t *testing.T) {
+		// given
+		service.EXPECT().CompleteUpload(gomock.Any(), "u2", nil).Return(fmt.Errorf("wrapped: %w", receiver.ErrFileAlreadyExists))
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodPost, "/uploads/u2/complete", nil))
+
+		// then
+		require.Equal(t, fiber.StatusConflict, resp.StatusCode)
+	})
+	t.Run("should abort upload", func(t *testing.T) {
+		// given
+		service.EXPECT().AbortUpload(gomock.Any(), "u1").Return(nil)
+		service.EXPECT().AbortUpload(gomock.Any(), "u3").Return(receiver.ErrUploadNotFound)
+
+		// when
+		resp := doRequest(t, srv, httptest.NewRequest(http.MethodDelete, "/uploads/u1", nil))
+		respMissing := doRequest(t, srv, httptest.NewRequest(http.MethodDelete, "/uploads/u3", nil))
+
+		// then
+		require.Equal(t, fiber.StatusNoContent, resp.StatusCode)
+		require.Equal(t, fiber.StatusNotFound, respMissing.StatusCode)
+	})
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/entities"
+)
+
+var (
+	ErrUnauthenticated  = errors.New("invalid credentials")
+	ErrInvalidNamespace = errors.New("invalid namespace")
+	ErrInvalidScope     = errors.New("invalid scope")
+	ErrTenantExists     = errors.New("tenant already exists")
+	ErrTenantNotFound   = errors.New("tenant not found")
+	ErrKeyNotFound      = errors.New("api key not found")
+)
+
+// Authenticator checks credentials of gateway requests and manages tenants and their API keys
+//
+//go:generate mockgen -source=abstract.go -destination=abstract_mock.go -package=auth
+type Authenticator interface {
+	// AuthenticateKey returns principal of API key "<id>.<secret>", ErrUnauthenticated if key is unknown or revoked
+	AuthenticateKey(ctx context.Context, key string) (*entities.Principal, error)
+	// AuthenticateToken returns principal of JWT signed with HS256, ErrUnauthenticated if token is invalid or expired
+	AuthenticateToken(ctx context.Context, token string) (*entities.Principal, error)
+
+	CreateTenant(ctx context.Context, namespace string) (*entities.Tenant, error)
+	// CreateKey creates API key of the tenant, the key is returned only once
+	CreateKey(ctx context.Context, namespace string, scope entities.Scope) (string, error)
+	RevokeKey(ctx context.Context, id string) error
+}
@@ -0,0 +1,114 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: abstract.go
+//
+// Generated by this command:
+//
+//	mockgen -source=abstract.go -destination=abstract_mock.go -package=auth
+//
+// Package auth is a generated GoMock package.
+package auth
+
+import (
+	context "context"
+	entities "extendable_storage/internal/entities"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAuthenticator is a mock of Authenticator interface.
+type MockAuthenticator struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthenticatorMockRecorder
+}
+
+// MockAuthenticatorMockRecorder is the mock recorder for MockAuthenticator.
+type MockAuthenticatorMockRecorder struct {
+	mock *MockAuthenticator
+}
+
+// NewMockAuthenticator creates a new mock instance.
+func NewMockAuthenticator(ctrl *gomock.Controller) *MockAuthenticator {
+	mock := &MockAuthenticator{ctrl: ctrl}
+	mock.recorder = &MockAuthenticatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuthenticator) EXPECT() *MockAuthenticatorMockRecorder {
+	return m.recorder
+}
+
+// AuthenticateKey mocks base method.
+func (m *MockAuthenticator) AuthenticateKey(ctx context.Context, key string) (*entities.Principal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateKey", ctx, key)
+	ret0, _ := ret[0].(*entities.Principal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateKey indicates an expected call of AuthenticateKey.
+func (mr *MockAuthenticatorMockRecorder) AuthenticateKey(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateKey", reflect.TypeOf((*MockAuthenticator)(nil).AuthenticateKey), ctx, key)
+}
+
+// AuthenticateToken mocks base method.
+func (m *MockAuthenticator) AuthenticateToken(ctx context.Context, token string) (*entities.Principal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthenticateToken", ctx, token)
+	ret0, _ := ret[0].(*entities.Principal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuthenticateToken indicates an expected call of AuthenticateToken.
+func (mr *MockAuthenticatorMockRecorder) AuthenticateToken(ctx, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthenticateToken", reflect.TypeOf((*MockAuthenticator)(nil).AuthenticateToken), ctx, token)
+}
+
+// CreateKey mocks base method.
+func (m *MockAuthenticator) CreateKey(ctx context.Context, namespace string, scope entities.Scope) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateKey", ctx, namespace, scope)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateKey indicates an expected call of CreateKey.
+func (mr *MockAuthenticatorMockRecorder) CreateKey(ctx, namespace, scope any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateKey", reflect.TypeOf((*MockAuthenticator)(nil).CreateKey), ctx, namespace, scope)
+}
+
+// CreateTenant mocks base method.
+func (m *MockAuthenticator) CreateTenant(ctx context.Context, namespace string) (*entities.Tenant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTenant", ctx, namespace)
+	ret0, _ := ret[0].(*entities.Tenant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTenant indicates an expected call of CreateTenant.
+func (mr *MockAuthenticatorMockRecorder) CreateTenant(ctx, namespace any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTenant", reflect.TypeOf((*MockAuthenticator)(nil).CreateTenant), ctx, namespace)
+}
+
+// RevokeKey mocks base method.
+func (m *MockAuthenticator) RevokeKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeKey indicates an expected call of RevokeKey.
+func (mr *MockAuthenticatorMockRecorder) RevokeKey(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeKey", reflect.TypeOf((*MockAuthenticator)(nil).RevokeKey), ctx, id)
+}
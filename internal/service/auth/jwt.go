@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"extendable_storage/internal/entities"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	jwtAlgorithm = "HS256"
+)
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// TokenClaims are claims of gateway JWT: namespace and scope of the caller, expiration and not before unix times.
+// Admin token may have no namespace.
+type TokenClaims struct {
+	Subject   string         `json:"sub,omitempty"`
+	Namespace string         `json:"ns,omitempty"`
+	Scope     entities.Scope `json:"scope"`
+	ExpiresAt int64          `json:"exp"`
+	NotBefore int64          `json:"nbf,omitempty"`
+}
+
+// SignToken returns JWT with claims signed with HS256, see AuthenticateToken
+func SignToken(claims *TokenClaims, secret string) (string, error) {
+	header, err := json.Marshal(tokenHeader{Alg: jwtAlgorithm, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(signed, []byte(secret))), nil
+}
+
+// verifyToken checks signature and validity period of the token. Only HS256 is accepted,
+// so token can't choose weaker algorithm.
+func verifyToken(token string, secret []byte, now time.Time) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != jwtAlgorithm {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("error decode signature: %w", err)
+	}
+	if !hmac.Equal(signature, sign(parts[0]+"."+parts[1], secret)) {
+		return nil, errors.New("signature mismatch")
+	}
+	var claims TokenClaims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.ExpiresAt == 0 || now.Unix() >= claims.ExpiresAt {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now.Unix() < claims.NotBefore {
+		return nil, errors.New("token is not valid yet")
+	}
+	return &claims, nil
+}
+
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("error decode token: %w", err)
+	}
+	if err = json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("error decode token: %w", err)
+	}
+	return nil
+}
+
+func sign(data string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
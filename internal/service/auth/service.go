@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/repository/tenant"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	keyIDBytes     = 16
+	keySecretBytes = 32
+)
+
+var namespaceRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+
+type Service struct {
+	repo      *tenant.Repo
+	jwtSecret []byte
+	now       func() time.Time
+}
+
+var _ Authenticator = (*Service)(nil)
+
+// NewService creates authenticator, tokens are verified with jwtSecret. Empty secret disables tokens.
+func NewService(repo *tenant.Repo, jwtSecret string) *Service {
+	return &Service{repo: repo, jwtSecret: []byte(jwtSecret), now: time.Now}
+}
+
+func (s *Service) AuthenticateKey(ctx context.Context, key string) (*entities.Principal, error) {
+	id, secret, ok := strings.Cut(key, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, ErrUnauthenticated
+	}
+	stored, err := s.repo.GetKey(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error get api key: %w", err)
+	}
+	if stored.RevokedAt != nil || subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(stored.SecretHash)) != 1 {
+		return nil, ErrUnauthenticated
+	}
+	return &entities.Principal{Namespace: stored.Namespace, Scope: stored.Scope}, nil
+}
+
+func (s *Service) AuthenticateToken(_ context.Context, token string) (*entities.Principal, error) {
+	if len(s.jwtSecret) == 0 {
+		return nil, ErrUnauthenticated
+	}
+	claims, err := verifyToken(token, s.jwtSecret, s.now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+	if !claims.Scope.Valid() || (claims.Scope != entities.ScopeAdmin && !namespaceRe.MatchString(claims.Namespace)) {
+		return nil, fmt.Errorf("%w: invalid claims", ErrUnauthenticated)
+	}
+	return &entities.Principal{Namespace: claims.Namespace, Scope: claims.Scope}, nil
+}
+
+func (s *Service) CreateTenant(ctx context.Context, namespace string) (*entities.Tenant, error) {
+	if !namespaceRe.MatchString(namespace) {
+		return nil, ErrInvalidNamespace
+	}
+	created, err := s.repo.CreateTenant(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error create tenant: %w", err)
+	}
+	if !created {
+		return nil, ErrTenantExists
+	}
+	tenant, err := s.repo.GetTenant(ctx, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error get tenant: %w", err)
+	}
+	return tenant, nil
+}
+
+func (s *Service) CreateKey(ctx context.Context, namespace string, scope entities.Scope) (string, error) {
+	if !scope.Valid() {
+		return "", ErrInvalidScope
+	}
+	if _, err := s.repo.GetTenant(ctx, namespace); errors.Is(err, sql.ErrNoRows) {
+		return "", ErrTenantNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("error get tenant: %w", err)
+	}
+	id, err := randomString(keyIDBytes, hex.EncodeToString)
+	if err != nil {
+		return "", err
+	}
+	secret, err := randomString(keySecretBytes, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", err
+	}
+	key := &entities.APIKey{ID: id, Namespace: namespace, SecretHash: hashSecret(secret), Scope: scope}
+	if err = s.repo.CreateKey(ctx, key); err != nil {
+		return "", fmt.Errorf("error create api key: %w", err)
+	}
+	return id + "." + secret, nil
+}
+
+func (s *Service) RevokeKey(ctx context.Context, id string) error {
+	revoked, err := s.repo.RevokeKey(ctx, id)
+	if err != nil {
+		return fmt.Errorf("error revoke api key: %w", err)
+	}
+	if !revoked {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generate api key: %w", err)
+	}
+	return encode(buf), nil
+}
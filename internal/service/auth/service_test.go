@@ -0,0 +1,116 @@
+package auth_test
+
+import (
+	"context"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/auth"
+	testhelpers "extendable_storage/internal/test_helpers"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecret = "test-secret"
+)
+
+func TestService_AuthenticateToken(t *testing.T) {
+	// given
+	ctx := context.Background()
+	service := auth.NewService(nil, testSecret)
+	valid := &auth.TokenClaims{Namespace: "acme", Scope: entities.ScopeRead, ExpiresAt: time.Now().Add(time.Hour).Unix()}
+
+	t.Run("should accept valid token", func(t *testing.T) {
+		// given
+		token, err := auth.SignToken(valid, testSecret)
+		require.NoError(t, err)
+
+		// when
+		principal, err := service.AuthenticateToken(ctx, token)
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, &entities.Principal{Namespace: "acme", Scope: entities.ScopeRead}, principal)
+	})
+	t.Run("should reject invalid tokens", func(t *testing.T) {
+		// given
+		otherSecret, err := auth.SignToken(valid, "other-secret")
+		require.NoError(t, err)
+		expired, err := auth.SignToken(&auth.TokenClaims{Namespace: "acme", Scope: entities.ScopeRead, ExpiresAt: time.Now().Add(-time.Minute).Unix()}, testSecret)
+		require.NoError(t, err)
+		notYetValid, err := auth.SignToken(&auth.TokenClaims{Namespace: "acme", Scope: entities.ScopeRead,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(), NotBefore: time.Now().Add(time.Minute).Unix()}, testSecret)
+		require.NoError(t, err)
+		noNamespace, err := auth.SignToken(&auth.TokenClaims{Scope: entities.ScopeReadWrite, ExpiresAt: valid.ExpiresAt}, testSecret)
+		require.NoError(t, err)
+		unknownScope, err := auth.SignToken(&auth.TokenClaims{Namespace: "acme", Scope: "owner", ExpiresAt: valid.ExpiresAt}, testSecret)
+		require.NoError(t, err)
+		token, err := auth.SignToken(valid, testSecret)
+		require.NoError(t, err)
+		parts := strings.Split(token, ".")
+		// header with "alg":"none" and no signature
+		unsigned := "eyJhbGciOiJub25lIn0." + parts[1] + "."
+
+		for name, token := range map[string]string{
+			"other secret": otherSecret, "expired": expired, "not yet valid": notYetValid, "no namespace": noNamespace,
+			"unknown scope": unknownScope, "unsigned": unsigned, "malformed": "abc",
+		} {
+			// when
+			_, err = service.AuthenticateToken(ctx, token)
+
+			// then
+			require.ErrorIs(t, err, auth.ErrUnauthenticated, name)
+		}
+	})
+	t.Run("should reject tokens without secret", func(t *testing.T) {
+		// given
+		token, err := auth.SignToken(valid, "")
+		require.NoError(t, err)
+
+		// when
+		_, err = auth.NewService(nil, "").AuthenticateToken(ctx, token)
+
+		// then
+		require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	})
+}
+
+func TestService_APIKeys(t *testing.T) {
+	// given
+	container := testhelpers.GetClean(t)
+	service := auth.NewService(container.RepoTenant, testSecret)
+	_, err := service.CreateTenant(container.Ctx, "acme")
+	require.NoError(t, err)
+
+	t.Run("should validate tenant", func(t *testing.T) {
+		_, err = service.CreateTenant(container.Ctx, "acme")
+		require.ErrorIs(t, err, auth.ErrTenantExists)
+		_, err = service.CreateTenant(container.Ctx, "Bad:Name")
+		require.ErrorIs(t, err, auth.ErrInvalidNamespace)
+		_, err = service.CreateKey(container.Ctx, "unknown", entities.ScopeRead)
+		require.ErrorIs(t, err, auth.ErrTenantNotFound)
+		_, err = service.CreateKey(container.Ctx, "acme", "owner")
+		require.ErrorIs(t, err, auth.ErrInvalidScope)
+	})
+	t.Run("should authenticate key until it is revoked", func(t *testing.T) {
+		// given
+		key, errC := service.CreateKey(container.Ctx, "acme", entities.ScopeReadWrite)
+		require.NoError(t, errC)
+		id, _, _ := strings.Cut(key, ".")
+
+		// when
+		principal, errA := service.AuthenticateKey(container.Ctx, key)
+		_, errWrong := service.AuthenticateKey(container.Ctx, id+".wrong")
+		require.NoError(t, service.RevokeKey(container.Ctx, id))
+		_, errRevoked := service.AuthenticateKey(container.Ctx, key)
+
+		// then
+		require.NoError(t, errA)
+		require.Equal(t, &entities.Principal{Namespace: "acme", Scope: entities.ScopeReadWrite}, principal)
+		require.ErrorIs(t, errWrong, auth.ErrUnauthenticated)
+		require.ErrorIs(t, errRevoked, auth.ErrUnauthenticated)
+		require.ErrorIs(t, service.RevokeKey(container.Ctx, id), auth.ErrKeyNotFound)
+	})
+}
@@ -1,24 +1,60 @@
 package orchestrator
 
 import (
+	"context"
+	"errors"
 	"extendable_storage/internal/entities"
 	"extendable_storage/internal/service/storager"
 )
 
+var (
+	ErrDataKeeperExists = errors.New("data keeper already exists in cluster")
+	ErrNoDataKeepers    = errors.New("no ready data keepers in cluster")
+	ErrDataKeeperAbsent = errors.New("data keeper not found in cluster")
+	ErrLastDataKeeper   = errors.New("last serving data keeper can't be removed")
+	ErrLayoutMigration  = errors.New("circle layout migration is in progress")
+
+	ErrRebalanceJobAbsent = errors.New("rebalance job not found")
+)
+
 // DataRouter is an interface for data routing nodes which can join the cluster and route data at any time
-// manage new nodes joining the cluster and route data to them
+// manage new nodes joining the cluster and route data to them.
+// Calls to data keepers are bound to ctx, see CallPolicy.
+//
+//go:generate mockgen -source=abstract.go -destination=abstract_mock.go -package=orchestrator
 type DataRouter interface {
 	// GetFileChunk returns a part of the file by its ID
-	GetFileChunk(chunk *entities.FileChunk) ([]byte, error)
+	GetFileChunk(ctx context.Context, chunk *entities.FileChunk) ([]byte, error)
 	// SaveFileChunk saves a part of the file by its ID
-	SaveFileChunk(chunk *entities.FileChunk, data []byte) error
+	SaveFileChunk(ctx context.Context, chunk *entities.FileChunk, data []byte) error
 
 	// PurgeFileChunks command to purge file chunks
-	PurgeFileChunks(chunks []*entities.FileChunk) error
+	PurgeFileChunks(ctx context.Context, chunks []*entities.FileChunk) error
+	// ChunkOwner returns id of data keeper which keeps primary copy of the chunk
+	ChunkOwner(chunk *entities.FileChunk) (string, error)
+	// ServingDataKeepers returns number of distinct data keepers which serve chunks
+	ServingDataKeepers() int
 
 	// AddDataKeeper adds a new data keeper to the cluster and orchestrate rebalance
-	AddDataKeeper(serviceID string, storage storager.DataKeeper) error
+	AddDataKeeper(ctx context.Context, serviceID string, storage storager.DataKeeper) error
+	// PlanDataKeeper returns placement AddDataKeeper would apply, without changing the cluster
+	PlanDataKeeper(ctx context.Context, serviceID string, storage storager.DataKeeper) (*PlacementPlan, error)
+	// RemoveDataKeeper drains data keeper: moves its sectors to other keepers and removes it from the cluster.
+	// Add and remove wait until previous rebalance job is finished or ctx is done.
+	RemoveDataKeeper(ctx context.Context, serviceID string) error
+	// GetRebalanceProgress returns progress of the last rebalance job of data keeper, ErrRebalanceJobAbsent if it had none
+	GetRebalanceProgress(ctx context.Context, serviceID string) (*RebalanceProgress, error)
+	// ListRebalanceProgress returns progress of the last rebalance job of every data keeper
+	ListRebalanceProgress(ctx context.Context) ([]*RebalanceProgress, error)
 
 	// PrintServerPositions prints the current server positions on circle
 	PrintServerPositions()
 }
+
+// KeeperConnector creates data keeper for remote storage node stored in topology
+type KeeperConnector func(serverID, address string) (storager.DataKeeper, error)
+
+// remoteDataKeeper is implemented by data keepers reachable over network, their address is stored in topology
+type remoteDataKeeper interface {
+	Address() string
+}
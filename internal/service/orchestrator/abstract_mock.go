@@ -0,0 +1,235 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: abstract.go
+//
+// Generated by this command:
+//
+//	mockgen -source=abstract.go -destination=abstract_mock.go -package=orchestrator
+//
+// Package orchestrator is a generated GoMock package.
+package orchestrator
+
+import (
+	context "context"
+	entities "extendable_storage/internal/entities"
+	storager "extendable_storage/internal/service/storager"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockDataRouter is a mock of DataRouter interface.
+type MockDataRouter struct {
+	ctrl     *gomock.Controller
+	recorder *MockDataRouterMockRecorder
+}
+
+// MockDataRouterMockRecorder is the mock recorder for MockDataRouter.
+type MockDataRouterMockRecorder struct {
+	mock *MockDataRouter
+}
+
+// NewMockDataRouter creates a new mock instance.
+func NewMockDataRouter(ctrl *gomock.Controller) *MockDataRouter {
+	mock := &MockDataRouter{ctrl: ctrl}
+	mock.recorder = &MockDataRouterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDataRouter) EXPECT() *MockDataRouterMockRecorder {
+	return m.recorder
+}
+
+// AddDataKeeper mocks base method.
+func (m *MockDataRouter) AddDataKeeper(ctx context.Context, serviceID string, storage storager.DataKeeper) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDataKeeper", ctx, serviceID, storage)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDataKeeper indicates an expected call of AddDataKeeper.
+func (mr *MockDataRouterMockRecorder) AddDataKeeper(ctx, serviceID, storage any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDataKeeper", reflect.TypeOf((*MockDataRouter)(nil).AddDataKeeper), ctx, serviceID, storage)
+}
+
+// ChunkOwner mocks base method.
+func (m *MockDataRouter) ChunkOwner(chunk *entities.FileChunk) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChunkOwner", chunk)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChunkOwner indicates an expected call of ChunkOwner.
+func (mr *MockDataRouterMockRecorder) ChunkOwner(chunk any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChunkOwner", reflect.TypeOf((*MockDataRouter)(nil).ChunkOwner), chunk)
+}
+
+// GetFileChunk mocks base method.
+func (m *MockDataRouter) GetFileChunk(ctx context.Context, chunk *entities.FileChunk) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFileChunk", ctx, chunk)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFileChunk indicates an expected call of GetFileChunk.
+func (mr *MockDataRouterMockRecorder) GetFileChunk(ctx, chunk any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileChunk", reflect.TypeOf((*MockDataRouter)(nil).GetFileChunk), ctx, chunk)
+}
+
+// GetRebalanceProgress mocks base method.
+func (m *MockDataRouter) GetRebalanceProgress(ctx context.Context, serviceID string) (*RebalanceProgress, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRebalanceProgress", ctx, serviceID)
+	ret0, _ := ret[0].(*RebalanceProgress)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRebalanceProgress indicates an expected call of GetRebalanceProgress.
+func (mr *MockDataRouterMockRecorder) GetRebalanceProgress(ctx, serviceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRebalanceProgress", reflect.TypeOf((*MockDataRouter)(nil).GetRebalanceProgress), ctx, serviceID)
+}
+
+// ListRebalanceProgress mocks base method.
+func (m *MockDataRouter) ListRebalanceProgress(ctx context.Context) ([]*RebalanceProgress, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRebalanceProgress", ctx)
+	ret0, _ := ret[0].([]*RebalanceProgress)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRebalanceProgress indicates an expected call of ListRebalanceProgress.
+func (mr *MockDataRouterMockRecorder) ListRebalanceProgress(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRebalanceProgress", reflect.TypeOf((*MockDataRouter)(nil).ListRebalanceProgress), ctx)
+}
+
+// PlanDataKeeper mocks base method.
+func (m *MockDataRouter) PlanDataKeeper(ctx context.Context, serviceID string, storage storager.DataKeeper) (*PlacementPlan, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlanDataKeeper", ctx, serviceID, storage)
+	ret0, _ := ret[0].(*PlacementPlan)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PlanDataKeeper indicates an expected call of PlanDataKeeper.
+func (mr *MockDataRouterMockRecorder) PlanDataKeeper(ctx, serviceID, storage any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlanDataKeeper", reflect.TypeOf((*MockDataRouter)(nil).PlanDataKeeper), ctx, serviceID, storage)
+}
+
+// PrintServerPositions mocks base method.
+func (m *MockDataRouter) PrintServerPositions() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PrintServerPositions")
+}
+
+// PrintServerPositions indicates an expected call of PrintServerPositions.
+func (mr *MockDataRouterMockRecorder) PrintServerPositions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrintServerPositions", reflect.TypeOf((*MockDataRouter)(nil).PrintServerPositions))
+}
+
+// PurgeFileChunks mocks base method.
+func (m *MockDataRouter) PurgeFileChunks(ctx context.Context, chunks []*entities.FileChunk) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeFileChunks", ctx, chunks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeFileChunks indicates an expected call of PurgeFileChunks.
+func (mr *MockDataRouterMockRecorder) PurgeFileChunks(ctx, chunks any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeFileChunks", reflect.TypeOf((*MockDataRouter)(nil).PurgeFileChunks), ctx, chunks)
+}
+
+// RemoveDataKeeper mocks base method.
+func (m *MockDataRouter) RemoveDataKeeper(ctx context.Context, serviceID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveDataKeeper", ctx, serviceID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveDataKeeper indicates an expected call of RemoveDataKeeper.
+func (mr *MockDataRouterMockRecorder) RemoveDataKeeper(ctx, serviceID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveDataKeeper", reflect.TypeOf((*MockDataRouter)(nil).RemoveDataKeeper), ctx, serviceID)
+}
+
+// SaveFileChunk mocks base method.
+func (m *MockDataRouter) SaveFileChunk(ctx context.Context, chunk *entities.FileChunk, data []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveFileChunk", ctx, chunk, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveFileChunk indicates an expected call of SaveFileChunk.
+func (mr *MockDataRouterMockRecorder) SaveFileChunk(ctx, chunk, data any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFileChunk", reflect.TypeOf((*MockDataRouter)(nil).SaveFileChunk), ctx, chunk, data)
+}
+
+// ServingDataKeepers mocks base method.
+func (m *MockDataRouter) ServingDataKeepers() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServingDataKeepers")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// ServingDataKeepers indicates an expected call of ServingDataKeepers.
+func (mr *MockDataRouterMockRecorder) ServingDataKeepers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServingDataKeepers", reflect.TypeOf((*MockDataRouter)(nil).ServingDataKeepers))
+}
+
+// MockremoteDataKeeper is a mock of remoteDataKeeper interface.
+type MockremoteDataKeeper struct {
+	ctrl     *gomock.Controller
+	recorder *MockremoteDataKeeperMockRecorder
+}
+
+// MockremoteDataKeeperMockRecorder is the mock recorder for MockremoteDataKeeper.
+type MockremoteDataKeeperMockRecorder struct {
+	mock *MockremoteDataKeeper
+}
+
+// NewMockremoteDataKeeper creates a new mock instance.
+func NewMockremoteDataKeeper(ctrl *gomock.Controller) *MockremoteDataKeeper {
+	mock := &MockremoteDataKeeper{ctrl: ctrl}
+	mock.recorder = &MockremoteDataKeeperMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockremoteDataKeeper) EXPECT() *MockremoteDataKeeperMockRecorder {
+	return m.recorder
+}
+
+// Address mocks base method.
+func (m *MockremoteDataKeeper) Address() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Address")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Address indicates an expected call of Address.
+func (mr *MockremoteDataKeeperMockRecorder) Address() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Address", reflect.TypeOf((*MockremoteDataKeeper)(nil).Address))
+}
@@ -0,0 +1,80 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/storage/cache/disk"
+	"extendable_storage/internal/storage/cache/lru"
+	"sync/atomic"
+)
+
+// CacheStats is a number of chunk reads served from memory (Hits), from disk (DiskHits) and passed to data keepers (Misses)
+type CacheStats struct {
+	Hits     uint64 `json:"hits"`
+	DiskHits uint64 `json:"disk_hits"`
+	Misses   uint64 `json:"misses"`
+}
+
+// CachingRouter serves hot chunks from cache, other calls go to wrapped DataRouter.
+// Chunk id is hash of its content, so cached chunk is valid until it is purged.
+type CachingRouter struct {
+	DataRouter
+	cache    lru.Cacher
+	l2       *disk.Cache
+	hits     atomic.Uint64
+	diskHits atomic.Uint64
+	misses   atomic.Uint64
+}
+
+// NewCachingRouter wraps router with memory cache, cache is used concurrently, lru.Cache and lru.ShardedCache are safe for it.
+// Optional l2 is consulted on cache miss before data keepers, chunks evicted from memory get to it by disk.Cache.Drain.
+func NewCachingRouter(router DataRouter, cache lru.Cacher, l2 *disk.Cache) *CachingRouter {
+	return &CachingRouter{DataRouter: router, cache: cache, l2: l2}
+}
+
+// GetFileChunk returns cached chunk or reads it from data keepers and caches it.
+// Chunk which doesn't fit into cache or is not admitted to it is returned without caching.
+func (r *CachingRouter) GetFileChunk(ctx context.Context, chunk *entities.FileChunk) ([]byte, error) {
+	key := chunk.String()
+	data, ok := r.cache.Get(key)
+	if ok {
+		r.hits.Add(1)
+		return data, nil
+	}
+	if r.l2 != nil {
+		data, ok = r.l2.Get(key)
+	}
+	if ok {
+		r.diskHits.Add(1)
+	} else {
+		r.misses.Add(1)
+		var err error
+		if data, err = r.DataRouter.GetFileChunk(ctx, chunk); err != nil {
+			return nil, err
+		}
+	}
+	// chunk cached by concurrent read is replaced by the same content
+	errS := r.cache.Store(key, data)
+	if errS != nil && !errors.Is(errS, lru.ErrL1CacheIsFull) && !errors.Is(errS, lru.ErrNotAdmitted) {
+		return nil, errS
+	}
+	return data, nil
+}
+
+// PurgeFileChunks purges chunks and drops them from cache.
+// Chunk evicted from memory right before purge can still get to disk, it is evicted from there as unused.
+func (r *CachingRouter) PurgeFileChunks(ctx context.Context, chunks []*entities.FileChunk) error {
+	err := r.DataRouter.PurgeFileChunks(ctx, chunks)
+	for _, chunk := range chunks {
+		r.cache.Remove(chunk.String())
+		if r.l2 != nil {
+			r.l2.Remove(chunk.String())
+		}
+	}
+	return err
+}
+
+func (r *CachingRouter) CacheStats() CacheStats {
+	return CacheStats{Hits: r.hits.Load(), DiskHits: r.diskHits.Load(), Misses: r.misses.Load()}
+}
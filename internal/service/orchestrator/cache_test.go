@@ -0,0 +1,133 @@
+package orchestrator_test
+
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/orchestrator"
+	"extendable_storage/internal/storage/cache/disk"
+	"extendable_storage/internal/storage/cache/lru"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCachingRouter(t *testing.T) {
+	// given
+	ctx := context.Background()
+	mck := gomock.NewController(t)
+	router := orchestrator.NewMockDataRouter(mck)
+	cache := lru.CreateShardedCache(1, 0)
+	cachingRouter := orchestrator.NewCachingRouter(router, cache, nil)
+	chunk := &entities.FileChunk{FileID: "file", ChunkID: "hash"}
+	data := []byte("chunk content")
+
+	t.Run("should read chunk once", func(t *testing.T) {
+		// given
+		router.EXPECT().GetFileChunk(gomock.Any(), chunk).Return(data, nil).Times(1)
+
+		// when
+		for i := 0; i < 3; i++ {
+			received, errG := cachingRouter.GetFileChunk(ctx, chunk)
+			require.NoError(t, errG)
+			require.Equal(t, data, received)
+		}
+
+		// then
+		require.Equal(t, orchestrator.CacheStats{Hits: 2, Misses: 1}, cachingRouter.CacheStats())
+	})
+	t.Run("should not cache failed read", func(t *testing.T) {
+		// given
+		other := &entities.FileChunk{FileID: "file", ChunkID: "other"}
+		errRead := errors.New("read failed")
+		router.EXPECT().GetFileChunk(gomock.Any(), other).Return(nil, errRead)
+		router.EXPECT().GetFileChunk(gomock.Any(), other).Return(data, nil)
+
+		// when
+		_, errFirst := cachingRouter.GetFileChunk(ctx, other)
+		received, errSecond := cachingRouter.GetFileChunk(ctx, other)
+
+		// then
+		require.ErrorIs(t, errFirst, errRead)
+		require.NoError(t, errSecond)
+		require.Equal(t, data, received)
+	})
+	t.Run("should serve concurrent reads", func(t *testing.T) {
+		// given
+		shared := &entities.FileChunk{FileID: "file", ChunkID: "shared"}
+		router.EXPECT().GetFileChunk(gomock.Any(), shared).Return(data, nil).MinTimes(1)
+		var wg sync.WaitGroup
+
+		// when
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					received, errG := cachingRouter.GetFileChunk(ctx, shared)
+					assert.NoError(t, errG)
+					assert.Equal(t, data, received)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// then
+		received, ok := cache.Get(shared.String())
+		require.True(t, ok)
+		require.Equal(t, data, received)
+	})
+	t.Run("should read purged chunk from data keepers", func(t *testing.T) {
+		// given
+		router.EXPECT().PurgeFileChunks(gomock.Any(), []*entities.FileChunk{chunk}).Return(nil)
+		router.EXPECT().GetFileChunk(gomock.Any(), chunk).Return(nil, errors.New("chunk not found"))
+
+		// when
+		require.NoError(t, cachingRouter.PurgeFileChunks(ctx, []*entities.FileChunk{chunk}))
+		_, err := cachingRouter.GetFileChunk(ctx, chunk)
+
+		// then
+		require.Error(t, err)
+	})
+}
+
+func TestCachingRouter_Disk(t *testing.T) {
+	// given
+	ctx := context.Background()
+	mck := gomock.NewController(t)
+	router := orchestrator.NewMockDataRouter(mck)
+	cache := lru.CreateShardedCache(1, 0)
+	l2, err := disk.CreateL2Cache(t.TempDir(), 1)
+	require.NoError(t, err)
+	cachingRouter := orchestrator.NewCachingRouter(router, cache, l2)
+	chunk := &entities.FileChunk{FileID: "file", ChunkID: "hash"}
+	data := []byte("chunk content")
+	// chunk was evicted from memory
+	require.NoError(t, l2.Store(chunk.String(), data))
+
+	t.Run("should read chunk from disk", func(t *testing.T) {
+		// when
+		for i := 0; i < 2; i++ {
+			received, errG := cachingRouter.GetFileChunk(ctx, chunk)
+			require.NoError(t, errG)
+			require.Equal(t, data, received)
+		}
+
+		// then
+		require.Equal(t, orchestrator.CacheStats{Hits: 1, DiskHits: 1}, cachingRouter.CacheStats())
+	})
+	t.Run("should drop purged chunk from disk", func(t *testing.T) {
+		// given
+		router.EXPECT().PurgeFileChunks(gomock.Any(), []*entities.FileChunk{chunk}).Return(nil)
+
+		// when
+		require.NoError(t, cachingRouter.PurgeFileChunks(ctx, []*entities.FileChunk{chunk}))
+
+		// then
+		_, ok := l2.Get(chunk.String())
+		require.False(t, ok)
+	})
+}
@@ -2,180 +2,375 @@ package orchestrator
 
 import (
 	"container/list"
+	"context"
 	"extendable_storage/internal/entities"
 	"extendable_storage/internal/service/storager"
 	"fmt"
 	"sync"
 )
 
+// ringServer is a physical server, it can take several positions on the circle
+type ringServer struct {
+	state     entities.NodeState
+	serverID  string
+	storage   storager.DataKeeper
+	positions []uint32
+}
+
+// dataKeeperContainer is a position of server on the circle
 type dataKeeperContainer struct {
-	state    int
 	position uint32
-	serverID string
-	storage  storager.DataKeeper
+	*ringServer
+}
+
+// Replica is a server which keeps copy of the data
+type Replica struct {
+	ServerID string
+	Storage  storager.DataKeeper
+}
+
+// serverFilter decides if server is taken into account when owners of position are calculated
+type serverFilter func(server *dataKeeperContainer) bool
+
+// isServing checks that server keeps all data of its sectors: it is ready or draining before removal
+func isServing(server *dataKeeperContainer) bool {
+	return server.state == entities.NodeStateReady || server.state == entities.NodeStateDraining
+}
+
+// willServe checks that server keeps data of its sectors when rebalance in progress is finished:
+// it is ready or joins the cluster
+func willServe(server *dataKeeperContainer) bool {
+	return server.state != entities.NodeStateDraining
+}
+
+func containsReplica(replicas []Replica, serverID string) bool {
+	for _, replica := range replicas {
+		if replica.ServerID == serverID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsServer(servers []*dataKeeperContainer, serverID string) bool {
+	for _, server := range servers {
+		if server.serverID == serverID {
+			return true
+		}
+	}
+	return false
 }
 
 type Circle struct {
-	mu            sync.RWMutex
-	serversList   *list.List
-	serversMap    map[uint32]*list.Element
-	servers       []*dataKeeperContainer
-	activeServers int
+	mu          sync.RWMutex
+	sectors     uint32
+	serversList *list.List
+	serversMap  map[uint32]*list.Element
+	servers     []*dataKeeperContainer
+	serversByID map[string]*ringServer
+	calls       CallPolicy // used to request loads of servers on placement
 }
 
-func NewCircle() *Circle {
+// NewCircle creates circle with given number of sectors, every position on the circle is a sector
+func NewCircle(sectors uint32) *Circle {
 	return &Circle{
-		servers:     make([]*dataKeeperContainer, entities.CircleSectors),
+		sectors:     sectors,
+		servers:     make([]*dataKeeperContainer, sectors),
 		serversMap:  make(map[uint32]*list.Element),
 		serversList: list.New(),
+		serversByID: make(map[string]*ringServer),
+		calls:       normalizeCallPolicy(CallPolicy{}),
+	}
+}
+
+// Sectors returns number of sectors on the circle
+func (c *Circle) Sectors() uint32 {
+	return c.sectors
+}
+
+// AddServer puts server on single position which equalizes utilization best, see PlanPlacement
+func (c *Circle) AddServer(ctx context.Context, serverID string, srv storager.DataKeeper) error {
+	plan, err := c.PlanPlacement(ctx, serverID, srv)
+	if err != nil {
+		return err
+	}
+	return c.ApplyPlacement(plan, srv)
+}
+
+// AddVirtualServer puts server on vnodes positions, see PlanVirtualPlacement
+func (c *Circle) AddVirtualServer(ctx context.Context, serverID string, srv storager.DataKeeper, vnodes int) error {
+	plan, err := c.PlanVirtualPlacement(ctx, serverID, srv, vnodes)
+	if err != nil {
+		return err
+	}
+	return c.ApplyPlacement(plan, srv)
+}
+
+// RestoreServer puts server on the circle at known position, used to rebuild circle from stored topology.
+// Server with several positions is restored position by position, state of the first one is used.
+func (c *Circle) RestoreServer(serverID string, position uint32, state entities.NodeState, srv storager.DataKeeper) error {
+	if position >= c.sectors {
+		return fmt.Errorf("position %d is out of circle", position)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.servers[position] != nil {
+		return fmt.Errorf("position %d is already taken by %s", position, c.servers[position].serverID)
+	}
+	server, ok := c.serversByID[serverID]
+	if !ok {
+		server = &ringServer{
+			state:    state,
+			serverID: serverID,
+			storage:  srv,
+		}
 	}
+	c.insertSorted(&dataKeeperContainer{position: position, ringServer: server})
+	return nil
 }
 
-func (c *Circle) AddServer(serverID string, srv storager.DataKeeper) (startRange, newEndRange, oldEndRange uint32, err error) {
-	container := &dataKeeperContainer{
-		state:    nodeStateNotReady,
-		serverID: serverID,
-		storage:  srv,
-	}
-	switch c.activeServers {
-	case 0:
-		c.mu.Lock()
-		container.position = 360 - 1
-		el := c.serversList.PushBack(container)
-		c.serversMap[container.position] = el
-		c.servers[container.position] = container
-		c.mu.Unlock()
-		startRange = 0
-		newEndRange = container.position
-		oldEndRange = container.position
-	case 1:
-		c.mu.Lock()
-		container.position = 180 - 1
-		el := c.serversList.PushFront(container)
-		c.serversMap[container.position] = el
-		c.servers[container.position] = container
-		c.mu.Unlock()
-		startRange = 0
-		newEndRange = container.position
-		oldEndRange = 360 - 1
-	default:
-		from, to, err := c.findExtendCandidate()
-		if err != nil {
-			return 0, 0, 0, fmt.Errorf("error find extend candidate: %w", err)
+// insertSorted puts server position into servers list keeping it sorted by position. Caller must hold the lock.
+func (c *Circle) insertSorted(container *dataKeeperContainer) {
+	var el *list.Element
+	for next := c.serversList.Front(); next != nil; next = next.Next() {
+		if next.Value.(*dataKeeperContainer).position > container.position {
+			el = c.serversList.InsertBefore(container, next)
+			break
 		}
-		position := (from + to) / 2
-		container.position = position
-		el := c.serversList.InsertBefore(container, c.serversMap[to])
-		c.serversMap[position] = el
-		c.servers[position] = container
-		newEndRange = position
-		startRange = from
-		oldEndRange = to
 	}
+	if el == nil {
+		el = c.serversList.PushBack(container)
+	}
+	c.serversMap[container.position] = el
+	c.servers[container.position] = container
+	container.positions = append(container.positions, container.position)
+	c.serversByID[container.serverID] = container.ringServer
+}
+
+// RemoveServer removes server from the circle, its sectors are served by next servers clockwise.
+// Returns positions server had.
+func (c *Circle) RemoveServer(serverID string) ([]uint32, error) {
 	c.mu.Lock()
-	c.activeServers++
-	c.mu.Unlock()
-	return startRange, newEndRange, oldEndRange, nil
+	defer c.mu.Unlock()
+	server, ok := c.serversByID[serverID]
+	if !ok {
+		return nil, fmt.Errorf("server %s not found in circle", serverID)
+	}
+	for _, position := range server.positions {
+		c.serversList.Remove(c.serversMap[position])
+		delete(c.serversMap, position)
+		c.servers[position] = nil
+	}
+	delete(c.serversByID, serverID)
+	return server.positions, nil
 }
 
-func (c *Circle) MarkServerReady(serverID string) {
+// MarkServerDraining marks server as draining before removal, it keeps serving data until it is removed.
+// Returns ErrLastDataKeeper if no other server can take its data.
+func (c *Circle) MarkServerDraining(serverID string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	for _, server := range c.servers {
-		if server == nil {
-			continue
+	server, ok := c.serversByID[serverID]
+	if !ok {
+		return fmt.Errorf("server %s not found in circle", serverID)
+	}
+	for _, other := range c.serversByID {
+		if other.serverID != serverID && other.state == entities.NodeStateReady {
+			server.state = entities.NodeStateDraining
+			return nil
 		}
-		if server.serverID == serverID {
-			server.state = nodeStateReady
-			return
+	}
+	return ErrLastDataKeeper
+}
+
+// HasServer checks that server is already on the circle
+func (c *Circle) HasServer(serverID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.serversByID[serverID]
+	return ok
+}
+
+// storageOf returns storage of the server on the circle
+func (c *Circle) storageOf(serverID string) (storager.DataKeeper, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	server, ok := c.serversByID[serverID]
+	if !ok {
+		return nil, false
+	}
+	return server.storage, true
+}
+
+// GetServerRange returns range of positions served by the first position of server [from, to] and server itself
+func (c *Circle) GetServerRange(serverID string) (from, to uint32, srv storager.DataKeeper, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	server, ok := c.serversByID[serverID]
+	if !ok {
+		return 0, 0, nil, fmt.Errorf("server %s not found in circle", serverID)
+	}
+	position := server.positions[0]
+	for _, p := range server.positions {
+		position = min(position, p)
+	}
+	if prev := c.serversMap[position].Prev(); prev != nil {
+		from = prev.Value.(*dataKeeperContainer).position + 1
+	}
+	return from, position, server.storage, nil
+}
+
+// Nodes returns snapshot of servers positions on the circle
+func (c *Circle) Nodes() []*entities.RingNode {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make([]*entities.RingNode, 0, c.serversList.Len())
+	for el := c.serversList.Front(); el != nil; el = el.Next() {
+		server := el.Value.(*dataKeeperContainer)
+		node := &entities.RingNode{
+			Position: server.position,
+			ServerID: server.serverID,
+			State:    server.state,
+			Sectors:  c.sectors,
+		}
+		if remote, ok := server.storage.(remoteDataKeeper); ok {
+			node.Address = remote.Address()
 		}
+		result = append(result, node)
 	}
+	return result
+}
+
+func (c *Circle) MarkServerReady(serverID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if server, ok := c.serversByID[serverID]; ok {
+		server.state = entities.NodeStateReady
+	}
+}
+
+// ServingServers returns number of distinct serving servers, virtual positions of one server are counted once
+func (c *Circle) ServingServers() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	serving := make(map[string]struct{})
+	for el := c.serversList.Front(); el != nil; el = el.Next() {
+		if server := el.Value.(*dataKeeperContainer); isServing(server) {
+			serving[server.serverID] = struct{}{}
+		}
+	}
+	return len(serving)
 }
 
 // GetServerForChunk returns server which can serve chunk
-// can be optimized for fewer iterations
 func (c *Circle) GetServerForChunk(chunk *entities.FileChunk) (srv storager.DataKeeper, serverID string, err error) {
-	circlePosition := chunk.Hash() % entities.CircleSectors
-	return c.GetServerForPosition(circlePosition)
+	return c.GetServerForPosition(chunk.Sector(c.sectors))
 }
 
+// GetServerForPosition returns first serving server clockwise from position.
+// Servers which are not rebalanced yet are skipped, next server on circle still keeps their data.
 func (c *Circle) GetServerForPosition(circlePosition uint32) (srv storager.DataKeeper, serverID string, err error) {
+	replicas := c.GetServersForPosition(circlePosition, 1)
+	if len(replicas) == 0 {
+		return nil, "", ErrNoDataKeepers
+	}
+	return replicas[0].Storage, replicas[0].ServerID, nil
+}
+
+// GetServersForChunk returns up to n distinct serving servers which keep chunk, see GetServersForPosition
+func (c *Circle) GetServersForChunk(chunk *entities.FileChunk, n int) []Replica {
+	return c.GetServersForPosition(chunk.Sector(c.sectors), n)
+}
+
+// GetServersForPosition returns up to n distinct serving servers clockwise from position.
+// First server is primary owner of the position, others keep replicas.
+func (c *Circle) GetServersForPosition(circlePosition uint32, n int) []Replica {
+	return c.replicas(circlePosition, n, isServing)
+}
+
+// GetWriteServersForChunk returns up to n serving owners of chunk and incoming owners: servers which take over
+// sector of the chunk in rebalance in progress. Sector may be copied to incoming owner before chunk is written,
+// so chunk written during rebalance is saved to both.
+func (c *Circle) GetWriteServersForChunk(chunk *entities.FileChunk, n int) (serving, incoming []Replica) {
+	circlePosition := chunk.Sector(c.sectors)
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	for i := circlePosition; i < entities.CircleSectors; i++ {
-		if c.servers[i] == nil {
-			continue
-		}
-		if c.servers[i].state == nodeStateReady {
-			return c.servers[i].storage, c.servers[i].serverID, nil
-		}
-		// probably server not rebalanced yet. expect that next server on circle is ready and can serve
-		if int(i+1) >= len(c.servers) {
-			// this is the latest server in circle, so we need to check first server in circle
-			for j := 0; j < len(c.servers); j++ {
-				if c.servers[j] == nil {
-					continue
-				}
-				if c.servers[j].state == nodeStateReady {
-					return c.servers[j].storage, c.servers[i].serverID, nil
-				}
-			}
+	servingOwners := c.owners(circlePosition, n, isServing)
+	for _, owner := range c.owners(circlePosition, n, willServe) {
+		if !containsServer(servingOwners, owner.serverID) {
+			incoming = append(incoming, Replica{ServerID: owner.serverID, Storage: owner.storage})
 		}
 	}
-	return nil, "", fmt.Errorf("no servers in circle")
+	return toReplicas(servingOwners), incoming
+}
+
+// getAllServersForChunk returns serving and incoming owners of chunk, servers in rebalance may already keep copy of the chunk
+func (c *Circle) getAllServersForChunk(chunk *entities.FileChunk, n int) []Replica {
+	serving, incoming := c.GetWriteServersForChunk(chunk, n)
+	return append(serving, incoming...)
+}
+
+// ownsSector reports whether server is one of n serving or incoming owners of sector
+func (c *Circle) ownsSector(serverID string, sector uint32, n int) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return containsServer(c.owners(sector, n, isServing), serverID) ||
+		containsServer(c.owners(sector, n, willServe), serverID)
+}
+
+func (c *Circle) replicas(circlePosition uint32, n int, filter serverFilter) []Replica {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return toReplicas(c.owners(circlePosition, n, filter))
+}
+
+func toReplicas(owners []*dataKeeperContainer) []Replica {
+	result := make([]Replica, 0, len(owners))
+	for _, owner := range owners {
+		result = append(result, Replica{ServerID: owner.serverID, Storage: owner.storage})
+	}
+	return result
 }
 
-func (c *Circle) findExtendCandidate() (from, to uint32, err error) {
-	utilization := float64(0)
-	candidate := uint32(0)
-	var (
-		wg      sync.WaitGroup
-		errList = make([]error, 0, len(c.servers))
-	)
+// owners walks circle clockwise from position and collects up to n distinct servers matching filter.
+// Caller must hold the lock.
+func (c *Circle) owners(circlePosition uint32, n int, filter serverFilter) []*dataKeeperContainer {
+	return c.ownersFrom(c.firstFrom(circlePosition), n, filter)
+}
 
-	for i := 0; i < len(c.servers); i++ {
-		if c.servers[i] == nil {
-			continue
+// firstFrom returns first server position clockwise from given position. Caller must hold the lock.
+func (c *Circle) firstFrom(circlePosition uint32) *list.Element {
+	for el := c.serversList.Front(); el != nil; el = el.Next() {
+		if el.Value.(*dataKeeperContainer).position >= circlePosition {
+			return el
 		}
-		wg.Add(1)
-		go func(j int) {
-			defer wg.Done()
-			usage, err := c.servers[j].storage.GetUsage()
-			if err != nil {
-				c.mu.Lock()
-				defer c.mu.Unlock()
-				errList = append(errList, err)
-				return
-			}
-			c.mu.Lock()
-			if usage >= utilization {
-				utilization = usage
-				candidate = uint32(j)
-			}
-			c.mu.Unlock()
-		}(i)
-	}
-	wg.Wait()
-	if len(errList) > 0 {
-		return 0, 0, fmt.Errorf("error get usage: %w", errList[0])
 	}
-	prevID := uint32(0)
-	prev := c.serversMap[candidate].Prev()
-	if prev != nil {
-		prevID = prev.Value.(*dataKeeperContainer).position
+	return c.serversList.Front()
+}
+
+// ownersFrom walks circle clockwise from start element, see owners. Caller must hold the lock.
+func (c *Circle) ownersFrom(start *list.Element, n int, filter serverFilter) []*dataKeeperContainer {
+	result := make([]*dataKeeperContainer, 0, n)
+	el := start
+	for i := 0; i < c.serversList.Len() && len(result) < n; i++ {
+		server := el.Value.(*dataKeeperContainer)
+		if filter(server) && !containsServer(result, server.serverID) {
+			result = append(result, server)
+		}
+		if el = el.Next(); el == nil {
+			el = c.serversList.Front()
+		}
 	}
-	return prevID, candidate, nil
+	return result
 }
 
 func (c *Circle) PrintServerPositions() {
-	prevID := 0
+	prevID := uint32(0)
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	for i, server := range c.servers {
-		if server == nil {
-			continue
-		}
-		fmt.Printf("%s(%d): [%d-%d]\n", server.serverID, server.position, prevID, i)
-		prevID = i
+	for el := c.serversList.Front(); el != nil; el = el.Next() {
+		server := el.Value.(*dataKeeperContainer)
+		fmt.Printf("%s(%d): [%d-%d]\n", server.serverID, server.position, prevID, server.position)
+		prevID = server.position
 	}
 }
@@ -1,13 +1,17 @@
 package orchestrator_test
 
 import (
+	"context"
+	"errors"
 	"extendable_storage/internal/entities"
 	"extendable_storage/internal/service/orchestrator"
 	"extendable_storage/internal/service/storager"
 	"fmt"
 	"math/rand"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -17,15 +21,14 @@ import (
 
 func TestCircle_AddServer(t *testing.T) {
 	// given
-	circle := orchestrator.NewCircle()
+	circle := orchestrator.NewCircle(entities.CircleSectors)
 
 	// when
 	mck := gomock.NewController(t)
 	keys := map[string]struct{}{"A": {}, "B": {}, "C": {}, "D": {}, "E": {}, "F": {}, "G": {}, "H": {}, "I": {}, "J": {}}
 	for key := range keys {
-		srv := storager.NewMockDataKeeper(mck)
-		srv.EXPECT().GetUsage().Return(randUsage(), nil).AnyTimes()
-		_, _, _, err := circle.AddServer(fmt.Sprintf("srv%s", key), srv)
+		srv := newLoadedKeeper(mck, 100*1024*1024, randSectorsUsage())
+		err := circle.AddServer(context.Background(), fmt.Sprintf("srv%s", key), srv)
 		require.NoError(t, err)
 		circle.MarkServerReady(fmt.Sprintf("srv%s", key))
 	}
@@ -47,8 +50,409 @@ func TestCircle_AddServer(t *testing.T) {
 	})
 }
 
-func randUsage() float64 {
-	minNum := 10
-	maxNum := 100
-	return float64(minNum + rand.Intn(maxNum-minNum+1))
+func randSectorsUsage() map[uint32]uint64 {
+	result := make(map[uint32]uint64)
+	for i := 0; i < 50; i++ {
+		result[uint32(rand.Intn(entities.CircleSectors))] += uint64(rand.Intn(1024 * 1024))
+	}
+	return result
+}
+
+func newLoadedKeeper(mck *gomock.Controller, capacity uint64, sectors map[uint32]uint64) *storager.MockDataKeeper {
+	srv := storager.NewMockDataKeeper(mck)
+	srv.EXPECT().GetCapacity(gomock.Any()).Return(capacity, nil).AnyTimes()
+	srv.EXPECT().GetSectorsUsage(gomock.Any()).Return(sectors, nil).AnyTimes()
+	return srv
+}
+
+func TestCircle_RestoreServer(t *testing.T) {
+	// given
+	circle := orchestrator.NewCircle(entities.CircleSectors)
+	mck := gomock.NewController(t)
+	positions := map[string]uint32{"srvA": entities.CircleSectors - 1, "srvB": 179, "srvC": 89}
+
+	// when
+	for name, position := range positions {
+		require.NoError(t, circle.RestoreServer(name, position, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	}
+
+	// then
+	require.True(t, circle.HasServer("srvA"))
+	require.False(t, circle.HasServer("srvD"))
+	require.Len(t, circle.Nodes(), len(positions))
+	table := map[string][2]uint32{
+		"srvC": {0, 89},
+		"srvB": {90, 179},
+		"srvA": {180, entities.CircleSectors - 1},
+	}
+	for name, expectedRange := range table {
+		from, to, _, err := circle.GetServerRange(name)
+		require.NoError(t, err)
+		require.Equal(t, expectedRange, [2]uint32{from, to})
+		_, srvName, err := circle.GetServerForPosition(from)
+		require.NoError(t, err)
+		require.Equal(t, name, srvName)
+	}
+
+	t.Run("should not restore server on taken position", func(t *testing.T) {
+		require.Error(t, circle.RestoreServer("srvD", 89, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	})
+}
+
+func TestCircle_GetServersForPosition(t *testing.T) {
+	// given
+	circle := orchestrator.NewCircle(entities.CircleSectors)
+	mck := gomock.NewController(t)
+	require.NoError(t, circle.RestoreServer("srvA", entities.CircleSectors-1, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvB", 179, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvC", 89, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvD", 269, entities.NodeStateNotReady, storager.NewMockDataKeeper(mck)))
+
+	table := map[uint32][]string{
+		0:   {"srvC", "srvB"},
+		100: {"srvB", "srvA"},
+		200: {"srvA", "srvC"},
+		300: {"srvA", "srvC"},
+	}
+	for position, expected := range table {
+		// when
+		replicas := circle.GetServersForPosition(position, 2)
+
+		// then
+		names := make([]string, 0, len(replicas))
+		for _, replica := range replicas {
+			names = append(names, replica.ServerID)
+		}
+		require.Equal(t, expected, names, "position %d", position)
+	}
+
+	t.Run("should return all servers if replication factor is bigger than cluster", func(t *testing.T) {
+		require.Len(t, circle.GetServersForPosition(0, 5), 3)
+	})
+
+	t.Run("should count serving servers", func(t *testing.T) {
+		require.Equal(t, 3, circle.ServingServers())
+	})
+}
+
+func TestCircle_GetWriteServersForChunk(t *testing.T) {
+	// given
+	circle := orchestrator.NewCircle(entities.CircleSectors)
+	mck := gomock.NewController(t)
+	require.NoError(t, circle.RestoreServer("srvA", entities.CircleSectors-1, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvB", 179, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvC", 89, entities.NodeStateDraining, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvD", 269, entities.NodeStateNotReady, storager.NewMockDataKeeper(mck)))
+
+	table := []struct {
+		name     string
+		from, to uint32
+		serving  []string
+		incoming []string
+	}{
+		{name: "joining server should get writes of its range", from: 180, to: 269, serving: []string{"srvA"}, incoming: []string{"srvD"}},
+		{name: "successor should get writes of draining server", from: 0, to: 89, serving: []string{"srvC"}, incoming: []string{"srvB"}},
+		{name: "range out of rebalance has no incoming owners", from: 90, to: 179, serving: []string{"srvB"}},
+	}
+	for _, tc := range table {
+		t.Run(tc.name, func(t *testing.T) {
+			// when
+			serving, incoming := circle.GetWriteServersForChunk(chunkInSectors(tc.from, tc.to), 1)
+
+			// then
+			require.Equal(t, tc.serving, replicaNames(serving))
+			require.Equal(t, tc.incoming, replicaNames(incoming))
+		})
+	}
+}
+
+// chunkInSectors returns chunk with random id which sector is in range [from, to]
+func chunkInSectors(from, to uint32) *entities.FileChunk {
+	for {
+		chunk := &entities.FileChunk{FileID: uuid.NewString(), ChunkID: uuid.NewString()}
+		if sector := chunk.Sector(entities.CircleSectors); sector >= from && sector <= to {
+			return chunk
+		}
+	}
+}
+
+func replicaNames(replicas []orchestrator.Replica) []string {
+	var names []string
+	for _, replica := range replicas {
+		names = append(names, replica.ServerID)
+	}
+	return names
+}
+
+func TestCircle_PlanRebalance(t *testing.T) {
+	// given
+	circle := orchestrator.NewCircle(entities.CircleSectors)
+	mck := gomock.NewController(t)
+	require.NoError(t, circle.RestoreServer("srvA", entities.CircleSectors-1, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvB", 179, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvC", 89, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvD", 269, entities.NodeStateNotReady, storager.NewMockDataKeeper(mck)))
+
+	t.Run("single copy moves only range of new server", func(t *testing.T) {
+		// when
+		plan := circle.PlanRebalance("srvD", 1)
+
+		// then
+		require.Len(t, plan.Transfers, 1)
+		require.Equal(t, [4]any{uint32(180), uint32(269), "srvA", "srvD"}, transferSummary(plan.Transfers[0]))
+		require.Len(t, plan.Drops, 1)
+		require.Equal(t, [3]any{uint32(180), uint32(269), "srvA"}, dropSummary(plan.Drops[0]))
+	})
+
+	t.Run("new server gets replicas of previous range", func(t *testing.T) {
+		// when
+		plan := circle.PlanRebalance("srvD", 2)
+
+		// then
+		require.Len(t, plan.Transfers, 2)
+		require.Equal(t, [4]any{uint32(90), uint32(179), "srvB", "srvD"}, transferSummary(plan.Transfers[0]))
+		require.Equal(t, [4]any{uint32(180), uint32(269), "srvA", "srvD"}, transferSummary(plan.Transfers[1]))
+		require.Len(t, plan.Drops, 2)
+		require.Equal(t, [3]any{uint32(90), uint32(179), "srvA"}, dropSummary(plan.Drops[0]))
+		require.Equal(t, [3]any{uint32(180), uint32(269), "srvC"}, dropSummary(plan.Drops[1]))
+	})
+
+	t.Run("server joining small cluster copies everything and drops nothing", func(t *testing.T) {
+		// when
+		plan := circle.PlanRebalance("srvD", 4)
+
+		// then
+		var sectors uint32
+		for _, transfer := range plan.Transfers {
+			require.Equal(t, "srvD", transfer.TargetID)
+			sectors += transfer.To - transfer.From + 1
+		}
+		require.Equal(t, uint32(entities.CircleSectors), sectors)
+		require.Empty(t, plan.Drops)
+	})
+}
+
+func transferSummary(transfer orchestrator.SectorTransfer) [4]any {
+	return [4]any{transfer.From, transfer.To, transfer.SourceID, transfer.TargetID}
+}
+
+func dropSummary(drop orchestrator.SectorDrop) [3]any {
+	return [3]any{drop.From, drop.To, drop.ServerID}
+}
+
+func TestCircle_RemoveServer(t *testing.T) {
+	// given
+	circle := orchestrator.NewCircle(entities.CircleSectors)
+	mck := gomock.NewController(t)
+	require.NoError(t, circle.RestoreServer("srvA", entities.CircleSectors-1, entities.NodeStateReady, newLoadedKeeper(mck, 100, nil)))
+	require.NoError(t, circle.RestoreServer("srvB", 179, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+	require.NoError(t, circle.RestoreServer("srvC", 89, entities.NodeStateReady, storager.NewMockDataKeeper(mck)))
+
+	t.Run("draining server should serve data and plan its sectors to successor", func(t *testing.T) {
+		// when
+		require.NoError(t, circle.MarkServerDraining("srvB"))
+
+		// then
+		_, srvName, err := circle.GetServerForPosition(100)
+		require.NoError(t, err)
+		require.Equal(t, "srvB", srvName)
+		plan := circle.PlanRemoval("srvB", 1)
+		require.Len(t, plan.Transfers, 1)
+		require.Equal(t, [4]any{uint32(90), uint32(179), "srvB", "srvA"}, transferSummary(plan.Transfers[0]))
+		require.Len(t, plan.Drops, 1)
+		require.Equal(t, [3]any{uint32(90), uint32(179), "srvB"}, dropSummary(plan.Drops[0]))
+	})
+
+	t.Run("removed server sectors should be served by successor", func(t *testing.T) {
+		// when
+		positions, err := circle.RemoveServer("srvB")
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, []uint32{179}, positions)
+		require.False(t, circle.HasServer("srvB"))
+		_, srvName, err := circle.GetServerForPosition(100)
+		require.NoError(t, err)
+		require.Equal(t, "srvA", srvName)
+	})
+
+	t.Run("last serving server should not be drained", func(t *testing.T) {
+		// given
+		_, err := circle.RemoveServer("srvC")
+		require.NoError(t, err)
+
+		// when, then
+		require.ErrorIs(t, circle.MarkServerDraining("srvA"), orchestrator.ErrLastDataKeeper)
+	})
+
+	t.Run("server should be added opposite to the only server", func(t *testing.T) {
+		// when
+		err := circle.AddServer(context.Background(), "srvD", newLoadedKeeper(mck, 100, nil))
+
+		// then
+		require.NoError(t, err)
+		from, to, _, err := circle.GetServerRange("srvD")
+		require.NoError(t, err)
+		require.Equal(t, [2]uint32{0, 179}, [2]uint32{from, to})
+	})
+}
+
+func TestCircle_AddVirtualServer(t *testing.T) {
+	// given
+	circle := orchestrator.NewCircle(1 << 16)
+	mck := gomock.NewController(t)
+	vnodes := map[string]int{"srvA": 100, "srvB": 100, "srvC": 200}
+
+	// when
+	for name, count := range vnodes {
+		require.NoError(t, circle.AddVirtualServer(context.Background(), name, newLoadedKeeper(mck, 100, nil), count))
+		circle.MarkServerReady(name)
+	}
+
+	// then
+	require.Equal(t, len(vnodes), circle.ServingServers(), "virtual positions are counted once")
+	chunks := make([]*entities.FileChunk, 0, 20000)
+	load := make(map[string]int)
+	for i := 0; i < cap(chunks); i++ {
+		chunk := &entities.FileChunk{FileID: uuid.NewString(), ChunkID: uuid.NewString()}
+		chunks = append(chunks, chunk)
+		_, srvName, err := circle.GetServerForChunk(chunk)
+		require.NoError(t, err)
+		load[srvName]++
+	}
+	for name, count := range vnodes {
+		share := float64(load[name]) / float64(len(chunks))
+		t.Logf("%s share: %.3f", name, share)
+		require.InDelta(t, float64(count)/400, share, 0.1)
+	}
+
+	t.Run("virtual server should be restored from nodes", func(t *testing.T) {
+		// given
+		nodes := circle.Nodes()
+		require.Len(t, nodes, 400)
+		restored := orchestrator.NewCircle(nodes[0].Sectors)
+
+		// when
+		for _, node := range nodes {
+			require.NoError(t, restored.RestoreServer(node.ServerID, node.Position, node.State, storager.NewMockDataKeeper(mck)))
+		}
+
+		// then
+		for _, chunk := range chunks[:1000] {
+			_, expected, err := circle.GetServerForChunk(chunk)
+			require.NoError(t, err)
+			_, srvName, err := restored.GetServerForChunk(chunk)
+			require.NoError(t, err)
+			require.Equal(t, expected, srvName)
+		}
+		positions, err := restored.RemoveServer("srvC")
+		require.NoError(t, err)
+		require.Len(t, positions, 200)
+	})
+
+	t.Run("server should not take more positions than circle has", func(t *testing.T) {
+		// when, then
+		require.Error(t, orchestrator.NewCircle(entities.CircleSectors).AddVirtualServer(context.Background(), "srvD", newLoadedKeeper(mck, 100, nil), entities.CircleSectors+1))
+	})
+}
+
+func TestCircle_PlanPlacement(t *testing.T) {
+	// given
+	circle := orchestrator.NewCircle(entities.CircleSectors)
+	mck := gomock.NewController(t)
+	sectorsA := make(map[uint32]uint64)
+	for sector := uint32(200); sector < 210; sector++ {
+		sectorsA[sector] = 10
+	}
+	require.NoError(t, circle.RestoreServer("srvA", entities.CircleSectors-1, entities.NodeStateReady, newLoadedKeeper(mck, 100, sectorsA)))
+	require.NoError(t, circle.RestoreServer("srvB", 179, entities.NodeStateReady, newLoadedKeeper(mck, 100, map[uint32]uint64{10: 20})))
+
+	t.Run("new server should take half of the most used server data", func(t *testing.T) {
+		// when
+		plan, err := circle.PlanPlacement(context.Background(), "srvC", newLoadedKeeper(mck, 100, nil))
+
+		// then
+		require.NoError(t, err)
+		require.False(t, circle.HasServer("srvC"))
+		require.Equal(t, []uint32{204}, plan.Positions)
+		require.Equal(t, []orchestrator.PlacementMove{{From: 180, To: 204, SourceID: "srvA", Bytes: 50}}, plan.Moves)
+		require.Equal(t, map[string]float64{"srvA": 50, "srvC": 50}, plan.Utilization)
+	})
+
+	t.Run("bigger server should take more data", func(t *testing.T) {
+		// when
+		plan, err := circle.PlanPlacement(context.Background(), "srvC", newLoadedKeeper(mck, 300, nil))
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, []uint32{207}, plan.Positions)
+		require.Equal(t, uint64(80), plan.MovedBytes())
+	})
+
+	t.Run("plan should be applied", func(t *testing.T) {
+		// given
+		srv := newLoadedKeeper(mck, 100, nil)
+		plan, err := circle.PlanPlacement(context.Background(), "srvC", srv)
+		require.NoError(t, err)
+
+		// when
+		require.NoError(t, circle.ApplyPlacement(plan, srv))
+
+		// then
+		from, to, _, err := circle.GetServerRange("srvC")
+		require.NoError(t, err)
+		require.Equal(t, [2]uint32{180, 204}, [2]uint32{from, to})
+		require.Error(t, circle.ApplyPlacement(plan, srv))
+	})
+}
+
+func TestCircle_PlanPlacementRetry(t *testing.T) {
+	// given
+	circle := orchestrator.NewCircle(entities.CircleSectors)
+	mck := gomock.NewController(t)
+	srvA := storager.NewMockDataKeeper(mck)
+	srvA.EXPECT().GetCapacity(gomock.Any()).Return(uint64(100), nil).AnyTimes()
+	require.NoError(t, circle.RestoreServer("srvA", 179, entities.NodeStateReady, srvA))
+
+	t.Run("failed usage request should be retried", func(t *testing.T) {
+		// given
+		gomock.InOrder(
+			srvA.EXPECT().GetSectorsUsage(gomock.Any()).Return(nil, errors.New("connection reset")),
+			srvA.EXPECT().GetSectorsUsage(gomock.Any()).Return(map[uint32]uint64{10: 20, 200: 20}, nil),
+		)
+
+		// when
+		plan, err := circle.PlanPlacement(context.Background(), "srvC", newLoadedKeeper(mck, 100, nil))
+
+		// then
+		require.NoError(t, err)
+		require.Equal(t, map[string]float64{"srvA": 20, "srvC": 20}, plan.Utilization)
+	})
+
+	t.Run("missing data should not be retried", func(t *testing.T) {
+		// given
+		srvA.EXPECT().GetSectorsUsage(gomock.Any()).Return(nil, os.ErrNotExist)
+
+		// when
+		_, err := circle.PlanPlacement(context.Background(), "srvC", newLoadedKeeper(mck, 100, nil))
+
+		// then
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("hung server should be abandoned when context is done", func(t *testing.T) {
+		// given
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		srvA.EXPECT().GetSectorsUsage(gomock.Any()).DoAndReturn(func(ctx context.Context) (map[uint32]uint64, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+		// when
+		_, err := circle.PlanPlacement(ctx, "srvC", newLoadedKeeper(mck, 100, nil))
+
+		// then
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
 }
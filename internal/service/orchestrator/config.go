@@ -0,0 +1,80 @@
+package orchestrator
+
+import (
+	"extendable_storage/internal/entities"
+	"time"
+)
+
+// Config is a replication and circle settings of the cluster
+type Config struct {
+	// ReplicationFactor is a number of distinct successive ring owners which keep every chunk
+	ReplicationFactor int
+	// WriteQuorum is a number of owners which must confirm chunk saving, majority of ReplicationFactor by default
+	WriteQuorum int
+	// Sectors is a number of sectors on the circle, entities.CircleSectors by default.
+	// Storage nodes should be configured with the same number.
+	Sectors uint32
+	// VNodeCapacityMB enables virtual nodes: node takes one position on the circle per VNodeCapacityMB of its capacity.
+	// Zero means one position per node which splits range of the most used node.
+	VNodeCapacityMB int
+	// Calls is a deadline and retry policy of calls to data keepers
+	Calls CallPolicy
+}
+
+// CallPolicy limits time of every call to data keeper by its kind. Failed call is repeated up to MaxAttempts times,
+// pause between attempts starts from InitialBackoff and doubles up to MaxBackoff.
+type CallPolicy struct {
+	// ReadTimeout is a deadline of chunk reading and usage requests
+	ReadTimeout time.Duration
+	// WriteTimeout is a deadline of chunk saving, purging and sector dropping
+	WriteTimeout time.Duration
+	// TransferTimeout is a deadline of sectors loading from other data keeper during rebalance
+	TransferTimeout time.Duration
+	MaxAttempts     int
+	InitialBackoff  time.Duration
+	MaxBackoff      time.Duration
+}
+
+// normalizeConfig fills defaults: single copy of data, majority write quorum, default circle and call policy
+func normalizeConfig(conf *Config) Config {
+	result := Config{ReplicationFactor: 1}
+	if conf != nil {
+		result = *conf
+	}
+	if result.ReplicationFactor < 1 {
+		result.ReplicationFactor = 1
+	}
+	if result.WriteQuorum < 1 {
+		result.WriteQuorum = result.ReplicationFactor/2 + 1
+	}
+	if result.WriteQuorum > result.ReplicationFactor {
+		result.WriteQuorum = result.ReplicationFactor
+	}
+	if result.Sectors == 0 {
+		result.Sectors = entities.CircleSectors
+	}
+	result.Calls = normalizeCallPolicy(result.Calls)
+	return result
+}
+
+func normalizeCallPolicy(policy CallPolicy) CallPolicy {
+	if policy.ReadTimeout <= 0 {
+		policy.ReadTimeout = defaultReadTimeout
+	}
+	if policy.WriteTimeout <= 0 {
+		policy.WriteTimeout = defaultWriteTimeout
+	}
+	if policy.TransferTimeout <= 0 {
+		policy.TransferTimeout = defaultTransferTimeout
+	}
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = defaultMaxAttempts
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaultInitialBackoff
+	}
+	if policy.MaxBackoff < policy.InitialBackoff {
+		policy.MaxBackoff = max(defaultMaxBackoff, policy.InitialBackoff)
+	}
+	return policy
+}
@@ -0,0 +1,231 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/storager"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	dropWorkers = 4
+)
+
+// dropTask is a range of sectors [from, to] which server doesn't own anymore and should drop
+type dropTask struct {
+	serverID string
+	from     uint32
+	to       uint32
+	storage  storager.DataKeeper
+	// bestEffort task is given up after MaxAttempts, it is used for removed server which data is not needed anymore
+	bestEffort bool
+	// done is called once when sectors are dropped or task is given up
+	done func()
+
+	attempt     int
+	notBefore   time.Time
+	skip        map[uint32]struct{} // sectors server owns again, they are kept
+	cancel      context.CancelFunc  // interrupts running attempt
+	finished    chan struct{}       // closed when running attempt is finished
+	interrupted bool
+}
+
+// dropQueue drops stale copies of sectors in background. Failed drop is repeated after exponential backoff
+// until it succeeds, so unreachable server doesn't stop rebalance. Sector is dropped only if server doesn't own it
+// at the moment of drop, and sectors which are loaded to server again are excluded from its pending drops.
+type dropQueue struct {
+	mu      sync.Mutex
+	pending []*dropTask
+	running map[*dropTask]struct{}
+	changed chan struct{} // closed and replaced when tasks are added or finished
+	calls   CallPolicy
+	owns    func(serverID string, sector uint32) bool
+	logger  logger.AppLogger
+}
+
+func newDropQueue(log logger.AppLogger, calls CallPolicy, owns func(serverID string, sector uint32) bool) *dropQueue {
+	return &dropQueue{
+		running: make(map[*dropTask]struct{}),
+		changed: make(chan struct{}),
+		calls:   calls,
+		owns:    owns,
+		logger:  log,
+	}
+}
+
+// run processes tasks until ctx is done, unfinished tasks are lost and must be enqueued again on restart
+func (q *dropQueue) run(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(dropWorkers)
+	for i := 0; i < dropWorkers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				task, taskCtx := q.next(ctx)
+				if task == nil {
+					return
+				}
+				if q.finish(ctx, task, q.drop(taskCtx, task)) {
+					task.done()
+				}
+			}
+		}()
+	}
+}
+
+func (q *dropQueue) push(task *dropTask) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, task)
+	q.notifyLocked()
+}
+
+// excludeSectors keeps sectors [from, to] on server: they are removed from its pending drops
+// and running drop is interrupted and waited for, so sectors can be loaded to server safely after return
+func (q *dropQueue) excludeSectors(ctx context.Context, serverID string, from, to uint32) error {
+	var wait []chan struct{}
+	q.mu.Lock()
+	for _, task := range q.pending {
+		task.exclude(serverID, from, to)
+	}
+	for task := range q.running {
+		if task.exclude(serverID, from, to) {
+			task.interrupted = true
+			task.cancel()
+			wait = append(wait, task.finished)
+		}
+	}
+	q.mu.Unlock()
+	for _, finished := range wait {
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// next waits for task which is ready to run, nil is returned when ctx is done
+func (q *dropQueue) next(ctx context.Context) (*dropTask, context.Context) {
+	for {
+		q.mu.Lock()
+		var (
+			wake   = q.changed
+			now    = time.Now()
+			nextAt time.Time
+		)
+		for i, task := range q.pending {
+			if task.notBefore.After(now) {
+				if nextAt.IsZero() || task.notBefore.Before(nextAt) {
+					nextAt = task.notBefore
+				}
+				continue
+			}
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			taskCtx, cancel := context.WithCancel(ctx)
+			task.cancel, task.finished, task.interrupted = cancel, make(chan struct{}), false
+			q.running[task] = struct{}{}
+			q.mu.Unlock()
+			return task, taskCtx
+		}
+		q.mu.Unlock()
+
+		var timer <-chan time.Time
+		if !nextAt.IsZero() {
+			timer = time.After(time.Until(nextAt))
+		}
+		select {
+		case <-wake:
+		case <-timer:
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+// drop deletes sectors of the task which server doesn't own, consecutive sectors are dropped by one call
+func (q *dropQueue) drop(ctx context.Context, task *dropTask) error {
+	q.mu.Lock()
+	skip := make(map[uint32]struct{}, len(task.skip))
+	for sector := range task.skip {
+		skip[sector] = struct{}{}
+	}
+	q.mu.Unlock()
+	for sector := task.from; sector <= task.to; sector++ {
+		if _, ok := skip[sector]; ok || q.owns(task.serverID, sector) {
+			continue
+		}
+		to := sector
+		for to < task.to {
+			if _, ok := skip[to+1]; ok || q.owns(task.serverID, to+1) {
+				break
+			}
+			to++
+		}
+		from := sector
+		err := callWithTimeout(ctx, q.calls.WriteTimeout, func(ctx context.Context) error {
+			return task.storage.DropChunksInRange(ctx, from, to)
+		})
+		if err != nil {
+			return err
+		}
+		sector = to
+	}
+	return nil
+}
+
+// finish schedules next attempt of failed task, reports whether task is completed
+func (q *dropQueue) finish(ctx context.Context, task *dropTask, err error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, task)
+	task.cancel()
+	close(task.finished)
+	defer q.notifyLocked()
+
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		return false // service is stopped, task is enqueued again on restart
+	case task.interrupted && errors.Is(err, context.Canceled):
+		// sectors were excluded during attempt, rest of them are dropped without delay
+		q.pending = append(q.pending, task)
+		return false
+	default:
+		task.attempt++
+		if task.bestEffort && task.attempt >= q.calls.MaxAttempts {
+			q.logger.Error("error drop sectors, task is given up", err,
+				slog.String("service_id", task.serverID), slog.Int("from", int(task.from)), slog.Int("to", int(task.to)))
+			break
+		}
+		q.logger.Error("error drop sectors, task is postponed", err,
+			slog.String("service_id", task.serverID), slog.Int("from", int(task.from)), slog.Int("to", int(task.to)),
+			slog.Int("attempt", task.attempt))
+		task.notBefore = time.Now().Add(q.calls.backoff(task.attempt))
+		q.pending = append(q.pending, task)
+		return false
+	}
+	return true
+}
+
+func (q *dropQueue) notifyLocked() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// exclude marks sectors of the task in range [from, to] as kept, reports whether task has such sectors
+func (t *dropTask) exclude(serverID string, from, to uint32) bool {
+	if t.serverID != serverID || to < t.from || from > t.to {
+		return false
+	}
+	if t.skip == nil {
+		t.skip = make(map[uint32]struct{})
+	}
+	for sector := max(from, t.from); sector <= min(to, t.to); sector++ {
+		t.skip[sector] = struct{}{}
+	}
+	return true
+}
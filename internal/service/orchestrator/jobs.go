@@ -0,0 +1,404 @@
+package orchestrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/storager"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// RebalanceProgress is a state of the last rebalance job of the server
+type RebalanceProgress struct {
+	ServerID string           `json:"server_id"`
+	Kind     entities.JobKind `json:"kind"`
+	// Done means requests are routed to new owners, stale copies are dropped in background until every sector is source_dropped
+	Done bool `json:"done"`
+	// Sectors is a number of sectors moved by the job, States is a number of sectors in every state
+	Sectors int                          `json:"sectors"`
+	States  map[entities.SectorState]int `json:"states"`
+	// Error is a reason job stopped, job is resumed on restart
+	Error string `json:"error,omitempty"`
+}
+
+// sectorRun is a range of consecutive sectors of the job which are moved the same way
+type sectorRun struct {
+	from  uint32
+	to    uint32
+	moves []*entities.SectorMove
+}
+
+func (r *sectorRun) first() *entities.SectorMove {
+	return r.moves[0]
+}
+
+func (r *sectorRun) setState(state entities.SectorState) {
+	for _, move := range r.moves {
+		move.State = state
+	}
+}
+
+// splitRuns groups consecutive sectors in given state which are moved the same way
+func splitRuns(moves []*entities.SectorMove, state entities.SectorState, same func(a, b *entities.SectorMove) bool) []*sectorRun {
+	var result []*sectorRun
+	var current *sectorRun
+	for _, move := range moves {
+		if move.State != state {
+			current = nil
+			continue
+		}
+		if current != nil && current.to+1 == move.Sector && same(current.first(), move) {
+			current.to = move.Sector
+			current.moves = append(current.moves, move)
+			continue
+		}
+		current = &sectorRun{from: move.Sector, to: move.Sector, moves: []*entities.SectorMove{move}}
+		result = append(result, current)
+	}
+	return result
+}
+
+func sameCopy(a, b *entities.SectorMove) bool {
+	return a.SourceID == b.SourceID && a.Targets.Equal(b.Targets)
+}
+
+func sameDrop(a, b *entities.SectorMove) bool {
+	return a.Drops.Equal(b.Drops)
+}
+
+// movesFromPlan converts ranges of rebalance plan to per sector moves
+func movesFromPlan(plan *RebalancePlan) []*entities.SectorMove {
+	bySector := make(map[uint32]*entities.SectorMove)
+	get := func(sector uint32) *entities.SectorMove {
+		move, ok := bySector[sector]
+		if !ok {
+			move = &entities.SectorMove{Sector: sector, State: entities.SectorStatePending}
+			bySector[sector] = move
+		}
+		return move
+	}
+	for _, transfer := range plan.Transfers {
+		for sector := transfer.From; sector <= transfer.To; sector++ {
+			move := get(sector)
+			move.SourceID = transfer.SourceID
+			move.Targets = append(move.Targets, transfer.TargetID)
+		}
+	}
+	for _, drop := range plan.Drops {
+		for sector := drop.From; sector <= drop.To; sector++ {
+			move := get(sector)
+			move.Drops = append(move.Drops, drop.ServerID)
+		}
+	}
+	result := make([]*entities.SectorMove, 0, len(bySector))
+	for _, move := range bySector {
+		result = append(result, move)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Sector < result[j].Sector
+	})
+	return result
+}
+
+// lockTopology waits until running rebalance job is finished, so topology changes one at a time
+func (s *Service) lockTopology(ctx context.Context) error {
+	select {
+	case s.topologyLock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+func (s *Service) unlockTopology() {
+	<-s.topologyLock
+}
+
+// createJob plans sector moves required by topology change of the server and stores them.
+// Progress of drops of the previous job of the server is not tracked anymore, its sector moves are replaced.
+func (s *Service) createJob(ctx context.Context, serviceID string, kind entities.JobKind) (*entities.RebalanceJob, error) {
+	var plan *RebalancePlan
+	switch kind {
+	case entities.JobKindJoin:
+		plan = s.circle.PlanRebalance(serviceID, s.conf.ReplicationFactor)
+	case entities.JobKindDrain:
+		plan = s.circle.PlanRemoval(serviceID, s.conf.ReplicationFactor)
+	default:
+		return nil, fmt.Errorf("unknown job kind %s", kind)
+	}
+	job := &entities.RebalanceJob{ServerID: serviceID, Kind: kind}
+	if storage, ok := s.circle.storageOf(serviceID); ok {
+		if remote, isRemote := storage.(remoteDataKeeper); isRemote {
+			job.Address = remote.Address()
+		}
+	}
+	s.dropsMu.Lock()
+	defer s.dropsMu.Unlock()
+	s.dropJobs[serviceID] = job
+	if err := s.repoTopology.CreateJob(ctx, job, movesFromPlan(plan)); err != nil {
+		return nil, fmt.Errorf("error save rebalance job: %w", err)
+	}
+	return job, nil
+}
+
+// startJobs runs jobs one by one in background, topology must be locked by caller and it is unlocked when jobs finish
+func (s *Service) startJobs(jobs ...*entities.RebalanceJob) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.unlockTopology()
+		for _, job := range jobs {
+			s.setJobError(job.ServerID, nil)
+			if err := s.runJob(job); err != nil {
+				s.logger.Error("error run rebalance job", err, slog.String("service_id", job.ServerID), slog.String("kind", string(job.Kind)))
+				s.setJobError(job.ServerID, err)
+			}
+		}
+	}()
+}
+
+func (s *Service) setJobError(serviceID string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.jobErrors, serviceID)
+		return
+	}
+	s.jobErrors[serviceID] = err.Error()
+}
+
+// runJob moves sectors of the job step by step, every step is stored, so interrupted job continues where it stopped:
+// sectors are copied to targets and verified, then routing is switched. Job is done when routing is switched,
+// stale copies are dropped by drop queue in background.
+func (s *Service) runJob(job *entities.RebalanceJob) error {
+	moves, err := s.repoTopology.GetSectorMoves(s.ctx, job.ServerID)
+	if err != nil {
+		return fmt.Errorf("error load sector moves: %w", err)
+	}
+	storageOf := s.jobStorages(job)
+	for _, run := range splitRuns(moves, entities.SectorStateCopying, sameCopy) {
+		// copy was interrupted, it is started again
+		run.setState(entities.SectorStatePending)
+	}
+	for _, run := range splitRuns(moves, entities.SectorStatePending, sameCopy) {
+		if err = s.ctx.Err(); err != nil {
+			return err
+		}
+		if err = s.copyRun(job, run, storageOf); err != nil {
+			return err
+		}
+	}
+
+	if err = s.switchJob(job); err != nil {
+		return err
+	}
+	for _, run := range splitRuns(moves, entities.SectorStateVerified, sameCopy) {
+		if err = s.setRunState(job, run, entities.SectorStateSwitched); err != nil {
+			return err
+		}
+	}
+
+	for _, run := range splitRuns(moves, entities.SectorStateSwitched, sameDrop) {
+		s.enqueueDrops(job, run, storageOf)
+	}
+	if err = s.repoTopology.FinishJob(s.ctx, job.ServerID); err != nil {
+		return fmt.Errorf("error finish job: %w", err)
+	}
+	s.logger.Info("rebalance job finished", slog.String("service_id", job.ServerID), slog.String("kind", string(job.Kind)), slog.Int("sectors", len(moves)))
+	return nil
+}
+
+// jobStorages returns lookup of servers taking part in the job. Drained server is not on the circle after switch,
+// so it is remembered at start or connected by stored address after restart.
+func (s *Service) jobStorages(job *entities.RebalanceJob) func(serverID string) (storager.DataKeeper, error) {
+	jobStorage, ok := s.circle.storageOf(job.ServerID)
+	if !ok && job.Address != "" {
+		var err error
+		if jobStorage, err = s.connector(job.ServerID, job.Address); err != nil {
+			s.logger.Error("error connect to job server", err, slog.String("service_id", job.ServerID))
+		}
+	}
+	return func(serverID string) (storager.DataKeeper, error) {
+		if serverID == job.ServerID && jobStorage != nil {
+			return jobStorage, nil
+		}
+		if storage, found := s.circle.storageOf(serverID); found {
+			return storage, nil
+		}
+		return nil, fmt.Errorf("server %s not found in circle", serverID)
+	}
+}
+
+func (s *Service) setRunState(job *entities.RebalanceJob, run *sectorRun, state entities.SectorState) error {
+	if err := s.repoTopology.SetSectorsState(s.ctx, job.ServerID, run.from, run.to, state); err != nil {
+		return fmt.Errorf("error set sectors %d-%d %s: %w", run.from, run.to, state, err)
+	}
+	run.setState(state)
+	return nil
+}
+
+// copyRun loads sectors of the run to every target and verifies that target keeps at least
+// as much data of every sector as source had before copy
+func (s *Service) copyRun(job *entities.RebalanceJob, run *sectorRun, storageOf func(string) (storager.DataKeeper, error)) error {
+	move := run.first()
+	if move.SourceID == "" || len(move.Targets) == 0 {
+		return s.setRunState(job, run, entities.SectorStateVerified)
+	}
+	if err := s.setRunState(job, run, entities.SectorStateCopying); err != nil {
+		return err
+	}
+	source, err := storageOf(move.SourceID)
+	if err != nil {
+		return err
+	}
+	sourceLoad, err := waitLoad(s.ctx, s.conf.Calls, source)
+	if err != nil {
+		return fmt.Errorf("error get sectors usage of %s: %w", move.SourceID, err)
+	}
+	for _, targetID := range move.Targets {
+		target, errT := storageOf(targetID)
+		if errT != nil {
+			return errT
+		}
+		// stale copies of these sectors may wait for drop on target since previous rebalance
+		if err = s.drops.excludeSectors(s.ctx, targetID, run.from, run.to); err != nil {
+			return err
+		}
+		err = s.conf.Calls.transfer(s.ctx, func(ctx context.Context) error {
+			return target.SaveFromSource(ctx, run.from, run.to, source)
+		})
+		if err != nil {
+			return fmt.Errorf("error save sectors %d-%d from source %s to %s: %w", run.from, run.to, move.SourceID, targetID, err)
+		}
+		targetLoad, errL := waitLoad(s.ctx, s.conf.Calls, target)
+		if errL != nil {
+			return fmt.Errorf("error get sectors usage of %s: %w", targetID, errL)
+		}
+		for sector := run.from; sector <= run.to; sector++ {
+			if targetLoad.Sectors[sector] < sourceLoad.Sectors[sector] {
+				return fmt.Errorf("sector %d is not copied to %s: %d of %d bytes",
+					sector, targetID, targetLoad.Sectors[sector], sourceLoad.Sectors[sector])
+			}
+		}
+	}
+	return s.setRunState(job, run, entities.SectorStateVerified)
+}
+
+// switchJob routes requests to new owners: joined server becomes ready, drained server is removed from circle
+func (s *Service) switchJob(job *entities.RebalanceJob) error {
+	switch job.Kind {
+	case entities.JobKindJoin:
+		s.circle.MarkServerReady(job.ServerID)
+		if err := s.saveTopology(s.ctx, job.ServerID); err != nil {
+			return fmt.Errorf("error save topology: %w", err)
+		}
+	case entities.JobKindDrain:
+		if !s.circle.HasServer(job.ServerID) {
+			return nil
+		}
+		positions, err := s.circle.RemoveServer(job.ServerID)
+		if err != nil {
+			return fmt.Errorf("error remove server from circle: %w", err)
+		}
+		for _, position := range positions {
+			if err = s.repoTopology.DeleteNode(s.ctx, position); err != nil {
+				return fmt.Errorf("error delete server from topology: %w", err)
+			}
+		}
+		s.logger.Info("server removed", slog.String("service_id", job.ServerID))
+	}
+	return nil
+}
+
+// enqueueDrops passes stale copies of sectors to drop queue, sectors become source_dropped when all copies are dropped.
+// Removed server may be already unreachable, its data is not needed anymore, so its drop is given up after retries.
+func (s *Service) enqueueDrops(job *entities.RebalanceJob, run *sectorRun, storageOf func(string) (storager.DataKeeper, error)) {
+	remaining := 1 // released below, so run without copies to drop is completed as well
+	dropped := func() {
+		s.dropsMu.Lock()
+		defer s.dropsMu.Unlock()
+		if remaining--; remaining > 0 || s.dropJobs[job.ServerID] != job {
+			return
+		}
+		if err := s.repoTopology.SetSectorsState(s.ctx, job.ServerID, run.from, run.to, entities.SectorStateSourceDropped); err != nil {
+			s.logger.Error("error set sectors dropped", err, slog.String("service_id", job.ServerID))
+		}
+	}
+	tasks := make([]*dropTask, 0, len(run.first().Drops))
+	for _, serverID := range run.first().Drops {
+		storage, err := storageOf(serverID)
+		if err != nil {
+			s.logger.Error("error find server of stale copy", err, slog.String("service_id", serverID))
+			continue
+		}
+		tasks = append(tasks, &dropTask{
+			serverID:   serverID,
+			from:       run.from,
+			to:         run.to,
+			storage:    storage,
+			bestEffort: job.Kind == entities.JobKindDrain && serverID == job.ServerID,
+			done:       dropped,
+		})
+	}
+	s.dropsMu.Lock()
+	remaining += len(tasks)
+	s.dropsMu.Unlock()
+	for _, task := range tasks {
+		s.drops.push(task)
+	}
+	dropped()
+}
+
+// GetRebalanceProgress returns progress of the last rebalance job of the server
+func (s *Service) GetRebalanceProgress(ctx context.Context, serviceID string) (*RebalanceProgress, error) {
+	job, err := s.repoTopology.GetJob(ctx, serviceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRebalanceJobAbsent
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error get rebalance job: %w", err)
+	}
+	return s.jobProgress(ctx, job)
+}
+
+// ListRebalanceProgress returns progress of the last rebalance job of every server
+func (s *Service) ListRebalanceProgress(ctx context.Context) ([]*RebalanceProgress, error) {
+	jobs, err := s.repoTopology.GetJobs(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("error get rebalance jobs: %w", err)
+	}
+	result := make([]*RebalanceProgress, 0, len(jobs))
+	for _, job := range jobs {
+		progress, errP := s.jobProgress(ctx, job)
+		if errP != nil {
+			return nil, errP
+		}
+		result = append(result, progress)
+	}
+	return result, nil
+}
+
+func (s *Service) jobProgress(ctx context.Context, job *entities.RebalanceJob) (*RebalanceProgress, error) {
+	states, err := s.repoTopology.CountSectorStates(ctx, job.ServerID)
+	if err != nil {
+		return nil, fmt.Errorf("error count sector states: %w", err)
+	}
+	progress := &RebalanceProgress{
+		ServerID: job.ServerID,
+		Kind:     job.Kind,
+		Done:     job.Done,
+		States:   states,
+	}
+	for _, count := range states {
+		progress.Sectors += count
+	}
+	s.mu.RLock()
+	progress.Error = s.jobErrors[job.ServerID]
+	s.mu.RUnlock()
+	return progress, nil
+}
@@ -0,0 +1,126 @@
+package orchestrator
+
+import (
+	"context"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/storager"
+	"fmt"
+	"log/slog"
+)
+
+// legacyCircle returns circle with previous number of sectors, nil when layout migration is not required
+func (s *Service) legacyCircle() *Circle {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.legacy
+}
+
+// LayoutMigrationRequired checks that stored topology has other number of sectors than configured
+func (s *Service) LayoutMigrationRequired() bool {
+	return s.legacyCircle() != nil
+}
+
+// restoreLegacyTopology restores circle stored with other number of sectors as legacy circle, it keeps serving
+// chunks which are not moved yet. New circle gets positions scaled to the configured number of sectors,
+// so every server keeps its share of the circle.
+func (s *Service) restoreLegacyTopology(nodes []*entities.RingNode, storages map[string]storager.DataKeeper) error {
+	oldSectors := nodes[0].Sectors
+	if oldSectors > s.conf.Sectors {
+		return fmt.Errorf("circle can't be shrunk from %d to %d sectors", oldSectors, s.conf.Sectors)
+	}
+	legacy := NewCircle(oldSectors)
+	for _, node := range nodes {
+		if node.Sectors != oldSectors {
+			return fmt.Errorf("node %s has %d sectors, expected %d", node.ServerID, node.Sectors, oldSectors)
+		}
+		if node.State != entities.NodeStateReady {
+			return fmt.Errorf("node %s is %s, layout can be migrated only when all nodes are ready", node.ServerID, node.State)
+		}
+		storage := storages[node.ServerID]
+		if err := legacy.RestoreServer(node.ServerID, node.Position, node.State, storage); err != nil {
+			return fmt.Errorf("error restore legacy node %s: %w", node.ServerID, err)
+		}
+		position := scalePosition(node.Position, oldSectors, s.conf.Sectors)
+		if err := s.circle.RestoreServer(node.ServerID, position, node.State, storage); err != nil {
+			return fmt.Errorf("error restore node %s: %w", node.ServerID, err)
+		}
+	}
+	s.mu.Lock()
+	s.legacy = legacy
+	s.mu.Unlock()
+	s.logger.Info("circle layout migration required", slog.Int("from_sectors", int(oldSectors)), slog.Int("to_sectors", int(s.conf.Sectors)))
+	return nil
+}
+
+// scalePosition maps the last sector of server range to the circle with other number of sectors
+func scalePosition(position, from, to uint32) uint32 {
+	return uint32((uint64(position)+1)*uint64(to)/uint64(from)) - 1
+}
+
+// MigrateLayout moves every chunk from its owners on legacy circle to its owners on the new one.
+// Chunks are copied before they are purged from old owners, so interrupted migration can be restarted.
+// Topology is replaced when all chunks are moved.
+func (s *Service) MigrateLayout(ctx context.Context, catalog storager.ChunkCatalog) error {
+	legacy := s.legacyCircle()
+	if legacy == nil {
+		return nil
+	}
+	var migrated, failed int
+	err := catalog.IterateChunks(ctx, func(chunks []*entities.FileChunk) error {
+		for _, chunk := range chunks {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.migrateChunk(ctx, legacy, chunk); err != nil {
+				s.logger.Error("error migrate chunk", err, slog.String("chunk", chunk.String()))
+				failed++
+				continue
+			}
+			migrated++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error iterate chunks: %w", err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("error migrate %d of %d chunks", failed, failed+migrated)
+	}
+	if err = s.repoTopology.ReplaceNodes(ctx, s.circle.Nodes()); err != nil {
+		return fmt.Errorf("error save topology: %w", err)
+	}
+	s.mu.Lock()
+	s.legacy = nil
+	s.mu.Unlock()
+	s.logger.Info("circle layout migrated", slog.Int("chunks", migrated))
+	return nil
+}
+
+func (s *Service) migrateChunk(ctx context.Context, legacy *Circle, chunk *entities.FileChunk) error {
+	oldReplicas := legacy.GetServersForChunk(chunk, s.conf.ReplicationFactor)
+	newReplicas := s.circle.GetServersForChunk(chunk, s.conf.ReplicationFactor)
+	data, _, err := s.getFromReplicas(ctx, chunk, oldReplicas)
+	if err != nil {
+		// chunk is moved already by interrupted migration
+		if _, _, errN := s.getFromReplicas(ctx, chunk, newReplicas); errN == nil {
+			return nil
+		}
+		return err
+	}
+	if err = s.saveToReplicas(ctx, chunk, data, newReplicas, nil); err != nil {
+		return err
+	}
+	for _, replica := range oldReplicas {
+		if containsReplica(newReplicas, replica.ServerID) {
+			continue
+		}
+		storage := replica.Storage
+		err = s.conf.Calls.write(ctx, func(ctx context.Context) error {
+			return storage.PurgeFileChunks(ctx, []*entities.FileChunk{chunk})
+		})
+		if err != nil {
+			return fmt.Errorf("error purge chunk from %s: %w", replica.ServerID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/storager"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	usageWaitTimeout  = 10 * time.Minute
+	usagePollInterval = time.Second
+)
+
+// ServerLoad is a capacity of the server and amount of bytes it stores per sector
+type ServerLoad struct {
+	Capacity uint64
+	Sectors  map[uint32]uint64
+}
+
+func (l ServerLoad) used() uint64 {
+	var total uint64
+	for _, size := range l.Sectors {
+		total += size
+	}
+	return total
+}
+
+// PlacementMove is a range of sectors [From, To] which new server takes over from its current primary owner
+type PlacementMove struct {
+	From     uint32
+	To       uint32
+	SourceID string
+	Bytes    uint64
+}
+
+// PlacementPlan is a proposed placement of new server on the circle, it is applied with ApplyPlacement.
+// Moves and utilization are calculated for primary copies of data.
+type PlacementPlan struct {
+	ServerID  string
+	Positions []uint32
+	Moves     []PlacementMove
+	// Utilization is expected usage percentage of new server and servers which give it sectors. 0 - 100
+	Utilization map[string]float64
+}
+
+// MovedBytes returns amount of bytes new server takes over
+func (p *PlacementPlan) MovedBytes() uint64 {
+	var total uint64
+	for _, move := range p.Moves {
+		total += move.Bytes
+	}
+	return total
+}
+
+// PlanPlacement chooses single position of new server which equalizes utilization best.
+// Range of every serving server is tried to be split at every sector: new server takes sectors before the split point,
+// so its utilization and utilization of split server are calculated from stored bytes per sector and capacities.
+// Position with the lowest maximum utilization in cluster wins, then the one with the lowest maximum of both servers,
+// then the one which gives new server its fair share of circle.
+func (c *Circle) PlanPlacement(ctx context.Context, serverID string, srv storager.DataKeeper) (*PlacementPlan, error) {
+	capacity, loads, err := c.collectLoads(ctx, srv)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	position, err := c.bestPosition(capacity, loads)
+	if err != nil {
+		return nil, err
+	}
+	return c.evaluatePlacement(serverID, []uint32{position}, capacity, loads), nil
+}
+
+// PlanVirtualPlacement calculates vnodes positions of new server derived from hash of its id,
+// so placement doesn't depend on order servers join. Taken position is probed clockwise.
+func (c *Circle) PlanVirtualPlacement(ctx context.Context, serverID string, srv storager.DataKeeper, vnodes int) (*PlacementPlan, error) {
+	if vnodes < 1 {
+		return nil, fmt.Errorf("server %s should have at least one position", serverID)
+	}
+	capacity, loads, err := c.collectLoads(ctx, srv)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.serversList.Len()+vnodes > int(c.sectors) {
+		return nil, fmt.Errorf("not enough free positions on circle for %d virtual nodes", vnodes)
+	}
+	positions := make([]uint32, 0, vnodes)
+	taken := make(map[uint32]struct{}, vnodes)
+	for i := 0; i < vnodes; i++ {
+		position := crc32.ChecksumIEEE([]byte(serverID+"#"+strconv.Itoa(i))) % c.sectors
+		for _, ok := taken[position]; ok || c.servers[position] != nil; _, ok = taken[position] {
+			position = (position + 1) % c.sectors
+		}
+		taken[position] = struct{}{}
+		positions = append(positions, position)
+	}
+	return c.evaluatePlacement(serverID, positions, capacity, loads), nil
+}
+
+// ApplyPlacement puts new server on positions of the plan, server is not ready until rebalance is finished.
+// Plan made before other topology change may be outdated, taken position is an error.
+func (c *Circle) ApplyPlacement(plan *PlacementPlan, srv storager.DataKeeper) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.serversByID[plan.ServerID]; ok {
+		return fmt.Errorf("server %s is already on circle", plan.ServerID)
+	}
+	for _, position := range plan.Positions {
+		if position >= c.sectors {
+			return fmt.Errorf("position %d is out of circle", position)
+		}
+		if c.servers[position] != nil {
+			return fmt.Errorf("position %d is already taken by %s", position, c.servers[position].serverID)
+		}
+	}
+	server := &ringServer{
+		state:    entities.NodeStateNotReady,
+		serverID: plan.ServerID,
+		storage:  srv,
+	}
+	for _, position := range plan.Positions {
+		c.insertSorted(&dataKeeperContainer{position: position, ringServer: server})
+	}
+	return nil
+}
+
+// collectLoads requests capacity of new server and loads of servers on the circle in parallel
+func (c *Circle) collectLoads(ctx context.Context, srv storager.DataKeeper) (uint64, map[string]ServerLoad, error) {
+	var capacity uint64
+	err := c.calls.read(ctx, func(ctx context.Context) (errC error) {
+		capacity, errC = srv.GetCapacity(ctx)
+		return errC
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("error get capacity: %w", err)
+	}
+	if capacity == 0 {
+		return 0, nil, fmt.Errorf("server has no capacity")
+	}
+	c.mu.RLock()
+	storages := make(map[string]storager.DataKeeper, len(c.serversByID))
+	for serverID, server := range c.serversByID {
+		storages[serverID] = server.storage
+	}
+	c.mu.RUnlock()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errList = make([]error, 0, len(storages))
+		loads   = make(map[string]ServerLoad, len(storages))
+	)
+	wg.Add(len(storages))
+	for serverID, storage := range storages {
+		go func(serverID string, storage storager.DataKeeper) {
+			defer wg.Done()
+			load, errL := waitLoad(ctx, c.calls, storage)
+			mu.Lock()
+			defer mu.Unlock()
+			if errL != nil {
+				errList = append(errList, fmt.Errorf("error get load of %s: %w", serverID, errL))
+				return
+			}
+			loads[serverID] = load
+		}(serverID, storage)
+	}
+	wg.Wait()
+	if len(errList) > 0 {
+		return 0, nil, errors.Join(errList...)
+	}
+	return capacity, loads, nil
+}
+
+// waitLoad waits until storage finishes usage calculation of already stored data,
+// so new node placement is based on accurate usage
+func waitLoad(ctx context.Context, calls CallPolicy, storage storager.DataKeeper) (ServerLoad, error) {
+	var load ServerLoad
+	err := calls.read(ctx, func(ctx context.Context) (errC error) {
+		load.Capacity, errC = storage.GetCapacity(ctx)
+		return errC
+	})
+	if err != nil {
+		return ServerLoad{}, err
+	}
+	deadline := time.Now().Add(usageWaitTimeout)
+	for {
+		err = calls.read(ctx, func(ctx context.Context) (errS error) {
+			load.Sectors, errS = storage.GetSectorsUsage(ctx)
+			return errS
+		})
+		if !errors.Is(err, storager.ErrUsageNotReady) || time.Now().After(deadline) {
+			return load, err
+		}
+		select {
+		case <-time.After(usagePollInterval):
+		case <-ctx.Done():
+			return load, ctx.Err()
+		}
+	}
+}
+
+// bestPosition finds split point for new server with given capacity, see PlanPlacement. Caller must hold the lock.
+func (c *Circle) bestPosition(capacity uint64, loads map[string]ServerLoad) (uint32, error) {
+	serving := make([]*dataKeeperContainer, 0, c.serversList.Len())
+	totalCapacity := capacity
+	for el := c.serversList.Front(); el != nil; el = el.Next() {
+		server := el.Value.(*dataKeeperContainer)
+		if !isServing(server) {
+			continue
+		}
+		if !containsServer(serving, server.serverID) {
+			totalCapacity += loads[server.serverID].Capacity
+		}
+		serving = append(serving, server)
+	}
+	if len(serving) == 0 {
+		if c.serversList.Len() > 0 {
+			return 0, fmt.Errorf("no serving servers to split")
+		}
+		return c.sectors - 1, nil
+	}
+
+	// two highest utilizations, so maximum of the cluster without split server is known
+	var top, second float64
+	var topID string
+	for serverID, load := range loads {
+		if load.Capacity == 0 {
+			return 0, fmt.Errorf("server %s has no capacity", serverID)
+		}
+		switch utilization := usagePercentage(load.used(), load.Capacity); {
+		case utilization >= top:
+			second, top, topID = top, utilization, serverID
+		case utilization > second:
+			second = utilization
+		}
+	}
+
+	fairShare := float64(c.sectors) * float64(capacity) / float64(totalCapacity)
+	var (
+		found                          bool
+		bestCluster, bestPair, bestFit float64
+		best                           uint32
+	)
+	for i, owner := range serving {
+		load := loads[owner.serverID]
+		used := load.used()
+		others := top
+		if owner.serverID == topID {
+			others = second
+		}
+		prev := serving[(i+len(serving)-1)%len(serving)].position
+		rangeLen := (owner.position + c.sectors - prev) % c.sectors
+		if rangeLen == 0 {
+			rangeLen = c.sectors // the only serving position owns the whole circle
+		}
+		var moved uint64
+		for taken := uint32(1); taken < rangeLen; taken++ {
+			sector := (prev + taken) % c.sectors
+			moved += load.Sectors[sector]
+			if c.servers[sector] != nil {
+				continue // position of server which is not serving yet
+			}
+			pair := max(usagePercentage(moved, capacity), usagePercentage(used-min(used, moved), load.Capacity))
+			cluster := max(pair, others)
+			fit := math.Abs(float64(taken) - fairShare)
+			if !found || cluster < bestCluster ||
+				(cluster == bestCluster && (pair < bestPair || (pair == bestPair && fit < bestFit))) {
+				found, bestCluster, bestPair, bestFit, best = true, cluster, pair, fit, sector
+			}
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("no free position on circle")
+	}
+	return best, nil
+}
+
+// evaluatePlacement calculates sectors which new server takes over from current primary owners
+// and expected utilization. Caller must hold the lock.
+func (c *Circle) evaluatePlacement(serverID string, positions []uint32, capacity uint64, loads map[string]ServerLoad) *PlacementPlan {
+	positions = slices.Clone(positions)
+	slices.Sort(positions)
+	plan := &PlacementPlan{
+		ServerID:    serverID,
+		Positions:   positions,
+		Utilization: map[string]float64{serverID: 0},
+	}
+	if c.serversList.Len() == 0 {
+		return plan
+	}
+	moves := make(map[string]int)
+	movedFrom := make(map[string]uint64)
+	distance := func(from, to uint32) uint32 {
+		return (to + c.sectors - from) % c.sectors
+	}
+	cursor, wrapped, next := c.serversList.Front(), false, 0
+	for sector := uint32(0); sector < c.sectors; sector++ {
+		for !wrapped && cursor.Value.(*dataKeeperContainer).position < sector {
+			if cursor = cursor.Next(); cursor == nil {
+				cursor, wrapped = c.serversList.Front(), true
+			}
+		}
+		for next < len(positions) && positions[next] < sector {
+			next++
+		}
+		owners := c.ownersFrom(cursor, 1, isServing)
+		if len(owners) == 0 {
+			continue
+		}
+		owner := owners[0]
+		if distance(sector, positions[next%len(positions)]) > distance(sector, owner.position) {
+			continue
+		}
+		size := loads[owner.serverID].Sectors[sector]
+		movedFrom[owner.serverID] += size
+		if i, ok := moves[owner.serverID]; ok && plan.Moves[i].To+1 == sector {
+			plan.Moves[i].To = sector
+			plan.Moves[i].Bytes += size
+			continue
+		}
+		moves[owner.serverID] = len(plan.Moves)
+		plan.Moves = append(plan.Moves, PlacementMove{
+			From:     sector,
+			To:       sector,
+			SourceID: owner.serverID,
+			Bytes:    size,
+		})
+	}
+	plan.Utilization[serverID] = usagePercentage(plan.MovedBytes(), capacity)
+	for sourceID, moved := range movedFrom {
+		load := loads[sourceID]
+		used := load.used()
+		plan.Utilization[sourceID] = usagePercentage(used-min(used, moved), load.Capacity)
+	}
+	return plan
+}
+
+func usagePercentage(used, capacity uint64) float64 {
+	if capacity == 0 {
+		return 0
+	}
+	return float64(used) / float64(capacity) * 100
+}
@@ -0,0 +1,118 @@
+package orchestrator
+
+import (
+	"extendable_storage/internal/service/storager"
+)
+
+// SectorTransfer is a range of sectors [From, To] which Target loads from Source
+type SectorTransfer struct {
+	From     uint32
+	To       uint32
+	SourceID string
+	TargetID string
+	Source   storager.DataKeeper
+	Target   storager.DataKeeper
+}
+
+// SectorDrop is a range of sectors [From, To] which server doesn't own anymore and can drop
+type SectorDrop struct {
+	From     uint32
+	To       uint32
+	ServerID string
+	Storage  storager.DataKeeper
+}
+
+// RebalancePlan is a list of copies required to keep replicationFactor copies of every sector
+// and list of copies which become redundant after that
+type RebalancePlan struct {
+	Transfers []SectorTransfer
+	Drops     []SectorDrop
+}
+
+// PlanRebalance calculates which sectors server should load before it becomes ready
+// and which sectors other servers can drop after that.
+// Owners of every sector are compared with and without the server, so every new owner gets data from the current primary.
+func (c *Circle) PlanRebalance(serverID string, replicationFactor int) *RebalancePlan {
+	before := func(server *dataKeeperContainer) bool {
+		return server.serverID != serverID && isServing(server)
+	}
+	after := func(server *dataKeeperContainer) bool {
+		return server.serverID == serverID || isServing(server)
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.planRebalance(replicationFactor, before, after)
+}
+
+// PlanRemoval calculates which sectors of draining server should be copied to servers which own them without it.
+// Draining server is the source of its sectors when it is primary owner.
+func (c *Circle) PlanRemoval(serverID string, replicationFactor int) *RebalancePlan {
+	before := isServing
+	after := func(server *dataKeeperContainer) bool {
+		return server.serverID != serverID && isServing(server)
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.planRebalance(replicationFactor, before, after)
+}
+
+// planRebalance compares owners of every sector before and after topology change. Caller must hold the lock.
+func (c *Circle) planRebalance(replicationFactor int, before, after serverFilter) *RebalancePlan {
+	plan := &RebalancePlan{}
+	transfers := make(map[[2]string]int)
+	drops := make(map[string]int)
+	if c.serversList.Len() == 0 {
+		return plan
+	}
+	// first position clockwise from sector moves forward with sector, so circle is walked once
+	cursor, wrapped := c.serversList.Front(), false
+	for sector := uint32(0); sector < c.sectors; sector++ {
+		for !wrapped && cursor.Value.(*dataKeeperContainer).position < sector {
+			if cursor = cursor.Next(); cursor == nil {
+				cursor, wrapped = c.serversList.Front(), true
+			}
+		}
+		ownersBefore := c.ownersFrom(cursor, replicationFactor, before)
+		ownersAfter := c.ownersFrom(cursor, replicationFactor, after)
+		if len(ownersBefore) == 0 {
+			continue // no data stored yet
+		}
+		source := ownersBefore[0]
+		for _, target := range ownersAfter {
+			if containsServer(ownersBefore, target.serverID) {
+				continue
+			}
+			key := [2]string{source.serverID, target.serverID}
+			if i, ok := transfers[key]; ok && plan.Transfers[i].To+1 == sector {
+				plan.Transfers[i].To = sector
+				continue
+			}
+			transfers[key] = len(plan.Transfers)
+			plan.Transfers = append(plan.Transfers, SectorTransfer{
+				From:     sector,
+				To:       sector,
+				SourceID: source.serverID,
+				TargetID: target.serverID,
+				Source:   source.storage,
+				Target:   target.storage,
+			})
+		}
+		for _, owner := range ownersBefore {
+			if containsServer(ownersAfter, owner.serverID) {
+				continue
+			}
+			if i, ok := drops[owner.serverID]; ok && plan.Drops[i].To+1 == sector {
+				plan.Drops[i].To = sector
+				continue
+			}
+			drops[owner.serverID] = len(plan.Drops)
+			plan.Drops = append(plan.Drops, SectorDrop{
+				From:     sector,
+				To:       sector,
+				ServerID: owner.serverID,
+				Storage:  owner.storage,
+			})
+		}
+	}
+	return plan
+}
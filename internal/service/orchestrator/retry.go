@@ -0,0 +1,90 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/service/storager"
+	"os"
+	"time"
+)
+
+const (
+	defaultReadTimeout     = 10 * time.Second
+	defaultWriteTimeout    = 30 * time.Second
+	defaultTransferTimeout = 30 * time.Minute
+	defaultMaxAttempts     = 3
+	defaultInitialBackoff  = 100 * time.Millisecond
+	defaultMaxBackoff      = 5 * time.Second
+)
+
+func (p CallPolicy) read(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p.retry(ctx, func() error {
+		return callWithTimeout(ctx, p.ReadTimeout, fn)
+	})
+}
+
+func (p CallPolicy) write(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p.retry(ctx, func() error {
+		return callWithTimeout(ctx, p.WriteTimeout, fn)
+	})
+}
+
+func (p CallPolicy) transfer(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p.retry(ctx, func() error {
+		return callWithTimeout(ctx, p.TransferTimeout, fn)
+	})
+}
+
+// retry calls fn until it succeeds, attempts are over or ctx is done, see isRetryable
+func (p CallPolicy) retry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !isRetryable(err) {
+			return err
+		}
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// backoff returns pause after given failed attempt: InitialBackoff doubled for every previous attempt, up to MaxBackoff
+func (p CallPolicy) backoff(attempt int) time.Duration {
+	pause := p.InitialBackoff
+	for i := 1; i < attempt && pause < p.MaxBackoff; i++ {
+		pause *= 2
+	}
+	return min(pause, p.MaxBackoff)
+}
+
+func callWithTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return fn(callCtx)
+}
+
+// isRetryable reports whether failed call may succeed when repeated. Missing data, corrupted chunk and not ready usage
+// are answers of data keeper, caller handles them. Joined error is retryable if any of its errors is.
+func isRetryable(err error) bool {
+	switch wrapped := err.(type) {
+	case interface{ Unwrap() []error }:
+		for _, inner := range wrapped.Unwrap() {
+			if isRetryable(inner) {
+				return true
+			}
+		}
+		return false
+	case interface{ Unwrap() error }:
+		if inner := wrapped.Unwrap(); inner != nil {
+			return isRetryable(inner)
+		}
+	}
+	return !errors.Is(err, os.ErrNotExist) &&
+		!errors.Is(err, storager.ErrUsageNotReady) &&
+		!errors.Is(err, storager.ErrChunkCorrupted) &&
+		!errors.Is(err, context.Canceled)
+}
@@ -2,101 +2,478 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
 	"extendable_storage/internal/entities"
 	"extendable_storage/internal/logger"
+	"extendable_storage/internal/repository/topology"
 	"extendable_storage/internal/service/storager"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
+	"time"
 )
 
 const (
-	nodeStateNotReady = iota
-	nodeStateReady
+	topologyDumpTimeout = 10 * time.Second
+	bytesInMB           = 1024 * 1024
 )
 
 type Service struct {
-	circle *Circle
-	ctx    context.Context
-	wg     sync.WaitGroup
-	mu     sync.RWMutex
-	logger logger.AppLogger
-	Nodes  map[uint32]dataKeeperContainer
+	circle       *Circle
+	legacy       *Circle // circle with previous number of sectors, set until layout migration is finished
+	conf         Config
+	ctx          context.Context
+	wg           sync.WaitGroup
+	mu           sync.RWMutex
+	logger       logger.AppLogger
+	repoTopology *topology.Repo
+	connector    KeeperConnector
+	topologyLock chan struct{}     // held while topology changes and its rebalance job runs
+	jobErrors    map[string]string // reasons rebalance jobs stopped, by server id
+	drops        *dropQueue
+	dropsMu      sync.Mutex
+	dropJobs     map[string]*entities.RebalanceJob // jobs which drop progress is stored, by server id
+	Nodes        map[uint32]dataKeeperContainer
 }
 
 var _ DataRouter = (*Service)(nil)
 
-func NewService(ctx context.Context, log logger.AppLogger) *Service {
-	return &Service{
-		circle: NewCircle(),
-		ctx:    ctx,
-		logger: log.With(slog.String("service", "orchestrator")),
+// NewService creates orchestrator and rebuilds circle from stored topology.
+// Unfinished rebalance jobs and drops of stale copies are resumed in background.
+// Nil conf means single copy of data.
+func NewService(ctx context.Context, log logger.AppLogger, conf *Config, repoTopology *topology.Repo, connector KeeperConnector) (*Service, error) {
+	srv := &Service{
+		conf:         normalizeConfig(conf),
+		ctx:          ctx,
+		logger:       log.With(slog.String("service", "orchestrator")),
+		repoTopology: repoTopology,
+		connector:    connector,
+		topologyLock: make(chan struct{}, 1),
+		jobErrors:    make(map[string]string),
+		dropJobs:     make(map[string]*entities.RebalanceJob),
 	}
+	srv.circle = NewCircle(srv.conf.Sectors)
+	srv.circle.calls = srv.conf.Calls
+	srv.drops = newDropQueue(srv.logger, srv.conf.Calls, func(serverID string, sector uint32) bool {
+		return srv.circle.ownsSector(serverID, sector, srv.conf.ReplicationFactor)
+	})
+	srv.drops.run(ctx, &srv.wg)
+	if err := srv.restoreTopology(); err != nil {
+		return nil, fmt.Errorf("error restore topology: %w", err)
+	}
+	return srv, nil
 }
 
 func (s *Service) Stop() {
-	// todo add dump and restore server topology for ring
 	s.wg.Wait()
+	if s.legacyCircle() != nil {
+		// stored topology is replaced only when layout migration is finished
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), topologyDumpTimeout)
+	defer cancel()
+	for _, node := range s.circle.Nodes() {
+		if err := s.repoTopology.SaveNode(ctx, node); err != nil {
+			s.logger.Error("error dump topology node", err, slog.String("service_id", node.ServerID))
+		}
+	}
+}
+
+// AddDataKeeper places server on the circle and starts background job which loads sectors it owns now.
+// Server becomes ready when job switches it, see GetRebalanceProgress. Waits until previous job is finished.
+func (s *Service) AddDataKeeper(ctx context.Context, serviceID string, storage storager.DataKeeper) error {
+	if s.circle.HasServer(serviceID) {
+		return ErrDataKeeperExists
+	}
+	if err := s.lockTopology(ctx); err != nil {
+		return err
+	}
+	job, err := s.addDataKeeper(ctx, serviceID, storage)
+	if err != nil {
+		s.unlockTopology()
+		return err
+	}
+	s.startJobs(job)
+	return nil
+}
+
+func (s *Service) addDataKeeper(ctx context.Context, serviceID string, storage storager.DataKeeper) (*entities.RebalanceJob, error) {
+	plan, err := s.PlanDataKeeper(ctx, serviceID, storage)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.circle.ApplyPlacement(plan, storage); err != nil {
+		return nil, fmt.Errorf("error add server in circle map: %w", err)
+	}
+	s.logger.Info("server placed",
+		slog.String("service_id", serviceID),
+		slog.Int("positions", len(plan.Positions)),
+		slog.Uint64("moved_bytes", plan.MovedBytes()),
+	)
+	if err = s.saveTopology(ctx, serviceID); err != nil {
+		return nil, fmt.Errorf("error save topology: %w", err)
+	}
+	return s.createJob(ctx, serviceID, entities.JobKindJoin)
+}
+
+// PlanDataKeeper returns placement of a new server without adding it: its positions, sectors it takes over
+// and expected utilization. With virtual nodes number of positions depends on server capacity.
+func (s *Service) PlanDataKeeper(ctx context.Context, serviceID string, storage storager.DataKeeper) (*PlacementPlan, error) {
+	if s.circle.HasServer(serviceID) {
+		return nil, ErrDataKeeperExists
+	}
+	if s.legacyCircle() != nil {
+		return nil, ErrLayoutMigration
+	}
+	if s.conf.VNodeCapacityMB == 0 {
+		plan, err := s.circle.PlanPlacement(ctx, serviceID, storage)
+		if err != nil {
+			return nil, fmt.Errorf("error plan placement: %w", err)
+		}
+		return plan, nil
+	}
+	var capacity uint64
+	err := s.conf.Calls.read(ctx, func(ctx context.Context) (errC error) {
+		capacity, errC = storage.GetCapacity(ctx)
+		return errC
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error get capacity: %w", err)
+	}
+	vnodes := max(1, int(capacity/bytesInMB)/s.conf.VNodeCapacityMB)
+	plan, err := s.circle.PlanVirtualPlacement(ctx, serviceID, storage, vnodes)
+	if err != nil {
+		return nil, fmt.Errorf("error plan placement: %w", err)
+	}
+	return plan, nil
+}
+
+// RemoveDataKeeper marks server draining and starts background job which copies every sector it owns
+// to servers which own the sector without it, then removes server from circle and topology.
+// Server serves data until it is removed. Waits until previous job is finished.
+func (s *Service) RemoveDataKeeper(ctx context.Context, serviceID string) error {
+	if !s.circle.HasServer(serviceID) {
+		return ErrDataKeeperAbsent
+	}
+	if err := s.lockTopology(ctx); err != nil {
+		return err
+	}
+	job, err := s.removeDataKeeper(ctx, serviceID)
+	if err != nil {
+		s.unlockTopology()
+		return err
+	}
+	s.startJobs(job)
+	return nil
+}
+
+func (s *Service) removeDataKeeper(ctx context.Context, serviceID string) (*entities.RebalanceJob, error) {
+	if !s.circle.HasServer(serviceID) {
+		return nil, ErrDataKeeperAbsent
+	}
+	if s.legacyCircle() != nil {
+		return nil, ErrLayoutMigration
+	}
+	if err := s.circle.MarkServerDraining(serviceID); err != nil {
+		return nil, fmt.Errorf("error mark server draining: %w", err)
+	}
+	if err := s.saveTopology(ctx, serviceID); err != nil {
+		return nil, fmt.Errorf("error save topology: %w", err)
+	}
+	return s.createJob(ctx, serviceID, entities.JobKindDrain)
 }
 
-func (s *Service) AddDataKeeper(serviceID string, storage storager.DataKeeper) error {
-	rangeFrom, rangeTo, oldRangeTo, err := s.circle.AddServer(serviceID, storage)
+// saveTopology stores current positions and state of the server
+func (s *Service) saveTopology(ctx context.Context, serviceID string) error {
+	saved := false
+	for _, node := range s.circle.Nodes() {
+		if node.ServerID != serviceID {
+			continue
+		}
+		if err := s.repoTopology.SaveNode(ctx, node); err != nil {
+			return err
+		}
+		saved = true
+	}
+	if !saved {
+		return fmt.Errorf("server %s not found in circle", serviceID)
+	}
+	return nil
+}
+
+func (s *Service) restoreTopology() error {
+	nodes, err := s.repoTopology.GetNodes(s.ctx)
 	if err != nil {
-		return fmt.Errorf("error add server in circle map: %w", err)
+		return fmt.Errorf("error load topology: %w", err)
+	}
+	storages := make(map[string]storager.DataKeeper)
+	for _, node := range nodes {
+		if _, ok := storages[node.ServerID]; ok {
+			continue
+		}
+		storage, errC := s.connector(node.ServerID, node.Address)
+		if errC != nil {
+			return fmt.Errorf("error connect to node %s: %w", node.ServerID, errC)
+		}
+		storages[node.ServerID] = storage
+	}
+	if len(nodes) > 0 && nodes[0].Sectors != s.conf.Sectors {
+		return s.restoreLegacyTopology(nodes, storages)
+	}
+	for _, node := range nodes {
+		if errR := s.circle.RestoreServer(node.ServerID, node.Position, node.State, storages[node.ServerID]); errR != nil {
+			return fmt.Errorf("error restore node %s: %w", node.ServerID, errR)
+		}
+	}
+	if err = s.resumeJobs(nodes); err != nil {
+		return fmt.Errorf("error resume rebalance jobs: %w", err)
 	}
-	// start rebalancing process
-	if oldRangeTo == rangeTo {
-		// no need rebalance
-		s.circle.MarkServerReady(serviceID)
+	s.logger.Info("topology restored", slog.Int("nodes", len(nodes)))
+	return nil
+}
+
+// resumeJobs continues unfinished rebalance jobs and drops of stale copies of finished ones.
+// Node which was not ready or draining without a job (job wasn't stored before restart) gets a new one.
+func (s *Service) resumeJobs(nodes []*entities.RingNode) error {
+	stored, err := s.repoTopology.GetJobs(s.ctx, false)
+	if err != nil {
+		return err
+	}
+	jobs := make([]*entities.RebalanceJob, 0, len(stored))
+	active := make(map[string]struct{}, len(stored))
+	for _, job := range stored {
+		s.dropsMu.Lock()
+		s.dropJobs[job.ServerID] = job
+		s.dropsMu.Unlock()
+		if !job.Done {
+			active[job.ServerID] = struct{}{}
+			jobs = append(jobs, job)
+			continue
+		}
+		if err = s.resumeDrops(job); err != nil {
+			return err
+		}
+	}
+	for _, node := range nodes {
+		if _, ok := active[node.ServerID]; ok {
+			continue
+		}
+		kind := entities.JobKindJoin
+		switch node.State {
+		case entities.NodeStateNotReady:
+		case entities.NodeStateDraining:
+			kind = entities.JobKindDrain
+		default:
+			continue
+		}
+		job, errC := s.createJob(s.ctx, node.ServerID, kind)
+		if errC != nil {
+			return errC
+		}
+		active[node.ServerID] = struct{}{}
+		jobs = append(jobs, job)
+	}
+	if len(jobs) == 0 {
 		return nil
 	}
-	// new server need get all files in range [rangeFrom, rangeTo]
-	// request them from server with id = [rangeFrom, oldRangeTo]
-	sourceSrv, _, err := s.circle.GetServerForPosition(rangeTo)
+	for _, job := range jobs {
+		s.logger.Info("resume rebalance job", slog.String("service_id", job.ServerID), slog.String("kind", string(job.Kind)))
+	}
+	if err = s.lockTopology(s.ctx); err != nil {
+		return err
+	}
+	s.startJobs(jobs...)
+	return nil
+}
+
+// resumeDrops enqueues stale copies of finished job which were not dropped before restart
+func (s *Service) resumeDrops(job *entities.RebalanceJob) error {
+	moves, err := s.repoTopology.GetSectorMoves(s.ctx, job.ServerID)
 	if err != nil {
-		return fmt.Errorf("error get server for position: %w", err)
+		return fmt.Errorf("error load sector moves: %w", err)
 	}
-	if err = storage.SaveFromSource(rangeFrom, rangeTo, sourceSrv); err != nil {
-		return fmt.Errorf("error save from source: %w", err)
+	runs := splitRuns(moves, entities.SectorStateSwitched, sameDrop)
+	if len(runs) == 0 {
+		return nil
 	}
-	s.logger.Info("rebalance finished", slog.String("service_id", serviceID))
-	s.circle.MarkServerReady(serviceID)
-	if err = sourceSrv.DropChunksInRange(rangeFrom, rangeTo); err != nil {
-		// todo move this to background process with retry logic
-		return fmt.Errorf("error drop chunks in range: %w", err)
+	storageOf := s.jobStorages(job)
+	for _, run := range runs {
+		s.enqueueDrops(job, run, storageOf)
 	}
+	s.logger.Info("resume drops of stale copies", slog.String("service_id", job.ServerID), slog.Int("runs", len(runs)))
 	return nil
 }
 
-func (s *Service) GetFileChunk(chunk *entities.FileChunk) ([]byte, error) {
-	srv, _, err := s.circle.GetServerForChunk(chunk)
+// GetFileChunk reads chunk from its owners one by one, replicas are used when primary fails.
+// When all owners fail, they are tried again after backoff, see CallPolicy.
+// Owners which keep corrupted copy of the chunk are repaired in background with data of the owner which served it.
+// While layout migration is in progress chunk which is not moved yet is read from owners on legacy circle.
+func (s *Service) GetFileChunk(ctx context.Context, chunk *entities.FileChunk) ([]byte, error) {
+	replicas := s.circle.GetServersForChunk(chunk, s.conf.ReplicationFactor)
+	if len(replicas) == 0 {
+		return nil, fmt.Errorf("error get server for chunk: %w", ErrNoDataKeepers)
+	}
+	data, corrupted, err := s.getFromReplicas(ctx, chunk, replicas)
+	if legacy := s.legacyCircle(); err != nil && legacy != nil {
+		// legacy copies are moved by layout migration, they are not repaired
+		data, _, err = s.getFromReplicas(ctx, chunk, legacy.GetServersForChunk(chunk, s.conf.ReplicationFactor))
+		return data, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error get server for chunk: %w", err)
+		return nil, err
+	}
+	if len(corrupted) > 0 {
+		s.repairChunk(chunk, data, corrupted)
 	}
-	return srv.GetFile(chunk)
+	return data, nil
 }
 
-func (s *Service) SaveFileChunk(chunk *entities.FileChunk, data []byte) error {
-	srv, _, err := s.circle.GetServerForChunk(chunk)
+// getFromReplicas reads chunk from replicas one by one, replicas which keep corrupted copy of the chunk are skipped and returned
+func (s *Service) getFromReplicas(ctx context.Context, chunk *entities.FileChunk, replicas []Replica) ([]byte, []Replica, error) {
+	var (
+		data      []byte
+		corrupted []Replica
+	)
+	err := s.conf.Calls.retry(ctx, func() error {
+		errList := make([]error, 0, len(replicas))
+		for _, replica := range replicas {
+			err := callWithTimeout(ctx, s.conf.Calls.ReadTimeout, func(ctx context.Context) (errG error) {
+				data, errG = replica.Storage.GetFile(ctx, chunk)
+				return errG
+			})
+			if err == nil {
+				return nil
+			}
+			if errors.Is(err, storager.ErrChunkCorrupted) && !containsReplica(corrupted, replica.ServerID) {
+				corrupted = append(corrupted, replica)
+			}
+			s.logger.Error("error get chunk from replica", err, slog.String("service_id", replica.ServerID), slog.String("chunk", chunk.String()))
+			errList = append(errList, fmt.Errorf("error get chunk from %s: %w", replica.ServerID, err))
+		}
+		return errors.Join(errList...)
+	})
 	if err != nil {
-		return fmt.Errorf("error get server for chunk: %w", err)
+		return nil, corrupted, err
 	}
-	return srv.SaveFile(chunk, data)
+	return data, corrupted, nil
 }
 
-func (s *Service) PurgeFileChunks(chunks []*entities.FileChunk) error {
+// repairChunk overwrites corrupted copies of the chunk with served data in background. Data is checked before,
+// so copy of the node which doesn't verify chunks can't spread damage.
+func (s *Service) repairChunk(chunk *entities.FileChunk, data []byte, corrupted []Replica) {
+	if !chunk.Verify(data) {
+		s.logger.Error("chunk can't be repaired, served copy is corrupted", storager.ErrChunkCorrupted, slog.String("chunk", chunk.String()))
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		sector := chunk.Sector(s.circle.Sectors())
+		for _, replica := range corrupted {
+			// sector can be moved away while chunk is read, stale copy is dropped by rebalance
+			if !s.circle.ownsSector(replica.ServerID, sector, s.conf.ReplicationFactor) {
+				continue
+			}
+			err := s.conf.Calls.write(s.ctx, func(ctx context.Context) error {
+				return replica.Storage.SaveFile(ctx, chunk, data)
+			})
+			if err != nil {
+				s.logger.Error("error repair corrupted chunk", err, slog.String("service_id", replica.ServerID), slog.String("chunk", chunk.String()))
+				continue
+			}
+			s.logger.Info("corrupted chunk is repaired", slog.String("service_id", replica.ServerID), slog.String("chunk", chunk.String()))
+		}
+	}()
+}
+
+func (s *Service) ChunkOwner(chunk *entities.FileChunk) (string, error) {
+	_, serverID, err := s.circle.GetServerForChunk(chunk)
+	return serverID, err
+}
+
+func (s *Service) ServingDataKeepers() int {
+	return s.circle.ServingServers()
+}
+
+// SaveFileChunk saves chunk to all its owners in parallel, save succeeds when WriteQuorum owners confirm it.
+// When cluster has less ready servers than ReplicationFactor, all of them must confirm.
+// Servers which take over sector of the chunk in rebalance in progress must confirm it as well,
+// otherwise chunk is lost when they become owners. Failed save to owner is retried, see CallPolicy.
+func (s *Service) SaveFileChunk(ctx context.Context, chunk *entities.FileChunk, data []byte) error {
+	replicas, incoming := s.circle.GetWriteServersForChunk(chunk, s.conf.ReplicationFactor)
+	if len(replicas) == 0 {
+		return fmt.Errorf("error get server for chunk: %w", ErrNoDataKeepers)
+	}
+	return s.saveToReplicas(ctx, chunk, data, replicas, incoming)
+}
+
+// saveToReplicas saves chunk to replicas and incoming owners in parallel,
+// WriteQuorum of replicas and all incoming owners must confirm it
+func (s *Service) saveToReplicas(ctx context.Context, chunk *entities.FileChunk, data []byte, replicas, incoming []Replica) error {
+	targets := append(slices.Clone(replicas), incoming...)
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	wg.Add(len(targets))
+	for i, target := range targets {
+		go func(i int, replica Replica) {
+			defer wg.Done()
+			err := s.conf.Calls.write(ctx, func(ctx context.Context) error {
+				return replica.Storage.SaveFile(ctx, chunk, data)
+			})
+			if err != nil {
+				s.logger.Error("error save chunk to replica", err, slog.String("service_id", replica.ServerID), slog.String("chunk", chunk.String()))
+				errs[i] = fmt.Errorf("error save chunk to %s: %w", replica.ServerID, err)
+			}
+		}(i, target)
+	}
+	wg.Wait()
+	var (
+		saved          int
+		incomingFailed bool
+		errList        []error
+	)
+	for i, err := range errs {
+		switch {
+		case err != nil:
+			errList = append(errList, err)
+			incomingFailed = incomingFailed || i >= len(replicas)
+		case i < len(replicas):
+			saved++
+		}
+	}
+	quorum := min(s.conf.WriteQuorum, len(replicas))
+	if saved < quorum {
+		return fmt.Errorf("error save chunk, saved %d of %d required copies: %w", saved, quorum, errors.Join(errList...))
+	}
+	if incomingFailed {
+		return fmt.Errorf("error save chunk to server in rebalance: %w", errors.Join(errList...))
+	}
+	return nil
+}
+
+// PurgeFileChunks purges chunks from all their owners, including servers which are still in rebalance
+// and owners on legacy circle while layout migration is in progress
+func (s *Service) PurgeFileChunks(ctx context.Context, chunks []*entities.FileChunk) error {
 	requests := make(map[string][]*entities.FileChunk, len(chunks))
-	srvs := make(map[string]storager.DataKeeper, entities.CircleSectors)
+	srvs := make(map[string]storager.DataKeeper)
+	legacy := s.legacyCircle()
 	for _, chunk := range chunks {
-		srv, srvID, err := s.circle.GetServerForChunk(chunk)
-		if err != nil {
-			return fmt.Errorf("error get server for chunk: %w", err)
+		replicas := s.circle.getAllServersForChunk(chunk, s.conf.ReplicationFactor)
+		if len(replicas) == 0 {
+			return fmt.Errorf("error get server for chunk: %w", ErrNoDataKeepers)
+		}
+		if legacy != nil {
+			for _, replica := range legacy.getAllServersForChunk(chunk, s.conf.ReplicationFactor) {
+				if !containsReplica(replicas, replica.ServerID) {
+					replicas = append(replicas, replica)
+				}
+			}
 		}
-		if _, ok := requests[srvID]; !ok {
-			requests[srvID] = make([]*entities.FileChunk, 0, len(chunks))
+		for _, replica := range replicas {
+			requests[replica.ServerID] = append(requests[replica.ServerID], chunk)
+			srvs[replica.ServerID] = replica.Storage
 		}
-		requests[srvID] = append(requests[srvID], chunk)
-		srvs[srvID] = srv
 	}
 	var (
 		wg      sync.WaitGroup
@@ -106,7 +483,10 @@ func (s *Service) PurgeFileChunks(chunks []*entities.FileChunk) error {
 	for srvID := range srvs {
 		go func(srvID string) {
 			defer wg.Done()
-			if err := srvs[srvID].PurgeFileChunks(requests[srvID]); err != nil {
+			err := s.conf.Calls.write(ctx, func(ctx context.Context) error {
+				return srvs[srvID].PurgeFileChunks(ctx, requests[srvID])
+			})
+			if err != nil {
 				s.mu.Lock()
 				errList = append(errList, err)
 				s.mu.Unlock()
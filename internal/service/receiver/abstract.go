@@ -1,8 +1,82 @@
 package receiver
 
-import "context"
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/entities"
+	"io"
+)
 
+var (
+	ErrFileAlreadyExists   = errors.New("file already exists")
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+	ErrRangeNotSupported   = errors.New("range requests not supported for file")
+	ErrInvalidSaveOptions  = errors.New("invalid save options")
+	ErrNotEnoughShards     = errors.New("not enough shards to reconstruct file")
+	ErrFileTooLarge        = errors.New("file is too large")
+	ErrNotEnoughKeepers    = errors.New("not enough data keepers to place shards apart")
+	ErrBucketNotFound      = errors.New("bucket not found")
+	ErrBucketAlreadyExists = errors.New("bucket already exists")
+	ErrBucketNotEmpty      = errors.New("bucket is not empty")
+	ErrUploadNotFound      = errors.New("upload not found")
+	ErrInvalidPart         = errors.New("invalid part")
+)
+
+// SaveOptions describes how file is stored. Zero value means plain chunks, which rely on orchestrator replication
+type SaveOptions struct {
+	// DataShards and ParityShards enable erasure coding, file can be reconstructed from any DataShards shards.
+	// Erasure coded file is buffered in memory on save, so its size is limited, and every shard is placed
+	// on its own data keeper.
+	DataShards   int
+	ParityShards int
+	// ContentType is stored with the file and served back, application/octet-stream if empty
+	ContentType string
+}
+
+// DataReceiver is an interface for gateway which receives files from clients and serves them back
+//
+//go:generate mockgen -source=abstract.go -destination=abstract_mock.go -package=receiver
 type DataReceiver interface {
 	GetFile(ctx context.Context, fileID string) ([]byte, error)
 	SaveFile(ctx context.Context, fileID string, data []byte) error
+
+	// GetFileStream returns reader which loads file chunks on demand and size of the file (-1 if unknown)
+	GetFileStream(ctx context.Context, fileID string) (io.ReadCloser, int64, error)
+	// SaveFileStream saves file of given size, reading and shipping it chunk by chunk
+	SaveFileStream(ctx context.Context, fileID string, data io.Reader, size int64, opts SaveOptions) error
+	// ReplaceFileStream saves file like SaveFileStream, complete file with the same id is replaced
+	// only after the new version is saved
+	ReplaceFileStream(ctx context.Context, fileID string, data io.Reader, size int64, opts SaveOptions) error
+	// GetFileRange returns reader of length bytes of the file starting from offset
+	GetFileRange(ctx context.Context, fileID string, offset, length int64) (io.ReadCloser, error)
+	// GetFileInfo returns file metadata. Read methods report file which is being saved or purged as missing
+	// (sql.ErrNoRows), its chunks are not all in place.
+	GetFileInfo(ctx context.Context, fileID string) (*entities.File, error)
+	// DeleteFile removes file and its chunks
+	DeleteFile(ctx context.Context, fileID string) error
+	// ListFiles returns up to limit completed files which id starts with prefix, ordered by id after startAfter
+	ListFiles(ctx context.Context, prefix, startAfter string, limit int) ([]*entities.File, error)
+
+	// InitiateUpload starts multipart upload of the file and returns its id. Parts are saved by SavePart
+	// and joined into the file by CompleteUpload, erasure coding isn't supported.
+	InitiateUpload(ctx context.Context, fileID string, opts SaveOptions) (string, error)
+	// GetUpload returns upload, ErrUploadNotFound if it doesn't exist
+	GetUpload(ctx context.Context, uploadID string) (*entities.Upload, error)
+	// SavePart saves part of given number (1-10000), previous copy of the part is replaced
+	SavePart(ctx context.Context, uploadID string, number int, data io.Reader, size int64) (*entities.UploadPart, error)
+	// ListParts returns uploaded parts ordered by number
+	ListParts(ctx context.Context, uploadID string) ([]*entities.UploadPart, error)
+	// CompleteUpload creates file from parts with given ascending numbers, nil means all uploaded parts.
+	// ErrFileAlreadyExists is returned if file exists at the moment.
+	CompleteUpload(ctx context.Context, uploadID string, numbers []int) error
+	// AbortUpload stops upload, its parts are purged in background
+	AbortUpload(ctx context.Context, uploadID string) error
+
+	// CreateBucket creates bucket of S3 API objects, ErrBucketAlreadyExists if it exists
+	CreateBucket(ctx context.Context, name string) error
+	// GetBucket returns bucket, ErrBucketNotFound if it doesn't exist
+	GetBucket(ctx context.Context, name string) (*entities.Bucket, error)
+	ListBuckets(ctx context.Context) ([]*entities.Bucket, error)
+	// DeleteBucket deletes empty bucket, ErrBucketNotEmpty if it has objects
+	DeleteBucket(ctx context.Context, name string) error
 }
@@ -0,0 +1,319 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: abstract.go
+//
+// Generated by this command:
+//
+//	mockgen -source=abstract.go -destination=abstract_mock.go -package=receiver
+//
+// Package receiver is a generated GoMock package.
+package receiver
+
+import (
+	context "context"
+	entities "extendable_storage/internal/entities"
+	io "io"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockDataReceiver is a mock of DataReceiver interface.
+type MockDataReceiver struct {
+	ctrl     *gomock.Controller
+	recorder *MockDataReceiverMockRecorder
+}
+
+// MockDataReceiverMockRecorder is the mock recorder for MockDataReceiver.
+type MockDataReceiverMockRecorder struct {
+	mock *MockDataReceiver
+}
+
+// NewMockDataReceiver creates a new mock instance.
+func NewMockDataReceiver(ctrl *gomock.Controller) *MockDataReceiver {
+	mock := &MockDataReceiver{ctrl: ctrl}
+	mock.recorder = &MockDataReceiverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDataReceiver) EXPECT() *MockDataReceiverMockRecorder {
+	return m.recorder
+}
+
+// AbortUpload mocks base method.
+func (m *MockDataReceiver) AbortUpload(ctx context.Context, uploadID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AbortUpload", ctx, uploadID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AbortUpload indicates an expected call of AbortUpload.
+func (mr *MockDataReceiverMockRecorder) AbortUpload(ctx, uploadID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AbortUpload", reflect.TypeOf((*MockDataReceiver)(nil).AbortUpload), ctx, uploadID)
+}
+
+// CompleteUpload mocks base method.
+func (m *MockDataReceiver) CompleteUpload(ctx context.Context, uploadID string, numbers []int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteUpload", ctx, uploadID, numbers)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteUpload indicates an expected call of CompleteUpload.
+func (mr *MockDataReceiverMockRecorder) CompleteUpload(ctx, uploadID, numbers any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteUpload", reflect.TypeOf((*MockDataReceiver)(nil).CompleteUpload), ctx, uploadID, numbers)
+}
+
+// CreateBucket mocks base method.
+func (m *MockDataReceiver) CreateBucket(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBucket", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateBucket indicates an expected call of CreateBucket.
+func (mr *MockDataReceiverMockRecorder) CreateBucket(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBucket", reflect.TypeOf((*MockDataReceiver)(nil).CreateBucket), ctx, name)
+}
+
+// DeleteBucket mocks base method.
+func (m *MockDataReceiver) DeleteBucket(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteBucket", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteBucket indicates an expected call of DeleteBucket.
+func (mr *MockDataReceiverMockRecorder) DeleteBucket(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteBucket", reflect.TypeOf((*MockDataReceiver)(nil).DeleteBucket), ctx, name)
+}
+
+// DeleteFile mocks base method.
+func (m *MockDataReceiver) DeleteFile(ctx context.Context, fileID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFile", ctx, fileID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteFile indicates an expected call of DeleteFile.
+func (mr *MockDataReceiverMockRecorder) DeleteFile(ctx, fileID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFile", reflect.TypeOf((*MockDataReceiver)(nil).DeleteFile), ctx, fileID)
+}
+
+// GetBucket mocks base method.
+func (m *MockDataReceiver) GetBucket(ctx context.Context, name string) (*entities.Bucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBucket", ctx, name)
+	ret0, _ := ret[0].(*entities.Bucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBucket indicates an expected call of GetBucket.
+func (mr *MockDataReceiverMockRecorder) GetBucket(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBucket", reflect.TypeOf((*MockDataReceiver)(nil).GetBucket), ctx, name)
+}
+
+// GetFile mocks base method.
+func (m *MockDataReceiver) GetFile(ctx context.Context, fileID string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFile", ctx, fileID)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFile indicates an expected call of GetFile.
+func (mr *MockDataReceiverMockRecorder) GetFile(ctx, fileID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFile", reflect.TypeOf((*MockDataReceiver)(nil).GetFile), ctx, fileID)
+}
+
+// GetFileInfo mocks base method.
+func (m *MockDataReceiver) GetFileInfo(ctx context.Context, fileID string) (*entities.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFileInfo", ctx, fileID)
+	ret0, _ := ret[0].(*entities.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFileInfo indicates an expected call of GetFileInfo.
+func (mr *MockDataReceiverMockRecorder) GetFileInfo(ctx, fileID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileInfo", reflect.TypeOf((*MockDataReceiver)(nil).GetFileInfo), ctx, fileID)
+}
+
+// GetFileRange mocks base method.
+func (m *MockDataReceiver) GetFileRange(ctx context.Context, fileID string, offset, length int64) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFileRange", ctx, fileID, offset, length)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFileRange indicates an expected call of GetFileRange.
+func (mr *MockDataReceiverMockRecorder) GetFileRange(ctx, fileID, offset, length any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileRange", reflect.TypeOf((*MockDataReceiver)(nil).GetFileRange), ctx, fileID, offset, length)
+}
+
+// GetFileStream mocks base method.
+func (m *MockDataReceiver) GetFileStream(ctx context.Context, fileID string) (io.ReadCloser, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFileStream", ctx, fileID)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetFileStream indicates an expected call of GetFileStream.
+func (mr *MockDataReceiverMockRecorder) GetFileStream(ctx, fileID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFileStream", reflect.TypeOf((*MockDataReceiver)(nil).GetFileStream), ctx, fileID)
+}
+
+// GetUpload mocks base method.
+func (m *MockDataReceiver) GetUpload(ctx context.Context, uploadID string) (*entities.Upload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpload", ctx, uploadID)
+	ret0, _ := ret[0].(*entities.Upload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUpload indicates an expected call of GetUpload.
+func (mr *MockDataReceiverMockRecorder) GetUpload(ctx, uploadID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpload", reflect.TypeOf((*MockDataReceiver)(nil).GetUpload), ctx, uploadID)
+}
+
+// InitiateUpload mocks base method.
+func (m *MockDataReceiver) InitiateUpload(ctx context.Context, fileID string, opts SaveOptions) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InitiateUpload", ctx, fileID, opts)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InitiateUpload indicates an expected call of InitiateUpload.
+func (mr *MockDataReceiverMockRecorder) InitiateUpload(ctx, fileID, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InitiateUpload", reflect.TypeOf((*MockDataReceiver)(nil).InitiateUpload), ctx, fileID, opts)
+}
+
+// ListBuckets mocks base method.
+func (m *MockDataReceiver) ListBuckets(ctx context.Context) ([]*entities.Bucket, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBuckets", ctx)
+	ret0, _ := ret[0].([]*entities.Bucket)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBuckets indicates an expected call of ListBuckets.
+func (mr *MockDataReceiverMockRecorder) ListBuckets(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBuckets", reflect.TypeOf((*MockDataReceiver)(nil).ListBuckets), ctx)
+}
+
+// ListFiles mocks base method.
+func (m *MockDataReceiver) ListFiles(ctx context.Context, prefix, startAfter string, limit int) ([]*entities.File, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFiles", ctx, prefix, startAfter, limit)
+	ret0, _ := ret[0].([]*entities.File)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFiles indicates an expected call of ListFiles.
+func (mr *MockDataReceiverMockRecorder) ListFiles(ctx, prefix, startAfter, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFiles", reflect.TypeOf((*MockDataReceiver)(nil).ListFiles), ctx, prefix, startAfter, limit)
+}
+
+// ListParts mocks base method.
+func (m *MockDataReceiver) ListParts(ctx context.Context, uploadID string) ([]*entities.UploadPart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListParts", ctx, uploadID)
+	ret0, _ := ret[0].([]*entities.UploadPart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListParts indicates an expected call of ListParts.
+func (mr *MockDataReceiverMockRecorder) ListParts(ctx, uploadID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListParts", reflect.TypeOf((*MockDataReceiver)(nil).ListParts), ctx, uploadID)
+}
+
+// ReplaceFileStream mocks base method.
+func (m *MockDataReceiver) ReplaceFileStream(ctx context.Context, fileID string, data io.Reader, size int64, opts SaveOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceFileStream", ctx, fileID, data, size, opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceFileStream indicates an expected call of ReplaceFileStream.
+func (mr *MockDataReceiverMockRecorder) ReplaceFileStream(ctx, fileID, data, size, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceFileStream", reflect.TypeOf((*MockDataReceiver)(nil).ReplaceFileStream), ctx, fileID, data, size, opts)
+}
+
+// SaveFile mocks base method.
+func (m *MockDataReceiver) SaveFile(ctx context.Context, fileID string, data []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveFile", ctx, fileID, data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveFile indicates an expected call of SaveFile.
+func (mr *MockDataReceiverMockRecorder) SaveFile(ctx, fileID, data any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFile", reflect.TypeOf((*MockDataReceiver)(nil).SaveFile), ctx, fileID, data)
+}
+
+// SaveFileStream mocks base method.
+func (m *MockDataReceiver) SaveFileStream(ctx context.Context, fileID string, data io.Reader, size int64, opts SaveOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveFileStream", ctx, fileID, data, size, opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveFileStream indicates an expected call of SaveFileStream.
+func (mr *MockDataReceiverMockRecorder) SaveFileStream(ctx, fileID, data, size, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFileStream", reflect.TypeOf((*MockDataReceiver)(nil).SaveFileStream), ctx, fileID, data, size, opts)
+}
+
+// SavePart mocks base method.
+func (m *MockDataReceiver) SavePart(ctx context.Context, uploadID string, number int, data io.Reader, size int64) (*entities.UploadPart, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SavePart", ctx, uploadID, number, data, size)
+	ret0, _ := ret[0].(*entities.UploadPart)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SavePart indicates an expected call of SavePart.
+func (mr *MockDataReceiverMockRecorder) SavePart(ctx, uploadID, number, data, size any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePart", reflect.TypeOf((*MockDataReceiver)(nil).SavePart), ctx, uploadID, number, data, size)
+}
@@ -30,17 +30,35 @@ func (s *Service) cleanData() {
 	s.cleanupFiles(purgeCandidate)
 
 	// 2. load files with new status and which not updated for 21 hour
-	purgeCandidate, err = s.repo.GetChunksUpdatedBeforeDataWithStatus(s.ctx, entities.FileStatusNew, time.Now().Add(-24*time.Hour))
+	staleBefore := time.Now().Add(-24 * time.Hour)
+	purgeCandidate, err = s.repo.GetChunksUpdatedBeforeDataWithStatus(s.ctx, entities.FileStatusNew, staleBefore)
 	if err != nil {
 		s.logger.Error("error get purge candidate", err)
 		return
 	}
 	s.cleanupFiles(purgeCandidate)
+
+	// 3. abort multipart uploads which got no parts for 24 hours
+	if _, err = s.repoUpload.AbortUploadsUpdatedBefore(s.ctx, staleBefore); err != nil {
+		s.logger.Error("error abort abandoned uploads", err)
+		return
+	}
+
+	// 4. purge replaced parts, parts of aborted or completed uploads which are not used by the file and stuck parts
+	parts, err := s.repoUpload.GetPartsToPurge(s.ctx, staleBefore)
+	if err != nil {
+		s.logger.Error("error get parts to purge", err)
+		return
+	}
+	s.cleanupParts(parts)
+	if err = s.repoUpload.DeleteFinishedUploads(s.ctx); err != nil {
+		s.logger.Error("error delete finished uploads", err)
+	}
 }
 
 func (s *Service) cleanupFiles(purgeCandidate []*entities.File) {
 	for _, file := range purgeCandidate {
-		if err := s.router.PurgeFileChunks(file.Chunks); err != nil {
+		if err := s.router.PurgeFileChunks(s.ctx, file.Chunks); err != nil {
 			s.logger.Error("error purge file chunks", err)
 			continue
 		}
@@ -49,3 +67,15 @@ func (s *Service) cleanupFiles(purgeCandidate []*entities.File) {
 		}
 	}
 }
+
+func (s *Service) cleanupParts(parts []*entities.UploadPart) {
+	for _, part := range parts {
+		if err := s.router.PurgeFileChunks(s.ctx, part.Chunks); err != nil {
+			s.logger.Error("error purge part chunks", err)
+			continue
+		}
+		if err := s.repoUpload.DeletePart(s.ctx, part.ID); err != nil {
+			s.logger.Error("error delete part", err)
+		}
+	}
+}
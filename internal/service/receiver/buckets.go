@@ -0,0 +1,57 @@
+package receiver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"extendable_storage/internal/entities"
+	"fmt"
+)
+
+func (s *Service) CreateBucket(ctx context.Context, name string) error {
+	created, err := s.repoBucket.CreateBucket(ctx, name)
+	if err != nil {
+		return fmt.Errorf("error create bucket: %w", err)
+	}
+	if !created {
+		return ErrBucketAlreadyExists
+	}
+	return nil
+}
+
+func (s *Service) GetBucket(ctx context.Context, name string) (*entities.Bucket, error) {
+	bucket, err := s.repoBucket.GetBucket(ctx, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBucketNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error get bucket: %w", err)
+	}
+	return bucket, nil
+}
+
+func (s *Service) ListBuckets(ctx context.Context) ([]*entities.Bucket, error) {
+	buckets, err := s.repoBucket.ListBuckets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error list buckets: %w", err)
+	}
+	return buckets, nil
+}
+
+// DeleteBucket deletes bucket without objects, ErrBucketNotEmpty is returned otherwise
+func (s *Service) DeleteBucket(ctx context.Context, name string) error {
+	if _, err := s.GetBucket(ctx, name); err != nil {
+		return err
+	}
+	objects, err := s.repo.ListFiles(ctx, entities.ObjectFileID(name, ""), "", 1)
+	if err != nil {
+		return fmt.Errorf("error list bucket objects: %w", err)
+	}
+	if len(objects) > 0 {
+		return ErrBucketNotEmpty
+	}
+	if err = s.repoBucket.DeleteBucket(ctx, name); err != nil {
+		return fmt.Errorf("error delete bucket: %w", err)
+	}
+	return nil
+}
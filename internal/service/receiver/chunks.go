@@ -1,24 +1,22 @@
 package receiver
 
-func chunkData(data []byte, numChunks int) [][]byte {
+// chunkSizes calculates sizes of chunks for data of given size split into numChunks parts.
+// Last chunk may be bigger if the data size is not divisible.
+// Data smaller than numChunks bytes is split into fewer chunks, so no chunk is empty.
+func chunkSizes(size int64, numChunks int) []int64 {
+	if size < int64(numChunks) {
+		numChunks = int(size)
+	}
+	if numChunks == 0 {
+		return nil
+	}
 	// Calculate the size of each chunk
-	chunkSize := len(data) / numChunks
+	chunkSize := size / int64(numChunks)
 
-	// Create a slice to hold the chunks
-	chunks := make([][]byte, numChunks)
-
-	// Split the data into equal-sized chunks
-	for i := 0; i < numChunks; i++ {
-		start := i * chunkSize
-		var end int
-		if i == numChunks-1 {
-			// Last chunk may be smaller if the data size is not divisible
-			end = len(data)
-		} else {
-			end = (i + 1) * chunkSize
-		}
-		chunks[i] = data[start:end]
+	sizes := make([]int64, numChunks)
+	for i := 0; i < numChunks-1; i++ {
+		sizes[i] = chunkSize
 	}
-
-	return chunks
+	sizes[numChunks-1] = size - chunkSize*int64(numChunks-1)
+	return sizes
 }
@@ -0,0 +1,42 @@
+package receiver
+
+import (
+	"crypto/md5" //nolint:gosec // md5 is not used for security, S3 clients expect it as ETag
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"strconv"
+)
+
+// fileDigest calculates SHA-256 and MD5 of the whole file while it is read
+type fileDigest struct {
+	sha256 hash.Hash
+	md5    hash.Hash
+}
+
+func newFileDigest() *fileDigest {
+	return &fileDigest{
+		sha256: sha256.New(),
+		md5:    md5.New(), //nolint:gosec // see import
+	}
+}
+
+func (d *fileDigest) Write(p []byte) (int, error) {
+	d.sha256.Write(p)
+	return d.md5.Write(p)
+}
+
+// sums returns hex digests of data written so far
+func (d *fileDigest) sums() (sha256Sum, md5Sum string) {
+	return hex.EncodeToString(d.sha256.Sum(nil)), hex.EncodeToString(d.md5.Sum(nil))
+}
+
+// multipartMD5 returns S3 ETag of multipart upload: MD5 of concatenated binary MD5 digests of parts and number of parts
+func multipartMD5(partMD5s []string) string {
+	digest := md5.New() //nolint:gosec // see import
+	for _, partMD5 := range partMD5s {
+		raw, _ := hex.DecodeString(partMD5)
+		digest.Write(raw)
+	}
+	return hex.EncodeToString(digest.Sum(nil)) + "-" + strconv.Itoa(len(partMD5s))
+}
@@ -0,0 +1,302 @@
+package receiver
+
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/orchestrator"
+	"extendable_storage/internal/service/storager"
+	"extendable_storage/internal/utils"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+const (
+	maxShards = 256 // reedsolomon limit of data and parity shards in total
+	// maxErasureCodedSize limits erasure coded file, it is buffered in memory with its parity
+	maxErasureCodedSize = 256 * 1024 * 1024
+	// maxPlacementAttempts is a number of salts tried to place shard on data keeper free of other shards
+	maxPlacementAttempts = 1000
+)
+
+func (o SaveOptions) isErasureCoded() bool {
+	return o.ParityShards > 0
+}
+
+func (o SaveOptions) validate() error {
+	if o.DataShards == 0 && o.ParityShards == 0 {
+		return nil
+	}
+	if o.DataShards < 1 || o.ParityShards < 1 || o.DataShards+o.ParityShards > maxShards {
+		return fmt.Errorf("%w: %d data and %d parity shards", ErrInvalidSaveOptions, o.DataShards, o.ParityShards)
+	}
+	return nil
+}
+
+// maxSize is a size limit of erasure coded file, every shard must fit into one chunk as well
+func (o SaveOptions) maxSize() int64 {
+	return min(maxErasureCodedSize, int64(o.DataShards)*entities.MaxChunkSize)
+}
+
+// saveErasureCoded splits file into data shards, calculates parity shards and ships all of them.
+// Parity depends on the whole file, so it is read into memory first, file above SaveOptions.maxSize is rejected.
+// Shards are placed on distinct data keepers, see spreadShards.
+func (s *Service) saveErasureCoded(ctx context.Context, fileID string, data io.Reader, size int64, opts SaveOptions,
+	complete func(sha256Sum, md5Sum string) error) error {
+	if size > opts.maxSize() {
+		return fmt.Errorf("%w: erasure coded file is limited to %d bytes", ErrFileTooLarge, opts.maxSize())
+	}
+	shardsNum := opts.DataShards + opts.ParityShards
+	if serving := s.router.ServingDataKeepers(); size > 0 && serving < shardsNum {
+		return fmt.Errorf("%w: %d shards, %d serving data keepers", ErrNotEnoughKeepers, shardsNum, serving)
+	}
+	fileData := make([]byte, size)
+	if _, err := io.ReadFull(data, fileData); err != nil {
+		return fmt.Errorf("error read file: %w", err)
+	}
+	chunks, shards, err := encodeShards(fileID, fileData, opts)
+	if err != nil {
+		return err
+	}
+	if err = s.spreadShards(chunks); err != nil {
+		return err
+	}
+	chunkList := make([]*entities.FileChunk, 0, len(shards))
+	created, err := s.repo.CreateFile(ctx, &entities.File{
+		ID:           fileID,
+		Chunks:       chunkList,
+		DataShards:   opts.DataShards,
+		ParityShards: opts.ParityShards,
+		Length:       size,
+		ContentType:  opts.ContentType,
+	})
+	if err != nil {
+		return fmt.Errorf("error save file chunks: %w", err)
+	}
+	if !created {
+		return ErrFileAlreadyExists
+	}
+	for i, chunk := range chunks {
+		if err = ctx.Err(); err != nil {
+			return s.failUpload(fileID, err)
+		}
+		chunkList = append(chunkList, chunk)
+		// store chunk before shipping, so background cleanup knows what to purge if upload is interrupted
+		if err = s.repo.UpdateFileChunks(ctx, fileID, chunkList); err != nil {
+			return s.failUpload(fileID, fmt.Errorf("error save file chunks: %w", err))
+		}
+		if err = s.router.SaveFileChunk(ctx, chunk, shards[i]); err != nil {
+			return s.failUpload(fileID, fmt.Errorf("error save file chunks: %w", err))
+		}
+	}
+
+	digest := newFileDigest()
+	_, _ = digest.Write(fileData)
+	return complete(digest.sums())
+}
+
+// encodeShards splits data into equal data shards and calculates parity shards for them. Empty data has no shards.
+func encodeShards(fileID string, data []byte, opts SaveOptions) ([]*entities.FileChunk, [][]byte, error) {
+	if len(data) == 0 {
+		return nil, nil, nil
+	}
+	enc, err := reedsolomon.New(opts.DataShards, opts.ParityShards)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error create encoder: %w", err)
+	}
+	shards, err := enc.Split(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error split file: %w", err)
+	}
+	if err = enc.Encode(shards); err != nil {
+		return nil, nil, fmt.Errorf("error encode parity: %w", err)
+	}
+	size := int64(len(data))
+	chunks := make([]*entities.FileChunk, 0, len(shards))
+	for i, shard := range shards {
+		chunk := &entities.FileChunk{
+			FileID:  fileID,
+			ChunkID: shardID(shard, i),
+			Role:    entities.ChunkRoleParity,
+			Index:   i,
+			Size:    int64(len(shard)),
+		}
+		if i < opts.DataShards {
+			// last data shards are padded with zeroes, keep size of real data
+			chunk.Role = entities.ChunkRoleData
+			chunk.Offset = int64(i) * int64(len(shard))
+			chunk.Size = max(0, min(chunk.Size, size-chunk.Offset))
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, shards, nil
+}
+
+// spreadShards gives every shard its own primary owner, so loss of one data keeper costs at most one shard.
+// Owner is derived from chunk id on the circle, shard which falls on taken owner gets salt appended to its id
+// until it falls on free one, so shards are found by the usual placement on read and moved by rebalance.
+func (s *Service) spreadShards(chunks []*entities.FileChunk) error {
+	owners := make(map[string]struct{}, len(chunks))
+	for _, chunk := range chunks {
+		id := chunk.ChunkID
+		for salt := 1; ; salt++ {
+			owner, err := s.router.ChunkOwner(chunk)
+			if err != nil {
+				return fmt.Errorf("error get shard owner: %w", err)
+			}
+			if _, taken := owners[owner]; !taken {
+				owners[owner] = struct{}{}
+				break
+			}
+			if salt > maxPlacementAttempts {
+				return fmt.Errorf("%w: no free data keeper for shard %d", ErrNotEnoughKeepers, chunk.Index)
+			}
+			chunk.ChunkID = fmt.Sprintf("%s_%d", id, salt)
+		}
+	}
+	return nil
+}
+
+// shardID is a hash of the shard with its index, padding shards of small file are equal and should not share storage key
+func shardID(shard []byte, index int) string {
+	return fmt.Sprintf("%s_%d", utils.HashData(shard), index)
+}
+
+// shardMatches checks that data is the content of the shard, its id can have placement salt appended
+func shardMatches(chunk *entities.FileChunk, data []byte) bool {
+	id := shardID(data, chunk.Index)
+	return chunk.ChunkID == id || strings.HasPrefix(chunk.ChunkID, id+"_")
+}
+
+// shardsDecoder reconstructs data shards of erasure coded file. All shards are loaded once on first request,
+// missing or damaged shards are restored from any DataShards of available ones. Damaged shards are saved back.
+type shardsDecoder struct {
+	ctx    context.Context
+	logger logger.AppLogger
+	router orchestrator.DataRouter
+	file   *entities.File
+	repair func(damaged []*entities.FileChunk, shards [][]byte)
+	once   sync.Once
+	shards [][]byte
+	err    error
+}
+
+func (s *Service) newShardsDecoder(ctx context.Context, file *entities.File) *shardsDecoder {
+	return &shardsDecoder{
+		ctx:    ctx,
+		logger: s.logger,
+		router: s.router,
+		file:   file,
+		repair: s.repairShards,
+	}
+}
+
+// shard returns content of data shard, trimmed to the size of file data in it
+func (d *shardsDecoder) shard(chunk *entities.FileChunk) ([]byte, error) {
+	d.once.Do(func() {
+		d.shards, d.err = d.reconstruct()
+	})
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d.shards[chunk.Index][:chunk.Size], nil
+}
+
+// readAll returns whole file content
+func (d *shardsDecoder) readAll() ([]byte, error) {
+	result := make([]byte, 0, max(0, d.file.Size()))
+	for _, chunk := range d.file.DataChunks() {
+		data, err := d.shard(chunk)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data...)
+	}
+	return result, nil
+}
+
+func (d *shardsDecoder) reconstruct() ([][]byte, error) {
+	enc, err := reedsolomon.New(d.file.DataShards, d.file.ParityShards)
+	if err != nil {
+		return nil, fmt.Errorf("error create decoder: %w", err)
+	}
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		available int
+		damaged   []*entities.FileChunk
+		shards    = make([][]byte, d.file.DataShards+d.file.ParityShards)
+	)
+	wg.Add(len(d.file.Chunks))
+	for _, chunk := range d.file.Chunks {
+		go func(chunk *entities.FileChunk) {
+			defer wg.Done()
+			if d.ctx.Err() != nil {
+				return
+			}
+			data, errG := d.router.GetFileChunk(d.ctx, chunk)
+			if errG == nil && !shardMatches(chunk, data) {
+				errG = fmt.Errorf("%w: %s", storager.ErrChunkCorrupted, chunk)
+			}
+			if errG != nil {
+				d.logger.Error("error get file shard", errG, slog.String("chunk", chunk.String()))
+				if errors.Is(errG, storager.ErrChunkCorrupted) {
+					mu.Lock()
+					damaged = append(damaged, chunk)
+					mu.Unlock()
+				}
+				return
+			}
+			mu.Lock()
+			shards[chunk.Index] = data
+			available++
+			mu.Unlock()
+		}(chunk)
+	}
+	wg.Wait()
+	if err = d.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if available < d.file.DataShards {
+		return nil, fmt.Errorf("%w: %d of %d available", ErrNotEnoughShards, available, d.file.DataShards)
+	}
+	if len(damaged) == 0 {
+		err = enc.ReconstructData(shards)
+	} else {
+		// damaged parity shards are restored as well to be saved back
+		err = enc.Reconstruct(shards)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reconstruct file: %w", err)
+	}
+	if len(damaged) > 0 {
+		d.repair(damaged, shards)
+	}
+	return shards, nil
+}
+
+// repairShards saves restored content of damaged shards in background, so they are not reconstructed on every read
+func (s *Service) repairShards(damaged []*entities.FileChunk, shards [][]byte) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for _, chunk := range damaged {
+			shard := shards[chunk.Index]
+			if !shardMatches(chunk, shard) {
+				s.logger.Error("error restore damaged shard", storager.ErrChunkCorrupted, slog.String("chunk", chunk.String()))
+				continue
+			}
+			if err := s.router.SaveFileChunk(s.ctx, chunk, shard); err != nil {
+				s.logger.Error("error repair damaged shard", err, slog.String("chunk", chunk.String()))
+				continue
+			}
+			s.logger.Info("damaged shard is repaired", slog.String("chunk", chunk.String()))
+		}
+	}()
+}
@@ -0,0 +1,178 @@
+package receiver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"extendable_storage/internal/entities"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxPartNumber = 10000
+)
+
+// InitiateUpload starts upload of the file, existence of the file is checked when upload is completed,
+// so existing file can be replaced by deleting it before completion
+func (s *Service) InitiateUpload(ctx context.Context, fileID string, opts SaveOptions) (string, error) {
+	if opts.isErasureCoded() {
+		return "", fmt.Errorf("%w: erasure coding isn't supported for multipart upload", ErrInvalidSaveOptions)
+	}
+	upload := &entities.Upload{ID: uuid.NewString(), FileID: fileID, ContentType: opts.ContentType}
+	if err := s.repoUpload.CreateUpload(ctx, upload); err != nil {
+		return "", fmt.Errorf("error create upload: %w", err)
+	}
+	return upload.ID, nil
+}
+
+func (s *Service) GetUpload(ctx context.Context, uploadID string) (*entities.Upload, error) {
+	upload, err := s.repoUpload.GetUpload(ctx, uploadID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error get upload: %w", err)
+	}
+	return upload, nil
+}
+
+// SavePart chunks the part and ships chunks one by one as SaveFileStream does. Chunks of every copy of the part
+// belong to the copy, so replaced copy is purged without touching the new one.
+func (s *Service) SavePart(ctx context.Context, uploadID string, number int, data io.Reader, size int64) (*entities.UploadPart, error) {
+	if number < 1 || number > maxPartNumber {
+		return nil, fmt.Errorf("%w: part number must be from 1 to %d", ErrInvalidPart, maxPartNumber)
+	}
+	if _, err := s.activeUpload(ctx, uploadID); err != nil {
+		return nil, err
+	}
+	part := &entities.UploadPart{ID: uuid.NewString(), UploadID: uploadID, Number: number, Size: size}
+	if err := s.repoUpload.CreatePart(ctx, part); err != nil {
+		return nil, fmt.Errorf("error create part: %w", err)
+	}
+	digest := newFileDigest()
+	chunks, err := s.saveChunks(ctx, part.ID, io.TeeReader(data, digest), size, func(chunks []*entities.FileChunk) error {
+		return s.repoUpload.UpdatePartChunks(ctx, part.ID, chunks)
+	})
+	if err != nil {
+		return nil, s.failPart(part.ID, err)
+	}
+	part.Chunks = chunks
+	part.SHA256, part.MD5 = digest.sums()
+	completed, err := s.repoUpload.CompletePart(ctx, part)
+	if err != nil {
+		return nil, s.failPart(part.ID, fmt.Errorf("error complete part: %w", err))
+	}
+	if !completed {
+		// upload is completed or aborted while part was uploaded, part is purged with the rest unused ones
+		return nil, ErrUploadNotFound
+	}
+	return part, nil
+}
+
+func (s *Service) ListParts(ctx context.Context, uploadID string) ([]*entities.UploadPart, error) {
+	if _, err := s.activeUpload(ctx, uploadID); err != nil {
+		return nil, err
+	}
+	parts, err := s.repoUpload.GetParts(ctx, uploadID)
+	if err != nil {
+		return nil, fmt.Errorf("error list parts: %w", err)
+	}
+	return parts, nil
+}
+
+// CompleteUpload joins chunks of the parts into completed file, chunks are not moved. MD5 of the file is
+// S3 multipart ETag "<md5 of part digests>-<parts>" and SHA-256 is unknown, as the whole file is never read.
+func (s *Service) CompleteUpload(ctx context.Context, uploadID string, numbers []int) error {
+	upload, err := s.activeUpload(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	parts, err := s.repoUpload.GetParts(ctx, uploadID)
+	if err != nil {
+		return fmt.Errorf("error list parts: %w", err)
+	}
+	byNumber := make(map[int]*entities.UploadPart, len(parts))
+	for _, part := range parts {
+		byNumber[part.Number] = part
+	}
+	if numbers == nil {
+		for _, part := range parts {
+			numbers = append(numbers, part.Number)
+		}
+	}
+	if len(numbers) == 0 {
+		return fmt.Errorf("%w: no parts uploaded", ErrInvalidPart)
+	}
+	// fast path, file created concurrently is detected when it is inserted
+	if err = s.checkFileNotExists(ctx, upload.FileID); err != nil {
+		return err
+	}
+
+	file := &entities.File{ID: upload.FileID, ContentType: upload.ContentType}
+	partIDs := make([]string, 0, len(numbers))
+	partMD5s := make([]string, 0, len(numbers))
+	for i, number := range numbers {
+		if i > 0 && number <= numbers[i-1] {
+			return fmt.Errorf("%w: part numbers must be ascending", ErrInvalidPart)
+		}
+		part, ok := byNumber[number]
+		if !ok {
+			return fmt.Errorf("%w: part %d is not uploaded", ErrInvalidPart, number)
+		}
+		for _, chunk := range part.Chunks {
+			fileChunk := *chunk
+			fileChunk.Offset += file.Length
+			file.Chunks = append(file.Chunks, &fileChunk)
+		}
+		file.Length += part.Size
+		partIDs = append(partIDs, part.ID)
+		partMD5s = append(partMD5s, part.MD5)
+	}
+	file.MD5 = multipartMD5(partMD5s)
+	completed, fileCreated, err := s.repoUpload.CompleteUpload(ctx, uploadID, file, partIDs)
+	if err != nil {
+		return fmt.Errorf("error complete upload: %w", err)
+	}
+	if !completed {
+		return ErrUploadNotFound
+	}
+	if !fileCreated {
+		return ErrFileAlreadyExists
+	}
+	return nil
+}
+
+func (s *Service) AbortUpload(ctx context.Context, uploadID string) error {
+	aborted, err := s.repoUpload.AbortUpload(ctx, uploadID)
+	if err != nil {
+		return fmt.Errorf("error abort upload: %w", err)
+	}
+	if !aborted {
+		return ErrUploadNotFound
+	}
+	return nil
+}
+
+// activeUpload returns upload which parts are being uploaded, ErrUploadNotFound for completed or aborted one
+func (s *Service) activeUpload(ctx context.Context, uploadID string) (*entities.Upload, error) {
+	upload, err := s.GetUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Status != entities.FileStatusNew {
+		return nil, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+// failPart marks part for purge, so background cleanup removes already shipped chunks
+func (s *Service) failPart(partID string, err error) error {
+	// request context can be already canceled here, so use service context
+	if errS := s.repoUpload.SetPartStatus(s.ctx, partID, entities.FileStatusPurge); errS != nil {
+		return fmt.Errorf("error update part status: %w", errS)
+	}
+	return err
+}
@@ -1,35 +1,50 @@
 package receiver
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
 	"extendable_storage/internal/entities"
 	"extendable_storage/internal/logger"
+	"extendable_storage/internal/repository/bucket"
 	"extendable_storage/internal/repository/file"
+	"extendable_storage/internal/repository/upload"
 	"extendable_storage/internal/service/orchestrator"
-	"extendable_storage/internal/utils"
+	"extendable_storage/internal/service/storager"
 	"fmt"
 	"log/slog"
 	"sync"
 )
 
+const (
+	chunksNum = 6 // number of chunks to split file into, file bigger than chunksNum * entities.MaxChunkSize gets more
+
+	tmpFilePrefix     = ".tmp/"     // id of new file version until it replaces the old one
+	retiredFilePrefix = ".retired/" // id of replaced file version until its chunks are purged
+)
+
 type Service struct {
-	ctx    context.Context
-	wg     sync.WaitGroup
-	logger logger.AppLogger
-	router orchestrator.DataRouter
-	repo   *file.Repo
+	ctx        context.Context
+	wg         sync.WaitGroup
+	logger     logger.AppLogger
+	router     orchestrator.DataRouter
+	repo       *file.Repo
+	repoBucket *bucket.Repo
+	repoUpload *upload.Repo
 }
 
 var _ DataReceiver = (*Service)(nil)
 
-func NewService(ctx context.Context, log logger.AppLogger, router orchestrator.DataRouter, repo *file.Repo) *Service {
+func NewService(ctx context.Context, log logger.AppLogger, router orchestrator.DataRouter, repo *file.Repo, repoBucket *bucket.Repo,
+	repoUpload *upload.Repo) *Service {
 	srv := &Service{
-		ctx:    ctx,
-		logger: log.With(slog.String("service", "receiver")),
-		router: router,
-		repo:   repo,
+		ctx:        ctx,
+		logger:     log.With(slog.String("service", "receiver")),
+		router:     router,
+		repo:       repo,
+		repoBucket: repoBucket,
+		repoUpload: repoUpload,
 	}
 	go srv.cleanupBadChunks()
 	return srv
@@ -40,10 +55,14 @@ func (s *Service) Stop() {
 }
 
 func (s *Service) GetFile(ctx context.Context, fileID string) ([]byte, error) {
-	chunks, err := s.repo.GetFileChunks(ctx, fileID)
+	file, err := s.getCompleteFile(ctx, fileID)
 	if err != nil {
 		return nil, fmt.Errorf("error get file chunks: %w", err)
 	}
+	if file.IsErasureCoded() {
+		return s.newShardsDecoder(ctx, file).readAll()
+	}
+	chunks := file.Chunks
 	data := make(map[int][]byte, len(chunks))
 	var (
 		wg       sync.WaitGroup
@@ -55,7 +74,10 @@ func (s *Service) GetFile(ctx context.Context, fileID string) ([]byte, error) {
 	for i := range chunks {
 		go func(j int) {
 			defer wg.Done()
-			singleChunk, err := s.router.GetFileChunk(chunks[j])
+			singleChunk, err := s.router.GetFileChunk(ctx, chunks[j])
+			if err == nil && !chunks[j].Verify(singleChunk) {
+				err = fmt.Errorf("%w: %s", storager.ErrChunkCorrupted, chunks[j])
+			}
 			mu.Lock()
 			defer mu.Unlock()
 			if err != nil {
@@ -73,7 +95,7 @@ func (s *Service) GetFile(ctx context.Context, fileID string) ([]byte, error) {
 	result := make([]byte, 0, totalLen)
 	for i := 0; i < len(data); i++ {
 		if len(data[i]) == 0 {
-			return nil, fmt.Errorf("error get file chunks: %w", errList[0])
+			return nil, fmt.Errorf("error get file chunks: chunk %d is empty", i)
 		}
 		result = append(result, data[i]...)
 	}
@@ -81,52 +103,58 @@ func (s *Service) GetFile(ctx context.Context, fileID string) ([]byte, error) {
 }
 
 func (s *Service) SaveFile(ctx context.Context, fileID string, data []byte) error {
-	chunks, err := s.repo.GetFileChunks(ctx, fileID)
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return fmt.Errorf("error check file exists: %w", err)
+	return s.SaveFileStream(ctx, fileID, bytes.NewReader(data), int64(len(data)), SaveOptions{})
+}
+
+// getCompleteFile returns file which is completely saved, file which is being saved or purged is reported
+// as missing, its chunks are not all in place
+func (s *Service) getCompleteFile(ctx context.Context, fileID string) (*entities.File, error) {
+	file, err := s.repo.GetFile(ctx, fileID)
+	if err != nil {
+		return nil, err
 	}
-	if len(chunks) > 0 {
-		return fmt.Errorf("file already exists")
+	if file.Status != entities.FileStatusComplete {
+		return nil, fmt.Errorf("file %s is %s: %w", fileID, file.Status, sql.ErrNoRows)
 	}
-	chunkedFile := chunkData(data, 6)
+	return file, nil
+}
 
-	chunkList := make([]*entities.FileChunk, 0, len(chunkedFile))
-	for i := range chunkedFile {
-		chunkList = append(chunkList, &entities.FileChunk{
-			FileID:  fileID,
-			ChunkID: utils.HashData(chunkedFile[i]),
-		})
+func (s *Service) checkFileNotExists(ctx context.Context, fileID string) error {
+	_, err := s.repo.GetFileChunks(ctx, fileID)
+	if err == nil {
+		return ErrFileAlreadyExists
 	}
-	if err := s.repo.SaveFileChunks(ctx, fileID, chunkList); err != nil {
-		return fmt.Errorf("error save file chunks: %w", err)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error check file exists: %w", err)
 	}
+	return nil
+}
 
-	var (
-		wg sync.WaitGroup
-		mu sync.Mutex
-	)
-	errList := make([]error, 0, len(chunkedFile))
-	wg.Add(len(chunkedFile))
-	for i := range chunkedFile {
-		go func(j int) {
-			defer wg.Done()
-			if err := s.router.SaveFileChunk(chunkList[j], chunkedFile[j]); err != nil {
-				mu.Lock()
-				errList = append(errList, err)
-				mu.Unlock()
-			}
-		}(i)
+// DeleteFile marks file for purge and removes its chunks from storage nodes. Chunks which can't be purged now
+// are removed by background cleanup, file is not served anymore anyway.
+func (s *Service) DeleteFile(ctx context.Context, fileID string) error {
+	file, err := s.repo.GetFile(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("error get file: %w", err)
 	}
-	wg.Wait()
-	if len(errList) > 0 {
-		if err := s.repo.SetFileStatus(ctx, fileID, entities.FileStatusPurge); err != nil {
-			return fmt.Errorf("error update file chunks status: %w", err)
-		}
-		return fmt.Errorf("error save file chunks: %w", errList[0])
+	if err = s.repo.SetFileStatus(ctx, fileID, entities.FileStatusPurge); err != nil {
+		return fmt.Errorf("error mark file for purge: %w", err)
 	}
-
-	if err := s.repo.SetFileStatus(ctx, fileID, entities.FileStatusComplete); err != nil {
-		return fmt.Errorf("error mark file chunks completed: %w", err)
+	if err = s.router.PurgeFileChunks(ctx, file.Chunks); err != nil {
+		s.logger.Error("error purge deleted file chunks, left for background cleanup", err, slog.String("file_id", fileID))
+		return nil
+	}
+	if err = s.repo.DeleteFile(ctx, fileID); err != nil {
+		return fmt.Errorf("error delete file: %w", err)
 	}
 	return nil
 }
+
+// ListFiles returns up to limit completed files which id starts with prefix, ordered by id after startAfter
+func (s *Service) ListFiles(ctx context.Context, prefix, startAfter string, limit int) ([]*entities.File, error) {
+	files, err := s.repo.ListFiles(ctx, prefix, startAfter, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error list files: %w", err)
+	}
+	return files, nil
+}
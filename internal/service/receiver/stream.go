@@ -0,0 +1,259 @@
+package receiver
+
+import (
+	"context"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/orchestrator"
+	"extendable_storage/internal/service/storager"
+	"extendable_storage/internal/utils"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/google/uuid"
+)
+
+// chunksReader loads file chunks from router one by one in order, so only one chunk is kept in memory
+type chunksReader struct {
+	ctx     context.Context
+	router  orchestrator.DataRouter
+	chunks  []*entities.FileChunk
+	buf     []byte
+	skip    int64          // bytes to skip from the beginning of the first chunk
+	decoder *shardsDecoder // reconstructs data shards of erasure coded file which can't be loaded
+}
+
+func (s *Service) newChunksReader(ctx context.Context, file *entities.File, chunks []*entities.FileChunk) *chunksReader {
+	reader := &chunksReader{
+		ctx:    ctx,
+		router: s.router,
+		chunks: chunks,
+	}
+	if file.IsErasureCoded() {
+		reader.decoder = s.newShardsDecoder(ctx, file)
+	}
+	return reader
+}
+
+func (r *chunksReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if len(r.chunks) == 0 {
+			return 0, io.EOF
+		}
+		if err := r.ctx.Err(); err != nil {
+			return 0, err
+		}
+		data, err := r.loadChunk(r.chunks[0])
+		if err != nil {
+			return 0, fmt.Errorf("error get file chunk: %w", err)
+		}
+		r.buf = data
+		r.chunks = r.chunks[1:]
+		if r.skip > 0 {
+			skip := min(r.skip, int64(len(r.buf)))
+			r.buf = r.buf[skip:]
+			r.skip -= skip
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *chunksReader) loadChunk(chunk *entities.FileChunk) ([]byte, error) {
+	data, err := r.router.GetFileChunk(r.ctx, chunk)
+	if r.decoder == nil {
+		if err == nil && !chunk.Verify(data) {
+			return nil, fmt.Errorf("%w: %s", storager.ErrChunkCorrupted, chunk)
+		}
+		return data, err
+	}
+	if err != nil || !shardMatches(chunk, data) {
+		return r.decoder.shard(chunk)
+	}
+	// data shard is padded with zeroes
+	return data[:chunk.Size], nil
+}
+
+func (r *chunksReader) Close() error {
+	r.chunks = nil
+	r.buf = nil
+	return nil
+}
+
+// GetFileStream returns reader which loads file chunks on demand and size of the file.
+// Size is -1 if it is unknown (file was stored before chunk sizes were tracked).
+func (s *Service) GetFileStream(ctx context.Context, fileID string) (io.ReadCloser, int64, error) {
+	file, err := s.getCompleteFile(ctx, fileID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error get file chunks: %w", err)
+	}
+	return s.newChunksReader(ctx, file, file.DataChunks()), file.Size(), nil
+}
+
+// GetFileRange returns reader of length bytes of the file starting from offset.
+// Only chunks which overlap the range are loaded.
+func (s *Service) GetFileRange(ctx context.Context, fileID string, offset, length int64) (io.ReadCloser, error) {
+	file, err := s.getCompleteFile(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("error get file chunks: %w", err)
+	}
+	size := file.Size()
+	if size < 0 {
+		return nil, ErrRangeNotSupported
+	}
+	if offset < 0 || length <= 0 || offset+length > size {
+		return nil, ErrRangeNotSatisfiable
+	}
+	end := offset + length
+	chunks := make([]*entities.FileChunk, 0, len(file.Chunks))
+	for _, chunk := range file.DataChunks() {
+		if chunk.Offset < end && chunk.Offset+chunk.Size > offset {
+			chunks = append(chunks, chunk)
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("%w: no chunks in range of file %s", ErrRangeNotSatisfiable, fileID)
+	}
+	reader := s.newChunksReader(ctx, file, chunks)
+	reader.skip = offset - chunks[0].Offset
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(reader, length), reader}, nil
+}
+
+// GetFileInfo returns file metadata without loading its content
+func (s *Service) GetFileInfo(ctx context.Context, fileID string) (*entities.File, error) {
+	file, err := s.getCompleteFile(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("error get file: %w", err)
+	}
+	return file, nil
+}
+
+// SaveFileStream reads file of given size chunk by chunk and ships each chunk as soon as it is read,
+// so only one chunk is kept in memory. Erasure coded file is read into memory at once, see saveErasureCoded.
+// Digests of the whole file are calculated while it is read and stored when upload is completed.
+func (s *Service) SaveFileStream(ctx context.Context, fileID string, data io.Reader, size int64, opts SaveOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	// fast path, file created concurrently is detected when it is inserted
+	if err := s.checkFileNotExists(ctx, fileID); err != nil {
+		return err
+	}
+	return s.saveStream(ctx, fileID, data, size, opts, func(sha256Sum, md5Sum string) error {
+		if err := s.repo.CompleteFile(ctx, fileID, sha256Sum, md5Sum); err != nil {
+			return fmt.Errorf("error mark file chunks completed: %w", err)
+		}
+		return nil
+	})
+}
+
+// ReplaceFileStream saves file like SaveFileStream, but complete file with the same id is replaced.
+// New version is saved under temporary id and swapped with the old one only when all its chunks are shipped,
+// so the old version is served until then and stays untouched if saving fails.
+// ErrFileAlreadyExists is returned if file with the same id is being saved at the moment.
+func (s *Service) ReplaceFileStream(ctx context.Context, fileID string, data io.Reader, size int64, opts SaveOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	// temporary ids can't clash with file ids, which are not empty before "/"
+	tmpID, retiredID := tmpFilePrefix+uuid.NewString(), retiredFilePrefix+uuid.NewString()
+	var replaced *entities.File
+	err := s.saveStream(ctx, tmpID, data, size, opts, func(sha256Sum, md5Sum string) error {
+		file, ok, err := s.repo.ReplaceFile(ctx, fileID, tmpID, retiredID, sha256Sum, md5Sum)
+		if err != nil {
+			return s.failUpload(tmpID, fmt.Errorf("error replace file: %w", err))
+		}
+		if !ok {
+			return s.failUpload(tmpID, ErrFileAlreadyExists)
+		}
+		replaced = file
+		return nil
+	})
+	if err != nil || replaced == nil {
+		return err
+	}
+	// replaced version is in purge status already, background cleanup removes it if chunks can't be purged now
+	if err = s.router.PurgeFileChunks(ctx, replaced.Chunks); err != nil {
+		s.logger.Error("error purge replaced file chunks, left for background cleanup", err, slog.String("file_id", fileID))
+		return nil
+	}
+	if err = s.repo.DeleteFile(ctx, retiredID); err != nil {
+		return fmt.Errorf("error delete replaced file: %w", err)
+	}
+	return nil
+}
+
+// saveStream saves file content under fileID, complete is called when all chunks are shipped
+func (s *Service) saveStream(ctx context.Context, fileID string, data io.Reader, size int64, opts SaveOptions,
+	complete func(sha256Sum, md5Sum string) error) error {
+	if opts.isErasureCoded() {
+		return s.saveErasureCoded(ctx, fileID, data, size, opts, complete)
+	}
+	created, err := s.repo.CreateFile(ctx, &entities.File{
+		ID:          fileID,
+		Chunks:      []*entities.FileChunk{},
+		Length:      size,
+		ContentType: opts.ContentType,
+	})
+	if err != nil {
+		return fmt.Errorf("error save file chunks: %w", err)
+	}
+	if !created {
+		return ErrFileAlreadyExists
+	}
+	digest := newFileDigest()
+	_, err = s.saveChunks(ctx, fileID, io.TeeReader(data, digest), size, func(chunks []*entities.FileChunk) error {
+		return s.repo.UpdateFileChunks(ctx, fileID, chunks)
+	})
+	if err != nil {
+		return s.failUpload(fileID, err)
+	}
+
+	return complete(digest.sums())
+}
+
+// saveChunks splits data of given size into chunks of fileID and ships them one by one. Chunk list is recorded
+// before every chunk is shipped, so background cleanup knows what to purge if upload is interrupted.
+func (s *Service) saveChunks(ctx context.Context, fileID string, data io.Reader, size int64,
+	record func(chunks []*entities.FileChunk) error) ([]*entities.FileChunk, error) {
+	sizes := chunkSizes(size, max(chunksNum, int((size+entities.MaxChunkSize-1)/entities.MaxChunkSize)))
+	chunkList := make([]*entities.FileChunk, 0, len(sizes))
+	offset := int64(0)
+	for _, chunkSize := range sizes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		chunkData := make([]byte, chunkSize)
+		if _, err := io.ReadFull(data, chunkData); err != nil {
+			return nil, fmt.Errorf("error read file chunk: %w", err)
+		}
+		chunk := &entities.FileChunk{
+			FileID:  fileID,
+			ChunkID: utils.HashData(chunkData),
+			Offset:  offset,
+			Size:    chunkSize,
+		}
+		offset += chunkSize
+		chunkList = append(chunkList, chunk)
+		if err := record(chunkList); err != nil {
+			return nil, fmt.Errorf("error save file chunks: %w", err)
+		}
+		if err := s.router.SaveFileChunk(ctx, chunk, chunkData); err != nil {
+			return nil, fmt.Errorf("error save file chunks: %w", err)
+		}
+	}
+	return chunkList, nil
+}
+
+// failUpload marks file for purge, so background cleanup removes already shipped chunks
+func (s *Service) failUpload(fileID string, err error) error {
+	// request context can be already canceled here, so use service context
+	if errS := s.repo.SetFileStatus(s.ctx, fileID, entities.FileStatusPurge); errS != nil {
+		return fmt.Errorf("error update file chunks status: %w", errS)
+	}
+	return err
+}
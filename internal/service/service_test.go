@@ -1,22 +1,358 @@
 package service_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/orchestrator"
+	"extendable_storage/internal/service/receiver"
 	"extendable_storage/internal/service/storager"
 	testhelpers "extendable_storage/internal/test_helpers"
+	"extendable_storage/internal/transport/keeper"
+	"extendable_storage/internal/utils"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
 )
 
+type storageFactory func(name string, maxLimitMB int) storager.DataKeeper
+
 func TestSaveData(t *testing.T) {
+	container := testhelpers.GetClean(t)
+	checkSaveData(t, container, func(name string, maxLimitMB int) storager.DataKeeper {
+		srv := storager.NewService(container.Ctx, &storager.Config{
+			MaxLimitMB: maxLimitMB,
+			NodeID:     name,
+			DataDir:    t.TempDir(),
+		}, container.Logger)
+		<-srv.UsageReady()
+		return srv
+	})
+}
+
+func TestSaveDataReplicated(t *testing.T) {
+	container := testhelpers.GetCleanReplicated(t, &orchestrator.Config{ReplicationFactor: 2})
+	dataDirs := make(map[string]string)
+	dataMap := checkSaveData(t, container, func(name string, maxLimitMB int) storager.DataKeeper {
+		dataDirs[name] = t.TempDir()
+		srv := storager.NewService(container.Ctx, &storager.Config{
+			MaxLimitMB: maxLimitMB,
+			NodeID:     name,
+			DataDir:    dataDirs[name],
+		}, container.Logger)
+		<-srv.UsageReady()
+		return srv
+	})
+
+	t.Run("corrupted chunks should be served by replica and repaired", func(t *testing.T) {
+		// given
+		original := make(map[string][]byte)
+		require.NoError(t, filepath.WalkDir(dataDirs["NODE_C"], func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			data, errR := os.ReadFile(path)
+			if errR != nil {
+				return errR
+			}
+			original[path] = slices.Clone(data)
+			data[0] ^= 0xff
+			return os.WriteFile(path, data, 0600)
+		}))
+		require.NotEmpty(t, original)
+
+		// when, then
+		for id, data := range dataMap {
+			receivedData, err := container.ServiceReceiver.GetFile(container.Ctx, id)
+			require.NoError(t, err)
+			require.Equal(t, data, receivedData)
+		}
+		require.Eventually(t, func() bool {
+			for path, data := range original {
+				if stored, err := os.ReadFile(path); err != nil || !bytes.Equal(data, stored) {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("data should be served by replica when node lost its disk", func(t *testing.T) {
+		// given
+		require.NoError(t, os.RemoveAll(dataDirs["NODE_B"]))
+
+		// when, then
+		for id, data := range dataMap {
+			receivedData, err := container.ServiceReceiver.GetFile(container.Ctx, id)
+			require.NoError(t, err)
+			require.Equal(t, data, receivedData)
+		}
+	})
+}
+
+func TestSaveDataErasureCoded(t *testing.T) {
+	// given
+	container := testhelpers.GetClean(t)
+	for _, name := range []string{"NODE_A", "NODE_B", "NODE_C", "NODE_D", "NODE_E", "NODE_F", "NODE_G", "NODE_H", "NODE_I"} {
+		srv := storager.NewService(container.Ctx, &storager.Config{
+			MaxLimitMB: 10,
+			NodeID:     name,
+			DataDir:    t.TempDir(),
+		}, container.Logger)
+		<-srv.UsageReady()
+		require.NoError(t, container.ServiceOrchestrator.AddDataKeeper(container.Ctx, name, srv))
+		waitRebalance(t, container.ServiceOrchestrator, name)
+	}
+	fileID := uuid.NewString()
+	data := testhelpers.GenerateMBData(t, 1.3)
+	opts := receiver.SaveOptions{DataShards: 6, ParityShards: 3}
+
+	// when
+	require.NoError(t, container.ServiceReceiver.SaveFileStream(container.Ctx, fileID, bytes.NewReader(data), int64(len(data)), opts))
+
+	// then
+	file, err := container.RepoFile.GetFile(container.Ctx, fileID)
+	require.NoError(t, err)
+	require.Equal(t, 6, file.DataShards)
+	require.Equal(t, 3, file.ParityShards)
+	require.Len(t, file.Chunks, 9)
+	require.Equal(t, int64(len(data)), file.Size())
+	owners := make(map[string]struct{})
+	for _, chunk := range file.Chunks {
+		owner, errO := container.ServiceOrchestrator.ChunkOwner(chunk)
+		require.NoError(t, errO)
+		owners[owner] = struct{}{}
+	}
+	require.Len(t, owners, 9, "every shard should have its own data keeper")
+
+	t.Run("salted shards should be streamed without reconstruction", func(t *testing.T) {
+		// given
+		salted := slices.ContainsFunc(file.Chunks, func(chunk *entities.FileChunk) bool {
+			return strings.Count(chunk.ChunkID, "_") > 1
+		})
+		require.True(t, salted, "some shards should be salted to be placed apart")
+		router := &countingRouter{DataRouter: container.ServiceOrchestrator}
+		service := receiver.NewService(container.Ctx, container.Logger, router, container.RepoFile, container.RepoBucket, container.RepoUpload)
+
+		// when
+		reader, _, errS := service.GetFileStream(container.Ctx, fileID)
+		require.NoError(t, errS)
+		receivedData, errS := io.ReadAll(reader)
+		require.NoError(t, errS)
+		require.NoError(t, reader.Close())
+		offset, length := int64(len(data)/7), int64(len(data)/2)
+		reader, errS = service.GetFileRange(container.Ctx, fileID, offset, length)
+		require.NoError(t, errS)
+		receivedRange, errS := io.ReadAll(reader)
+		require.NoError(t, errS)
+		require.NoError(t, reader.Close())
+
+		// then
+		require.Equal(t, data, receivedData)
+		require.Equal(t, data[offset:offset+length], receivedRange)
+		// only data shards are loaded: 6 for the whole file and the ones overlapping the range
+		rangeShards := 0
+		for _, chunk := range file.DataChunks() {
+			if chunk.Offset < offset+length && chunk.Offset+chunk.Size > offset {
+				rangeShards++
+			}
+		}
+		require.Equal(t, int64(6+rangeShards), router.reads.Load())
+	})
+
+	t.Run("file with more shards than data keepers should be rejected", func(t *testing.T) {
+		// when
+		errS := container.ServiceReceiver.SaveFileStream(container.Ctx, uuid.NewString(), bytes.NewReader(data), int64(len(data)),
+			receiver.SaveOptions{DataShards: 8, ParityShards: 2})
+
+		// then
+		require.ErrorIs(t, errS, receiver.ErrNotEnoughKeepers)
+	})
+
+	t.Run("too large file should be rejected before it is read", func(t *testing.T) {
+		// when
+		errS := container.ServiceReceiver.SaveFileStream(container.Ctx, uuid.NewString(), bytes.NewReader(data), 1<<40, opts)
+
+		// then
+		require.ErrorIs(t, errS, receiver.ErrFileTooLarge)
+	})
+
+	t.Run("file should be reconstructed without parity count of shards", func(t *testing.T) {
+		// given
+		require.NoError(t, container.ServiceOrchestrator.PurgeFileChunks(container.Ctx, []*entities.FileChunk{file.Chunks[0], file.Chunks[2], file.Chunks[7]}))
+
+		// when
+		receivedData, errG := container.ServiceReceiver.GetFile(container.Ctx, fileID)
+
+		// then
+		require.NoError(t, errG)
+		require.Equal(t, data, receivedData)
+
+		reader, size, errS := container.ServiceReceiver.GetFileStream(container.Ctx, fileID)
+		require.NoError(t, errS)
+		require.Equal(t, int64(len(data)), size)
+		receivedData, errS = io.ReadAll(reader)
+		require.NoError(t, errS)
+		require.NoError(t, reader.Close())
+		require.Equal(t, data, receivedData)
+
+		offset, length := int64(len(data)/7), int64(len(data)/2)
+		reader, errS = container.ServiceReceiver.GetFileRange(container.Ctx, fileID, offset, length)
+		require.NoError(t, errS)
+		receivedData, errS = io.ReadAll(reader)
+		require.NoError(t, errS)
+		require.NoError(t, reader.Close())
+		require.Equal(t, data[offset:offset+length], receivedData)
+	})
+
+	t.Run("file should not be served if too many shards lost", func(t *testing.T) {
+		// given
+		require.NoError(t, container.ServiceOrchestrator.PurgeFileChunks(container.Ctx, []*entities.FileChunk{file.Chunks[4]}))
+
+		// when
+		_, errG := container.ServiceReceiver.GetFile(container.Ctx, fileID)
+
+		// then
+		require.ErrorIs(t, errG, receiver.ErrNotEnoughShards)
+	})
+}
+
+// countingRouter counts chunks loaded through it
+type countingRouter struct {
+	orchestrator.DataRouter
+	reads atomic.Int64
+}
+
+func (r *countingRouter) GetFileChunk(ctx context.Context, chunk *entities.FileChunk) ([]byte, error) {
+	r.reads.Add(1)
+	return r.DataRouter.GetFileChunk(ctx, chunk)
+}
+
+func TestSaveDataRemoteNodes(t *testing.T) {
+	container := testhelpers.GetClean(t)
+	dataMap := checkSaveData(t, container, func(name string, maxLimitMB int) storager.DataKeeper {
+		return testhelpers.StartStorageNode(t, container.Ctx, container.Logger, name, maxLimitMB)
+	})
+
+	t.Run("topology should be restored after restart", func(t *testing.T) {
+		// when
+		serviceOrchestrator, err := orchestrator.NewService(container.Ctx, container.Logger, container.ReplicationConf, container.RepoTopology, keeper.Connector(testhelpers.NodeSecret))
+		require.NoError(t, err)
+		serviceReceiver := receiver.NewService(container.Ctx, container.Logger, serviceOrchestrator, container.RepoFile, container.RepoBucket, container.RepoUpload)
+
+		// then
+		for id, data := range dataMap {
+			receivedData, errG := serviceReceiver.GetFile(container.Ctx, id)
+			require.NoError(t, errG)
+			require.Equal(t, data, receivedData)
+		}
+	})
+}
+
+func TestMigrateLayout(t *testing.T) {
+	// given
+	container := testhelpers.GetClean(t)
+	dataDirs := make(map[string]string)
+	for _, name := range []string{"NODE_A", "NODE_B", "NODE_C"} {
+		dataDirs[name] = t.TempDir()
+		srv := storager.NewService(container.Ctx, &storager.Config{MaxLimitMB: 10, NodeID: name, DataDir: dataDirs[name]}, container.Logger)
+		<-srv.UsageReady()
+		require.NoError(t, container.ServiceOrchestrator.AddDataKeeper(container.Ctx, name, srv))
+		waitRebalance(t, container.ServiceOrchestrator, name)
+	}
+	dataMap := map[string][]byte{
+		uuid.NewString(): testhelpers.GenerateMBData(t, 1),
+		uuid.NewString(): testhelpers.GenerateMBData(t, 2.1),
+	}
+	for id, data := range dataMap {
+		require.NoError(t, container.ServiceReceiver.SaveFile(container.Ctx, id, data))
+	}
+	// part of upload which is completed after migration
+	uploadFileID := uuid.NewString()
+	uploadID, err := container.ServiceReceiver.InitiateUpload(container.Ctx, uploadFileID, receiver.SaveOptions{})
+	require.NoError(t, err)
+	partData := testhelpers.GenerateMBData(t, 1.5)
+	_, err = container.ServiceReceiver.SavePart(container.Ctx, uploadID, 1, bytes.NewReader(partData), int64(len(partData)))
+	require.NoError(t, err)
+
+	// when
+	conf := &orchestrator.Config{Sectors: 1 << 12}
+	storages := make(map[string]storager.DataKeeper)
+	serviceOrchestrator, err := orchestrator.NewService(container.Ctx, container.Logger, conf, container.RepoTopology,
+		func(serverID, _ string) (storager.DataKeeper, error) {
+			srv := storager.NewService(container.Ctx, &storager.Config{MaxLimitMB: 10, NodeID: serverID, DataDir: dataDirs[serverID], Sectors: conf.Sectors}, container.Logger)
+			<-srv.UsageReady()
+			storages[serverID] = srv
+			return srv, nil
+		})
+	require.NoError(t, err)
+	serviceReceiver := receiver.NewService(container.Ctx, container.Logger, serviceOrchestrator, container.RepoFile, container.RepoBucket, container.RepoUpload)
+
+	// then
+	require.True(t, serviceOrchestrator.LayoutMigrationRequired())
+	require.ErrorIs(t, serviceOrchestrator.RemoveDataKeeper(container.Ctx, "NODE_A"), orchestrator.ErrLayoutMigration)
+	for id, data := range dataMap {
+		receivedData, errG := serviceReceiver.GetFile(container.Ctx, id)
+		require.NoError(t, errG)
+		require.Equal(t, data, receivedData)
+	}
+
+	t.Run("data should be served from new layout after migration", func(t *testing.T) {
+		// when
+		catalog := storager.ChunkCatalogs{container.RepoFile, container.RepoUpload}
+		require.NoError(t, serviceOrchestrator.MigrateLayout(container.Ctx, catalog))
+
+		// then
+		require.False(t, serviceOrchestrator.LayoutMigrationRequired())
+		nodes, errN := container.RepoTopology.GetNodes(container.Ctx)
+		require.NoError(t, errN)
+		for _, node := range nodes {
+			require.Equal(t, conf.Sectors, node.Sectors)
+		}
+		for id, data := range dataMap {
+			receivedData, errG := serviceReceiver.GetFile(container.Ctx, id)
+			require.NoError(t, errG)
+			require.Equal(t, data, receivedData)
+		}
+		var total float64
+		for name, srv := range storages {
+			usage, errU := srv.GetUsage(container.Ctx)
+			require.NoError(t, errU)
+			t.Logf("%s usage: %.2f", name, usage)
+			total += usage
+		}
+		require.NotZero(t, total)
+	})
+	t.Run("upload should be completed from parts moved by migration", func(t *testing.T) {
+		// when
+		require.NoError(t, serviceReceiver.CompleteUpload(container.Ctx, uploadID, nil))
+
+		// then
+		receivedData, errG := serviceReceiver.GetFile(container.Ctx, uploadFileID)
+		require.NoError(t, errG)
+		require.Equal(t, partData, receivedData)
+	})
+}
+
+func checkSaveData(t *testing.T, container *testhelpers.TestContainer, newStorage storageFactory) map[string][]byte {
 	// given
 	dataMap := map[string][]byte{
 		uuid.NewString(): testhelpers.GenerateMBData(t, 1),
 		uuid.NewString(): testhelpers.GenerateMBData(t, 2.1),
 		uuid.NewString(): testhelpers.GenerateMBData(t, 3.2),
 	}
-	container := testhelpers.GetClean(t)
 	storageClusterUsage := map[string]float64{
 		"NODE_A": 0,
 		"NODE_B": 0,
@@ -27,7 +363,7 @@ func TestSaveData(t *testing.T) {
 	}
 	storageClusters := make(map[string]storager.DataKeeper)
 	for storageName := range storageClusterUsage {
-		srv := addStorage(t, container, storageName, 6)
+		srv := addStorage(t, container, newStorage, storageName, 6)
 		storageClusters[storageName] = srv
 	}
 	container.ServiceOrchestrator.PrintServerPositions()
@@ -45,8 +381,173 @@ func TestSaveData(t *testing.T) {
 		require.Equal(t, data, receivedData)
 	}
 
+	t.Run("should stream file", func(t *testing.T) {
+		// given
+		streamID := uuid.NewString()
+		data := testhelpers.GenerateMBData(t, 1.5)
+
+		// when
+		require.NoError(t, container.ServiceReceiver.SaveFileStream(container.Ctx, streamID, bytes.NewReader(data), int64(len(data)), receiver.SaveOptions{}))
+
+		// then
+		reader, size, err := container.ServiceReceiver.GetFileStream(container.Ctx, streamID)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(data)), size)
+		receivedData, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		require.Equal(t, data, receivedData)
+		info, err := container.ServiceReceiver.GetFileInfo(container.Ctx, streamID)
+		require.NoError(t, err)
+		require.Equal(t, utils.HashData(data), info.SHA256)
+		require.Equal(t, int64(len(data)), info.Length)
+		dataMap[streamID] = data
+	})
+
+	t.Run("should assemble file from multipart upload", func(t *testing.T) {
+		// given
+		fileID := uuid.NewString()
+		data := testhelpers.GenerateMBData(t, 1.5)
+		half := len(data) / 2
+		uploadID, err := container.ServiceReceiver.InitiateUpload(container.Ctx, fileID, receiver.SaveOptions{ContentType: "video/mp4"})
+		require.NoError(t, err)
+
+		// when
+		_, err = container.ServiceReceiver.SavePart(container.Ctx, uploadID, 2, bytes.NewReader(data[half:]), int64(len(data)-half))
+		require.NoError(t, err)
+		// interrupted part is uploaded again
+		_, err = container.ServiceReceiver.SavePart(container.Ctx, uploadID, 1, bytes.NewReader(data[half:]), int64(half))
+		require.NoError(t, err)
+		_, err = container.ServiceReceiver.SavePart(container.Ctx, uploadID, 1, bytes.NewReader(data[:half]), int64(half))
+		require.NoError(t, err)
+		parts, err := container.ServiceReceiver.ListParts(container.Ctx, uploadID)
+		require.NoError(t, err)
+		require.NoError(t, container.ServiceReceiver.CompleteUpload(container.Ctx, uploadID, nil))
+
+		// then
+		require.Len(t, parts, 2)
+		require.Equal(t, utils.HashData(data[:half]), parts[0].SHA256)
+		receivedData, err := container.ServiceReceiver.GetFile(container.Ctx, fileID)
+		require.NoError(t, err)
+		require.Equal(t, data, receivedData)
+		info, err := container.ServiceReceiver.GetFileInfo(container.Ctx, fileID)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(data)), info.Length)
+		require.Equal(t, "video/mp4", info.ContentType)
+		require.True(t, strings.HasSuffix(info.MD5, "-2"))
+		_, err = container.ServiceReceiver.ListParts(container.Ctx, uploadID)
+		require.ErrorIs(t, err, receiver.ErrUploadNotFound)
+		dataMap[fileID] = data
+	})
+
+	t.Run("failed overwrite should keep old file", func(t *testing.T) {
+		// given
+		fileID := uuid.NewString()
+		data := testhelpers.GenerateMBData(t, 1.5)
+		newData := testhelpers.GenerateMBData(t, 1.5)
+		require.NoError(t, container.ServiceReceiver.SaveFileStream(container.Ctx, fileID, bytes.NewReader(data), int64(len(data)), receiver.SaveOptions{}))
+		errBroken := errors.New("connection reset")
+
+		// when
+		// upload breaks after a few chunks are shipped
+		broken := io.MultiReader(bytes.NewReader(newData[:len(newData)/2]), iotest.ErrReader(errBroken))
+		errReplace := container.ServiceReceiver.ReplaceFileStream(container.Ctx, fileID, broken, int64(len(newData)), receiver.SaveOptions{})
+
+		// then
+		require.ErrorIs(t, errReplace, errBroken)
+		receivedData, err := container.ServiceReceiver.GetFile(container.Ctx, fileID)
+		require.NoError(t, err)
+		require.Equal(t, data, receivedData)
+
+		// when
+		require.NoError(t, container.ServiceReceiver.ReplaceFileStream(container.Ctx, fileID, bytes.NewReader(newData), int64(len(newData)), receiver.SaveOptions{}))
+
+		// then
+		receivedData, err = container.ServiceReceiver.GetFile(container.Ctx, fileID)
+		require.NoError(t, err)
+		require.Equal(t, newData, receivedData)
+		info, err := container.ServiceReceiver.GetFileInfo(container.Ctx, fileID)
+		require.NoError(t, err)
+		require.Equal(t, utils.HashData(newData), info.SHA256)
+		dataMap[fileID] = newData
+	})
+
+	t.Run("concurrent saves of the same file should conflict", func(t *testing.T) {
+		// given
+		fileID := uuid.NewString()
+		data := testhelpers.GenerateMBData(t, 0.5)
+		errs := make(chan error, 4)
+
+		// when
+		for i := 0; i < cap(errs); i++ {
+			go func() {
+				errs <- container.ServiceReceiver.SaveFileStream(container.Ctx, fileID, bytes.NewReader(data), int64(len(data)), receiver.SaveOptions{})
+			}()
+		}
+
+		// then
+		saved := 0
+		for i := 0; i < cap(errs); i++ {
+			if err := <-errs; err != nil {
+				require.ErrorIs(t, err, receiver.ErrFileAlreadyExists)
+				continue
+			}
+			saved++
+		}
+		require.Equal(t, 1, saved)
+		receivedData, err := container.ServiceReceiver.GetFile(container.Ctx, fileID)
+		require.NoError(t, err)
+		require.Equal(t, data, receivedData)
+		dataMap[fileID] = data
+	})
+
+	t.Run("unfinished file should not be served", func(t *testing.T) {
+		// given
+		fileID := uuid.NewString()
+		_, err := container.RepoFile.CreateFile(container.Ctx, &entities.File{
+			ID: fileID, Chunks: []*entities.FileChunk{}, Length: 1024,
+		})
+		require.NoError(t, err)
+
+		// when
+		_, errInfo := container.ServiceReceiver.GetFileInfo(container.Ctx, fileID)
+		_, errGet := container.ServiceReceiver.GetFile(container.Ctx, fileID)
+		_, _, errStream := container.ServiceReceiver.GetFileStream(container.Ctx, fileID)
+		_, errRange := container.ServiceReceiver.GetFileRange(container.Ctx, fileID, 10, 100)
+
+		// then
+		require.ErrorIs(t, errInfo, sql.ErrNoRows)
+		require.ErrorIs(t, errGet, sql.ErrNoRows)
+		require.ErrorIs(t, errStream, sql.ErrNoRows)
+		require.ErrorIs(t, errRange, sql.ErrNoRows)
+
+		// when
+		require.NoError(t, container.RepoFile.SetFileStatus(container.Ctx, fileID, entities.FileStatusPurge))
+		_, errInfo = container.ServiceReceiver.GetFileInfo(container.Ctx, fileID)
+
+		// then
+		require.ErrorIs(t, errInfo, sql.ErrNoRows)
+	})
+
+	t.Run("should serve file range", func(t *testing.T) {
+		for id, data := range dataMap {
+			// given
+			offset, length := int64(len(data)/3), int64(len(data)/2)
+
+			// when
+			reader, err := container.ServiceReceiver.GetFileRange(container.Ctx, id, offset, length)
+			require.NoError(t, err)
+			receivedData, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.NoError(t, reader.Close())
+
+			// then
+			require.Equal(t, data[offset:offset+length], receivedData)
+		}
+	})
+
 	for storageName, srv := range storageClusters {
-		usage, err := srv.GetUsage()
+		usage, err := srv.GetUsage(container.Ctx)
 		require.NoError(t, err)
 		storageClusterUsage[storageName] = usage
 		t.Logf("%s usage: %.2f", storageName, usage)
@@ -54,8 +555,8 @@ func TestSaveData(t *testing.T) {
 
 	t.Run("data should be rebalanced after new server added", func(t *testing.T) {
 		// given
-		srvG := addStorage(t, container, "NODE_G", 10)
-		srvH := addStorage(t, container, "NODE_H", 10)
+		srvG := addStorage(t, container, newStorage, "NODE_G", 10)
+		srvH := addStorage(t, container, newStorage, "NODE_H", 10)
 		container.ServiceOrchestrator.PrintServerPositions()
 
 		// when
@@ -75,28 +576,81 @@ func TestSaveData(t *testing.T) {
 
 		counter := 0
 		for node, usage := range storageClusterUsage {
-			newUsage, err := storageClusters[node].GetUsage()
+			newUsage, err := storageClusters[node].GetUsage(container.Ctx)
 			require.NoError(t, err)
 			if newUsage != usage {
 				counter++
 			}
 		}
 		require.True(t, counter > 0)
-		usageG, err := srvG.GetUsage()
+		usageG, err := srvG.GetUsage(container.Ctx)
 		require.NoError(t, err)
 		t.Logf("G usage: %.2f", usageG)
-		usageH, err := srvH.GetUsage()
+		usageH, err := srvH.GetUsage(container.Ctx)
 		require.NoError(t, err)
 		t.Logf("H usage: %.2f", usageH)
 	})
+
+	t.Run("data saved during rebalance should be served after it", func(t *testing.T) {
+		// given
+		require.NoError(t, container.ServiceOrchestrator.AddDataKeeper(container.Ctx, "NODE_I", newStorage("NODE_I", 10)))
+
+		// when
+		savedDuring := make(map[string][]byte)
+		for i := 0; i < 10; i++ {
+			id := uuid.NewString()
+			savedDuring[id] = testhelpers.GenerateMBData(t, 0.1)
+			require.NoError(t, container.ServiceReceiver.SaveFile(container.Ctx, id, savedDuring[id]))
+		}
+		waitRebalance(t, container.ServiceOrchestrator, "NODE_I")
+
+		// then
+		for id, data := range savedDuring {
+			receivedData, err := container.ServiceReceiver.GetFile(container.Ctx, id)
+			require.NoError(t, err)
+			require.Equal(t, data, receivedData)
+			dataMap[id] = data
+		}
+	})
+
+	t.Run("data should be served after node removed", func(t *testing.T) {
+		// when
+		require.NoError(t, container.ServiceOrchestrator.RemoveDataKeeper(container.Ctx, "NODE_A"))
+		waitRebalance(t, container.ServiceOrchestrator, "NODE_A")
+		container.ServiceOrchestrator.PrintServerPositions()
+
+		// then
+		require.ErrorIs(t, container.ServiceOrchestrator.RemoveDataKeeper(container.Ctx, "NODE_A"), orchestrator.ErrDataKeeperAbsent)
+		for id, data := range dataMap {
+			receivedData, err := container.ServiceReceiver.GetFile(container.Ctx, id)
+			require.NoError(t, err)
+			require.Equal(t, data, receivedData)
+		}
+		usage, err := storageClusters["NODE_A"].GetUsage(container.Ctx)
+		require.NoError(t, err)
+		require.Zero(t, usage)
+	})
+	return dataMap
 }
 
-func addStorage(t *testing.T, container *testhelpers.TestContainer, name string, maxLimitMB int) storager.DataKeeper {
-	srv := storager.NewService(container.Ctx, &storager.Config{
-		MaxLimitMB: maxLimitMB,
-		NodeID:     name,
-		DataDir:    t.TempDir(),
-	}, container.Logger)
-	require.NoError(t, container.ServiceOrchestrator.AddDataKeeper(name, srv))
+func addStorage(t *testing.T, container *testhelpers.TestContainer, newStorage storageFactory, name string, maxLimitMB int) storager.DataKeeper {
+	srv := newStorage(name, maxLimitMB)
+	require.NoError(t, container.ServiceOrchestrator.AddDataKeeper(container.Ctx, name, srv))
+	waitRebalance(t, container.ServiceOrchestrator, name)
 	return srv
 }
+
+// waitRebalance waits until background rebalance job of the server is finished and stale copies are dropped
+func waitRebalance(t *testing.T, router orchestrator.DataRouter, serviceID string) {
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		progress, err := router.GetRebalanceProgress(context.Background(), serviceID)
+		require.NoError(t, err)
+		require.Empty(t, progress.Error)
+		if progress.Done && progress.States[entities.SectorStateSwitched] == 0 {
+			return
+		}
+		require.True(t, time.Now().Before(deadline), "rebalance of %s is not finished", serviceID)
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -1,27 +1,52 @@
 package storager
 
-import "extendable_storage/internal/entities"
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/entities"
+	"io"
+)
 
-// DataKeeper is an interface for data storage nodes which can join the cluster and store data at any time
+var (
+	ErrUsageNotReady  = errors.New("usage calculation is in progress")
+	ErrChunkCorrupted = errors.New("chunk content doesn't match its hash")
+)
+
+// DataKeeper is an interface for data storage nodes which can join the cluster and store data at any time.
+// Calls are bound to ctx, remote node stops waiting when ctx is done.
 //
 //go:generate mockgen -source=abstract.go -destination=abstract_mock.go -package=storager
 type DataKeeper interface {
 	// GetUsage returns the amount of data stored in percentage of usage. 0 - 100
-	// this metric is used to determine the most used node in the cluster.
-	// New node candidate will be added to the cluster to balance the usage.
-	GetUsage() (float64, error)
+	// ErrUsageNotReady is returned while node calculates usage of already stored data.
+	GetUsage(ctx context.Context) (float64, error)
+	// GetCapacity returns max amount of bytes node can store, it defines weight of the node on the circle
+	GetCapacity(ctx context.Context) (uint64, error)
+	// GetSectorsUsage returns amount of bytes stored per sector, sectors without data are omitted.
+	// New node is placed on the circle so that utilization of nodes is equal after rebalance.
+	// ErrUsageNotReady is returned while node calculates usage of already stored data.
+	GetSectorsUsage(ctx context.Context) (map[uint32]uint64, error)
 
-	// GetFile returns a file by its ID and hash. ID is user defined, hash is calculated by the system
-	GetFile(chunk *entities.FileChunk) ([]byte, error)
+	// GetFile returns a file by its ID and hash. ID is user defined, hash is calculated by the system.
+	// ErrChunkCorrupted is returned if stored content doesn't match the hash.
+	GetFile(ctx context.Context, chunk *entities.FileChunk) ([]byte, error)
 	// SaveFile saves a file by its ID and hash. ID is user defined, hash is calculated by the system
-	SaveFile(chunk *entities.FileChunk, data []byte) error
+	SaveFile(ctx context.Context, chunk *entities.FileChunk, data []byte) error
 
 	// SaveFromSource command to load batch of data from external source.
-	SaveFromSource(chunksFrom, chunksTo uint32, source DataKeeper) error
-	// ServeChunksInRange command to get batch of data from external source.
-	ServeChunksInRange(chunksRange uint32) (data []byte, checkSum int32, err error)
+	SaveFromSource(ctx context.Context, chunksFrom, chunksTo uint32, source DataKeeper) error
+	// ServeChunksInRange returns zip archive of the sector as a stream, so whole sector is not held in memory.
+	// Caller must close data. checkSum is -1 and data is nil if sector has no data.
+	ServeChunksInRange(ctx context.Context, chunksRange uint32) (data io.ReadCloser, checkSum int32, err error)
 	// DropChunksInRange command to drop batch of data from external source.
-	DropChunksInRange(chunksFrom, chunksTo uint32) error
+	DropChunksInRange(ctx context.Context, chunksFrom, chunksTo uint32) error
 	// PurgeFileChunks command to purge file chunks in case of broken upload
-	PurgeFileChunks(chunks []*entities.FileChunk) error
+	PurgeFileChunks(ctx context.Context, chunks []*entities.FileChunk) error
+}
+
+// ChunkCatalog lists chunks recorded by gateway (files and parts of uploads), scrubber checks stored files against it
+// and orchestrator moves them when circle layout changes
+type ChunkCatalog interface {
+	// IterateChunks calls fn with chunks of every recorded file by batches
+	IterateChunks(ctx context.Context, fn func(chunks []*entities.FileChunk) error) error
 }
@@ -9,7 +9,9 @@
 package storager
 
 import (
+	context "context"
 	entities "extendable_storage/internal/entities"
+	io "io"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -39,103 +41,170 @@ func (m *MockDataKeeper) EXPECT() *MockDataKeeperMockRecorder {
 }
 
 // DropChunksInRange mocks base method.
-func (m *MockDataKeeper) DropChunksInRange(chunksFrom, chunksTo uint32) error {
+func (m *MockDataKeeper) DropChunksInRange(ctx context.Context, chunksFrom, chunksTo uint32) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DropChunksInRange", chunksFrom, chunksTo)
+	ret := m.ctrl.Call(m, "DropChunksInRange", ctx, chunksFrom, chunksTo)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DropChunksInRange indicates an expected call of DropChunksInRange.
-func (mr *MockDataKeeperMockRecorder) DropChunksInRange(chunksFrom, chunksTo any) *gomock.Call {
+func (mr *MockDataKeeperMockRecorder) DropChunksInRange(ctx, chunksFrom, chunksTo any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DropChunksInRange", reflect.TypeOf((*MockDataKeeper)(nil).DropChunksInRange), chunksFrom, chunksTo)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DropChunksInRange", reflect.TypeOf((*MockDataKeeper)(nil).DropChunksInRange), ctx, chunksFrom, chunksTo)
+}
+
+// GetCapacity mocks base method.
+func (m *MockDataKeeper) GetCapacity(ctx context.Context) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCapacity", ctx)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCapacity indicates an expected call of GetCapacity.
+func (mr *MockDataKeeperMockRecorder) GetCapacity(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCapacity", reflect.TypeOf((*MockDataKeeper)(nil).GetCapacity), ctx)
 }
 
 // GetFile mocks base method.
-func (m *MockDataKeeper) GetFile(chunk *entities.FileChunk) ([]byte, error) {
+func (m *MockDataKeeper) GetFile(ctx context.Context, chunk *entities.FileChunk) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetFile", chunk)
+	ret := m.ctrl.Call(m, "GetFile", ctx, chunk)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetFile indicates an expected call of GetFile.
-func (mr *MockDataKeeperMockRecorder) GetFile(chunk any) *gomock.Call {
+func (mr *MockDataKeeperMockRecorder) GetFile(ctx, chunk any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFile", reflect.TypeOf((*MockDataKeeper)(nil).GetFile), chunk)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFile", reflect.TypeOf((*MockDataKeeper)(nil).GetFile), ctx, chunk)
+}
+
+// GetSectorsUsage mocks base method.
+func (m *MockDataKeeper) GetSectorsUsage(ctx context.Context) (map[uint32]uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSectorsUsage", ctx)
+	ret0, _ := ret[0].(map[uint32]uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSectorsUsage indicates an expected call of GetSectorsUsage.
+func (mr *MockDataKeeperMockRecorder) GetSectorsUsage(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSectorsUsage", reflect.TypeOf((*MockDataKeeper)(nil).GetSectorsUsage), ctx)
 }
 
 // GetUsage mocks base method.
-func (m *MockDataKeeper) GetUsage() (float64, error) {
+func (m *MockDataKeeper) GetUsage(ctx context.Context) (float64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUsage")
+	ret := m.ctrl.Call(m, "GetUsage", ctx)
 	ret0, _ := ret[0].(float64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUsage indicates an expected call of GetUsage.
-func (mr *MockDataKeeperMockRecorder) GetUsage() *gomock.Call {
+func (mr *MockDataKeeperMockRecorder) GetUsage(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsage", reflect.TypeOf((*MockDataKeeper)(nil).GetUsage))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsage", reflect.TypeOf((*MockDataKeeper)(nil).GetUsage), ctx)
 }
 
 // PurgeFileChunks mocks base method.
-func (m *MockDataKeeper) PurgeFileChunks(chunks []*entities.FileChunk) error {
+func (m *MockDataKeeper) PurgeFileChunks(ctx context.Context, chunks []*entities.FileChunk) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PurgeFileChunks", chunks)
+	ret := m.ctrl.Call(m, "PurgeFileChunks", ctx, chunks)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // PurgeFileChunks indicates an expected call of PurgeFileChunks.
-func (mr *MockDataKeeperMockRecorder) PurgeFileChunks(chunks any) *gomock.Call {
+func (mr *MockDataKeeperMockRecorder) PurgeFileChunks(ctx, chunks any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeFileChunks", reflect.TypeOf((*MockDataKeeper)(nil).PurgeFileChunks), chunks)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeFileChunks", reflect.TypeOf((*MockDataKeeper)(nil).PurgeFileChunks), ctx, chunks)
 }
 
 // SaveFile mocks base method.
-func (m *MockDataKeeper) SaveFile(chunk *entities.FileChunk, data []byte) error {
+func (m *MockDataKeeper) SaveFile(ctx context.Context, chunk *entities.FileChunk, data []byte) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveFile", chunk, data)
+	ret := m.ctrl.Call(m, "SaveFile", ctx, chunk, data)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SaveFile indicates an expected call of SaveFile.
-func (mr *MockDataKeeperMockRecorder) SaveFile(chunk, data any) *gomock.Call {
+func (mr *MockDataKeeperMockRecorder) SaveFile(ctx, chunk, data any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFile", reflect.TypeOf((*MockDataKeeper)(nil).SaveFile), chunk, data)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFile", reflect.TypeOf((*MockDataKeeper)(nil).SaveFile), ctx, chunk, data)
 }
 
 // SaveFromSource mocks base method.
-func (m *MockDataKeeper) SaveFromSource(chunksFrom, chunksTo uint32, source DataKeeper) error {
+func (m *MockDataKeeper) SaveFromSource(ctx context.Context, chunksFrom, chunksTo uint32, source DataKeeper) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveFromSource", chunksFrom, chunksTo, source)
+	ret := m.ctrl.Call(m, "SaveFromSource", ctx, chunksFrom, chunksTo, source)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // SaveFromSource indicates an expected call of SaveFromSource.
-func (mr *MockDataKeeperMockRecorder) SaveFromSource(chunksFrom, chunksTo, source any) *gomock.Call {
+func (mr *MockDataKeeperMockRecorder) SaveFromSource(ctx, chunksFrom, chunksTo, source any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFromSource", reflect.TypeOf((*MockDataKeeper)(nil).SaveFromSource), chunksFrom, chunksTo, source)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFromSource", reflect.TypeOf((*MockDataKeeper)(nil).SaveFromSource), ctx, chunksFrom, chunksTo, source)
 }
 
 // ServeChunksInRange mocks base method.
-func (m *MockDataKeeper) ServeChunksInRange(chunksRange uint32) ([]byte, int32, error) {
+func (m *MockDataKeeper) ServeChunksInRange(ctx context.Context, chunksRange uint32) (io.ReadCloser, int32, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ServeChunksInRange", chunksRange)
-	ret0, _ := ret[0].([]byte)
+	ret := m.ctrl.Call(m, "ServeChunksInRange", ctx, chunksRange)
+	ret0, _ := ret[0].(io.ReadCloser)
 	ret1, _ := ret[1].(int32)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
 // ServeChunksInRange indicates an expected call of ServeChunksInRange.
-func (mr *MockDataKeeperMockRecorder) ServeChunksInRange(chunksRange any) *gomock.Call {
+func (mr *MockDataKeeperMockRecorder) ServeChunksInRange(ctx, chunksRange any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServeChunksInRange", reflect.TypeOf((*MockDataKeeper)(nil).ServeChunksInRange), ctx, chunksRange)
+}
+
+// MockChunkCatalog is a mock of ChunkCatalog interface.
+type MockChunkCatalog struct {
+	ctrl     *gomock.Controller
+	recorder *MockChunkCatalogMockRecorder
+}
+
+// MockChunkCatalogMockRecorder is the mock recorder for MockChunkCatalog.
+type MockChunkCatalogMockRecorder struct {
+	mock *MockChunkCatalog
+}
+
+// NewMockChunkCatalog creates a new mock instance.
+func NewMockChunkCatalog(ctrl *gomock.Controller) *MockChunkCatalog {
+	mock := &MockChunkCatalog{ctrl: ctrl}
+	mock.recorder = &MockChunkCatalogMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockChunkCatalog) EXPECT() *MockChunkCatalogMockRecorder {
+	return m.recorder
+}
+
+// IterateChunks mocks base method.
+func (m *MockChunkCatalog) IterateChunks(ctx context.Context, fn func([]*entities.FileChunk) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IterateChunks", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IterateChunks indicates an expected call of IterateChunks.
+func (mr *MockChunkCatalogMockRecorder) IterateChunks(ctx, fn any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServeChunksInRange", reflect.TypeOf((*MockDataKeeper)(nil).ServeChunksInRange), chunksRange)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IterateChunks", reflect.TypeOf((*MockChunkCatalog)(nil).IterateChunks), ctx, fn)
 }
@@ -24,6 +24,20 @@ func (s *Service) createDirIfNotExist(path string) error {
 	return err
 }
 
+// deleteDirIfEmpty removes directory if there are no files left in it
+func (s *Service) deleteDirIfEmpty(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil || len(entries) > 0 {
+		return err
+	}
+	return os.Remove(path)
+}
+
 func (s *Service) checkDirExist(path string) (bool, error) {
 	_, err := os.Stat(path)
 	if os.IsNotExist(err) {
@@ -43,18 +57,19 @@ func deleteFolderAndCalculateSize(folderPath string) (uint64, error) {
 	return size, nil
 }
 
-func (s *Service) deleteFile(filePath string) {
+// deleteFileAndCalculateSize removes file and returns its size. Missing file is not an error, 0 is returned.
+func deleteFileAndCalculateSize(filePath string) (uint64, error) {
 	fileInfo, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
 	if err != nil {
-		return
+		return 0, err
 	}
-	if err = os.Remove(filePath); err != nil {
-		s.logger.Error("error deleting file", err)
-		return
+	if err = os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return 0, err
 	}
-	s.mu.Lock()
-	s.currentUsage -= uint64(fileInfo.Size())
-	s.mu.Unlock()
+	return uint64(fileInfo.Size()), nil
 }
 
 func ZipAndCalculateCRC32(sourceFolder, zipFilePath string) (uint32, error) {
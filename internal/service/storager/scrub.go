@@ -0,0 +1,286 @@
+package storager
+
+import (
+	"context"
+	"errors"
+	"extendable_storage/internal/entities"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultScrubBytesPerSecond = 10 * bytesToMB
+)
+
+// ScrubConfig sets how often stored files are checked and how many bytes per second scrubber reads.
+// Zero BytesPerSecond means default 10MB/s.
+type ScrubConfig struct {
+	Interval       time.Duration
+	BytesPerSecond int64
+}
+
+// ChunkCatalogs iterates chunks of all catalogs one by one
+type ChunkCatalogs []ChunkCatalog
+
+func (c ChunkCatalogs) IterateChunks(ctx context.Context, fn func(chunks []*entities.FileChunk) error) error {
+	for _, catalog := range c {
+		if err := catalog.IterateChunks(ctx, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type ScrubProblem string
+
+const (
+	// ScrubCorrupted file content doesn't match chunk id recorded for its path
+	ScrubCorrupted ScrubProblem = "corrupted"
+	// ScrubOrphaned file has no chunk recorded in the files table
+	ScrubOrphaned ScrubProblem = "orphaned"
+)
+
+// ScrubIssue is a stored file which is found broken by scrubber. Chunk is nil for orphaned file.
+type ScrubIssue struct {
+	Problem ScrubProblem
+	Path    string
+	Sector  uint32
+	Chunk   *entities.FileChunk
+}
+
+// ScrubReport is a summary of scrubber pass
+type ScrubReport struct {
+	StartedAt time.Time
+	Duration  time.Duration
+	Files     int
+	Bytes     uint64
+	Corrupted int
+	Orphaned  int
+	// Skipped files are written or removed during the pass, they are checked by the next one
+	Skipped int
+}
+
+// RunScrubber checks stored files every ScrubConfig.Interval until ctx is done, see Scrub.
+// Found issues are passed to report, nil report means they are only logged.
+func (s *Service) RunScrubber(ctx context.Context, catalog ChunkCatalog, report func(issue ScrubIssue)) {
+	for {
+		if _, err := s.Scrub(ctx, catalog, report); err != nil && ctx.Err() == nil {
+			s.logger.Error("error scrub stored files", err)
+		}
+		timer := time.NewTimer(s.conf.Scrub.Interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// Scrub streams chunks recorded in catalog and recomputes SHA-256 of the file at the path of every chunk stored
+// on this node, chunks of other nodes are skipped. Then sector dirs are walked, file which was not checked has no
+// recorded chunk and is reported as orphaned. Files written during the pass are skipped. Reading is limited by
+// ScrubConfig.BytesPerSecond, so scrubber doesn't starve client requests.
+func (s *Service) Scrub(ctx context.Context, catalog ChunkCatalog, report func(issue ScrubIssue)) (ScrubReport, error) {
+	result := ScrubReport{StartedAt: time.Now()}
+	s.logger.Info("start scrub")
+	scrubber := &scrubPass{
+		service: s,
+		checked: make(map[string]struct{}),
+		report:  report,
+		result:  &result,
+		rate:    s.conf.Scrub.BytesPerSecond,
+		start:   time.Now(),
+	}
+	if scrubber.rate <= 0 {
+		scrubber.rate = defaultScrubBytesPerSecond
+	}
+	err := catalog.IterateChunks(ctx, func(chunks []*entities.FileChunk) error {
+		for _, chunk := range chunks {
+			if err := scrubber.scrubChunk(ctx, chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("error scrub recorded chunks: %w", err)
+	}
+	roots := []string{s.dataRoot}
+	if s.legacyRoot != "" {
+		roots = append(roots, s.legacyRoot)
+	}
+	for _, root := range roots {
+		if err = scrubber.findOrphaned(ctx, root); err != nil {
+			return result, err
+		}
+	}
+	result.Duration = time.Since(result.StartedAt)
+	s.mu.Lock()
+	s.lastScrub = result
+	s.mu.Unlock()
+	s.logger.Info("scrub finished",
+		slog.Int("files", result.Files),
+		slog.Uint64("bytes", result.Bytes),
+		slog.Int("corrupted", result.Corrupted),
+		slog.Int("orphaned", result.Orphaned),
+		slog.Int("skipped", result.Skipped),
+		slog.Duration("duration", result.Duration),
+	)
+	return result, nil
+}
+
+// LastScrub returns summary of the last finished scrubber pass, it is empty until the first pass is finished
+func (s *Service) LastScrub() ScrubReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastScrub
+}
+
+type scrubPass struct {
+	service *Service
+	checked map[string]struct{} // paths of files with recorded chunk, only files of this node are kept
+	report  func(issue ScrubIssue)
+	result  *ScrubReport
+	rate    int64
+	start   time.Time // start of files reading
+	read    int64     // bytes read since start, used for rate limiting
+}
+
+// scrubChunk checks file of the chunk in current and legacy layout, chunk which is not stored on this node is skipped
+func (p *scrubPass) scrubChunk(ctx context.Context, chunk *entities.FileChunk) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s := p.service
+	_, filePath := s.chunkFilePath(chunk)
+	if err := p.scrubFile(ctx, s.chunkSector(chunk), filePath, chunk); err != nil {
+		return err
+	}
+	if s.legacyRoot == "" {
+		return nil
+	}
+	_, legacyPath := s.legacyChunkFilePath(chunk)
+	return p.scrubFile(ctx, chunk.Sector(entities.CircleSectors), legacyPath, chunk)
+}
+
+func (p *scrubPass) scrubFile(ctx context.Context, sector uint32, path string, chunk *entities.FileChunk) error {
+	path = filepath.Clean(path)
+	if _, ok := p.checked[path]; ok {
+		// chunk is recorded by several files
+		return nil
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	p.checked[path] = struct{}{}
+	if p.changed(path) {
+		p.result.Skipped++
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		p.result.Skipped++
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error read file: %w", err)
+	}
+	p.result.Files++
+	p.result.Bytes += uint64(len(data))
+	if !chunk.Verify(data) {
+		// file is overwritten while it is read
+		if p.changed(path) {
+			p.result.Skipped++
+			return nil
+		}
+		p.result.Corrupted++
+		p.found(ScrubIssue{Problem: ScrubCorrupted, Path: path, Sector: sector, Chunk: chunk})
+	}
+	return p.throttle(ctx, len(data))
+}
+
+// findOrphaned walks sector dirs of root and reports files which were not checked against recorded chunk
+func (p *scrubPass) findOrphaned(ctx context.Context, root string) error {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error read data dir: %w", err)
+	}
+	for _, entry := range entries {
+		// skip zip dir and anything else which is not a sector
+		sector, errP := strconv.ParseUint(entry.Name(), 10, 32)
+		if !entry.IsDir() || errP != nil {
+			continue
+		}
+		err = filepath.WalkDir(filepath.Join(root, entry.Name()), func(path string, d fs.DirEntry, errW error) error {
+			if errW != nil {
+				// sector can be dropped during the pass
+				if errors.Is(errW, fs.ErrNotExist) {
+					return nil
+				}
+				return errW
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if errC := ctx.Err(); errC != nil {
+				return errC
+			}
+			if _, ok := p.checked[filepath.Clean(path)]; ok {
+				return nil
+			}
+			// file is written after its chunk was recorded and checked
+			if p.changed(path) {
+				p.result.Skipped++
+				return nil
+			}
+			p.result.Files++
+			p.result.Orphaned++
+			p.found(ScrubIssue{Problem: ScrubOrphaned, Path: path, Sector: uint32(sector)})
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error scrub sector %d: %w", sector, err)
+		}
+	}
+	return nil
+}
+
+// changed reports whether file is removed or modified after the pass start
+func (p *scrubPass) changed(path string) bool {
+	info, err := os.Stat(path)
+	return err != nil || !info.ModTime().Before(p.result.StartedAt)
+}
+
+func (p *scrubPass) found(issue ScrubIssue) {
+	p.service.logger.Error("scrub found broken file", fmt.Errorf("file is %s", issue.Problem),
+		slog.String("path", issue.Path), slog.Int("sector", int(issue.Sector)))
+	if p.report != nil {
+		p.report(issue)
+	}
+}
+
+// throttle pauses the pass so that bytes read since its start don't exceed the rate
+func (p *scrubPass) throttle(ctx context.Context, size int) error {
+	p.read += int64(size)
+	pause := time.Duration(float64(p.read)/float64(p.rate)*float64(time.Second)) - time.Since(p.start)
+	if pause <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(pause)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
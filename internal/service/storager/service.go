@@ -6,6 +6,7 @@ import (
 	"extendable_storage/internal/logger"
 	"extendable_storage/internal/utils"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"sync"
@@ -19,6 +20,11 @@ type Config struct {
 	MaxLimitMB int
 	NodeID     string
 	DataDir    string
+	// Sectors is number of sectors on the circle, should be the same as orchestrator uses. entities.CircleSectors if empty.
+	// Data with other number of sectors is kept in separate dir, data of default layout is read as legacy.
+	Sectors uint32
+	// Scrub sets background check of stored files, see RunScrubber
+	Scrub ScrubConfig
 }
 
 type Service struct {
@@ -27,53 +33,105 @@ type Service struct {
 	conf         *Config
 	maxBytesLen  uint64
 	currentUsage uint64
+	sectorsUsage map[uint32]uint64
+	usageReady   chan struct{}
+	sectors      uint32
+	dataRoot     string // dir with sector dirs
+	legacyRoot   string // dir with sector dirs of default layout, empty if node uses default layout
 	nodeID       string
+	lastScrub    ScrubReport
 	logger       logger.AppLogger
 }
 
 var _ DataKeeper = (*Service)(nil)
 
+// NewService creates storage node. Usage of already stored data is calculated in background,
+// GetUsage returns ErrUsageNotReady until it is finished.
 func NewService(ctx context.Context, conf *Config, log logger.AppLogger) *Service {
-	// todo add check for data dir exist for calculate usage
-	return &Service{
-		ctx:         ctx,
-		conf:        conf,
-		nodeID:      conf.NodeID,
-		logger:      log.With(slog.String("service", "storager")),
-		maxBytesLen: uint64(conf.MaxLimitMB) * bytesToMB,
+	sectors := conf.Sectors
+	if sectors == 0 {
+		sectors = entities.CircleSectors
 	}
+	nodeDir := fmt.Sprintf("%s/%s", conf.DataDir, conf.NodeID)
+	srv := &Service{
+		sectors:      sectors,
+		dataRoot:     nodeDir,
+		ctx:          ctx,
+		conf:         conf,
+		nodeID:       conf.NodeID,
+		logger:       log.With(slog.String("service", "storager"), slog.String("node_id", conf.NodeID)),
+		maxBytesLen:  uint64(conf.MaxLimitMB) * bytesToMB,
+		sectorsUsage: make(map[uint32]uint64),
+		usageReady:   make(chan struct{}),
+	}
+	if sectors != entities.CircleSectors {
+		srv.dataRoot = fmt.Sprintf("%s/sectors_%d", nodeDir, sectors)
+		srv.legacyRoot = nodeDir
+	}
+	go srv.calculateUsage()
+	return srv
 }
 
-func (s *Service) GetUsage() (float64, error) {
+func (s *Service) GetUsage(_ context.Context) (float64, error) {
+	select {
+	case <-s.usageReady:
+	default:
+		return 0, ErrUsageNotReady
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	percentage := (float64(s.currentUsage) / float64(s.maxBytesLen)) * 100
 	return percentage, nil
 }
 
-func (s *Service) GetFile(chunk *entities.FileChunk) ([]byte, error) {
-	filePath, err := s.predictFilePath(s.conf, chunk)
+// GetCapacity returns max amount of bytes node can store
+func (s *Service) GetCapacity(_ context.Context) (uint64, error) {
+	return s.maxBytesLen, nil
+}
+
+// GetFile reads chunk, chunk which is not moved from legacy layout yet is read from there.
+// Content is checked against the chunk hash, so bit rot on disk is not served silently.
+func (s *Service) GetFile(_ context.Context, chunk *entities.FileChunk) ([]byte, error) {
+	_, filePath := s.chunkFilePath(chunk)
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) && s.legacyRoot != "" {
+		_, legacyPath := s.legacyChunkFilePath(chunk)
+		data, err = os.ReadFile(legacyPath)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error create dir for file getting: %w", err)
+		return nil, err
 	}
-	return os.ReadFile(filePath)
+	if !chunk.Verify(data) {
+		return nil, fmt.Errorf("%w: %s on node %s", ErrChunkCorrupted, chunk, s.nodeID)
+	}
+	return data, nil
 }
 
-func (s *Service) SaveFile(chunk *entities.FileChunk, data []byte) error {
-	filePath, err := s.predictFilePath(s.conf, chunk)
+func (s *Service) SaveFile(_ context.Context, chunk *entities.FileChunk, data []byte) error {
+	filePath, err := s.predictFilePath(chunk)
 	if err != nil {
 		return fmt.Errorf("error create dir for file saving: %w", err)
 	}
+	// chunk can be overwritten, count only difference in size
+	prevSize := uint64(0)
+	if fileInfo, errS := os.Stat(filePath); errS == nil {
+		prevSize = uint64(fileInfo.Size())
+	}
 	if err = os.WriteFile(filePath, data, 0600); err != nil {
 		return fmt.Errorf("error save file: %w", err)
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.currentUsage += uint64(len(data))
+	sector := s.chunkSector(chunk)
+	s.subUsage(sector, prevSize)
+	s.addUsage(sector, uint64(len(data)))
+	// new copy supersedes the legacy one
+	if err = s.purgeLegacyChunk(chunk); err != nil {
+		return fmt.Errorf("error delete legacy chunk: %w", err)
+	}
 	return nil
 }
 
-func (s *Service) SaveFromSource(chunksFrom, chunksTo uint32, source DataKeeper) error {
+// SaveFromSource loads sectors from source in parallel, sector loading is stopped when ctx is done
+func (s *Service) SaveFromSource(ctx context.Context, chunksFrom, chunksTo uint32, source DataKeeper) error {
 	var (
 		wg      sync.WaitGroup
 		mu      sync.Mutex
@@ -84,7 +142,7 @@ func (s *Service) SaveFromSource(chunksFrom, chunksTo uint32, source DataKeeper)
 		wg.Add(1)
 		go func(j uint32) {
 			defer wg.Done()
-			data, checkSum, err := source.ServeChunksInRange(j)
+			data, checkSum, err := source.ServeChunksInRange(ctx, j)
 			if err != nil {
 				s.logger.Error("error get file from source", err)
 				mu.Lock()
@@ -95,7 +153,7 @@ func (s *Service) SaveFromSource(chunksFrom, chunksTo uint32, source DataKeeper)
 			if checkSum == -1 {
 				return // no data in this range
 			}
-			dataPath, zipPath, err := s.predictZipPath(s.conf, j)
+			dataPath, zipPath, err := s.predictZipPath(j)
 			if err != nil {
 				s.logger.Error("error create dir for file saving", err)
 				mu.Lock()
@@ -103,7 +161,17 @@ func (s *Service) SaveFromSource(chunksFrom, chunksTo uint32, source DataKeeper)
 				mu.Unlock()
 				return
 			}
-			if err = os.WriteFile(zipPath+"1_tmp.zip", data, 0600); err != nil {
+			sizeBefore, err := calculateFolderSize(dataPath)
+			if err != nil && !os.IsNotExist(err) {
+				s.logger.Error("error get file info", err)
+				mu.Lock()
+				errList = append(errList, fmt.Errorf("error get file info for %d: %w", j, err))
+				mu.Unlock()
+				return
+			}
+			defer data.Close()
+			defer os.Remove(zipPath + "1_tmp.zip")
+			if err = writeStream(zipPath+"1_tmp.zip", data); err != nil {
 				s.logger.Error("error save file", err)
 				mu.Lock()
 				errList = append(errList, fmt.Errorf("error save file for %d: %w", j, err))
@@ -141,9 +209,8 @@ func (s *Service) SaveFromSource(chunksFrom, chunksTo uint32, source DataKeeper)
 				mu.Unlock()
 				return
 			}
-			s.mu.Lock()
-			s.currentUsage += dataSize
-			s.mu.Unlock()
+			s.subUsage(j, sizeBefore)
+			s.addUsage(j, dataSize)
 		}(i)
 	}
 	wg.Wait()
@@ -153,9 +220,13 @@ func (s *Service) SaveFromSource(chunksFrom, chunksTo uint32, source DataKeeper)
 	return nil
 }
 
-func (s *Service) DropChunksInRange(chunksFrom, chunksTo uint32) error {
+// DropChunksInRange deletes sector dirs one by one, sectors dropped before ctx is done stay dropped
+func (s *Service) DropChunksInRange(ctx context.Context, chunksFrom, chunksTo uint32) error {
 	for i := chunksFrom; i <= chunksTo; i++ {
-		dataPath, _, err := s.predictZipPath(s.conf, i)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dataPath, _, err := s.predictZipPath(i)
 		if err != nil {
 			return fmt.Errorf("error create dir for file saving: %w", err)
 		}
@@ -171,19 +242,17 @@ func (s *Service) DropChunksInRange(chunksFrom, chunksTo uint32) error {
 		if err != nil {
 			return fmt.Errorf("error delete folder %d: %v", i, err)
 		}
-		s.mu.Lock()
-		s.currentUsage -= removedSize
-		s.mu.Unlock()
+		s.subUsage(i, removedSize)
 	}
 	return nil
 }
 
-func (s *Service) ServeChunksInRange(chunksRange uint32) (data []byte, checkSum int32, err error) {
-	dataPath, zipPath, err := s.predictZipPath(s.conf, chunksRange)
+// ServeChunksInRange zips the sector to a temporary file and streams it, the file is removed when data is closed
+func (s *Service) ServeChunksInRange(_ context.Context, chunksRange uint32) (data io.ReadCloser, checkSum int32, err error) {
+	dataPath, zipPath, err := s.predictZipPath(chunksRange)
 	if err != nil {
 		return nil, 0, fmt.Errorf("error create dir for file saving: %w", err)
 	}
-	defer s.deleteFile(zipPath)
 
 	// check folder exist
 	dataExist, err := s.checkDirExist(dataPath)
@@ -196,32 +265,108 @@ func (s *Service) ServeChunksInRange(chunksRange uint32) (data []byte, checkSum
 
 	checkSumTmp, err := ZipAndCalculateCRC32(dataPath, zipPath)
 	if err != nil {
+		_ = os.Remove(zipPath)
 		return nil, 0, fmt.Errorf("error zip file: %w", err)
 	}
-	data, err = os.ReadFile(zipPath)
+	zipFile, err := os.Open(zipPath)
 	if err != nil {
+		_ = os.Remove(zipPath)
 		return nil, 0, fmt.Errorf("error get file content: %w", err)
 	}
-	return data, int32(checkSumTmp), nil
+	return &tmpFile{File: zipFile}, int32(checkSumTmp), nil
 }
 
-func (s *Service) PurgeFileChunks(chunks []*entities.FileChunk) error {
-	panic("implement me")
+// tmpFile is removed when it is closed
+type tmpFile struct {
+	*os.File
 }
 
-func (s *Service) predictFilePath(conf *Config, chunk *entities.FileChunk) (filePath string, err error) {
-	parentDir := fmt.Sprintf("%s/%s/%d/%s", conf.DataDir, s.nodeID, chunk.Hash()%entities.CircleSectors, utils.HashString(chunk.String())[:4])
-	filePath = parentDir + "/" + utils.HashString(chunk.String())
+func (f *tmpFile) Close() error {
+	err := f.File.Close()
+	if errR := os.Remove(f.Name()); errR != nil && err == nil {
+		err = errR
+	}
+	return err
+}
+
+// writeStream copies data to the file at path
+func writeStream(path string, data io.Reader) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// PurgeFileChunks deletes chunks files. Already missing chunks are skipped, so purge can be safely repeated.
+func (s *Service) PurgeFileChunks(_ context.Context, chunks []*entities.FileChunk) error {
+	for _, chunk := range chunks {
+		parentDir, filePath := s.chunkFilePath(chunk)
+		removedSize, err := deleteFileAndCalculateSize(filePath)
+		if err != nil {
+			return fmt.Errorf("error purge chunk %s: %w", chunk.String(), err)
+		}
+		s.subUsage(s.chunkSector(chunk), removedSize)
+		if err = s.deleteDirIfEmpty(parentDir); err != nil {
+			return fmt.Errorf("error delete chunk dir: %w", err)
+		}
+		if err = s.purgeLegacyChunk(chunk); err != nil {
+			return fmt.Errorf("error purge legacy chunk %s: %w", chunk.String(), err)
+		}
+	}
+	return nil
+}
+
+// purgeLegacyChunk deletes copy of the chunk stored in legacy layout, if any
+func (s *Service) purgeLegacyChunk(chunk *entities.FileChunk) error {
+	if s.legacyRoot == "" {
+		return nil
+	}
+	parentDir, filePath := s.legacyChunkFilePath(chunk)
+	removedSize, err := deleteFileAndCalculateSize(filePath)
+	if err != nil {
+		return err
+	}
+	s.subLegacyUsage(removedSize)
+	return s.deleteDirIfEmpty(parentDir)
+}
+
+func (s *Service) predictFilePath(chunk *entities.FileChunk) (filePath string, err error) {
+	parentDir, filePath := s.chunkFilePath(chunk)
 	if err = s.createDirIfNotExist(parentDir); err != nil {
 		return "", fmt.Errorf("error create dir for file saving: %w", err)
 	}
 	return filePath, nil
 }
 
-func (s *Service) predictZipPath(conf *Config, positionID uint32) (dataPath, zipPath string, err error) {
-	zipPath = fmt.Sprintf("%s/%s/zip/%d/", conf.DataDir, s.nodeID, positionID)
+func (s *Service) chunkSector(chunk *entities.FileChunk) uint32 {
+	return chunk.Sector(s.sectors)
+}
+
+// chunkFilePath returns path of the chunk file and its hash-prefix directory
+func (s *Service) chunkFilePath(chunk *entities.FileChunk) (parentDir, filePath string) {
+	return sectorFilePath(s.dataRoot, s.chunkSector(chunk), chunk)
+}
+
+// legacyChunkFilePath returns path of the chunk file in default layout
+func (s *Service) legacyChunkFilePath(chunk *entities.FileChunk) (parentDir, filePath string) {
+	return sectorFilePath(s.legacyRoot, chunk.Sector(entities.CircleSectors), chunk)
+}
+
+func sectorFilePath(root string, sector uint32, chunk *entities.FileChunk) (parentDir, filePath string) {
+	chunkHash := utils.HashString(chunk.String())
+	parentDir = fmt.Sprintf("%s/%d/%s", root, sector, chunkHash[:4])
+	return parentDir, parentDir + "/" + chunkHash
+}
+
+func (s *Service) predictZipPath(positionID uint32) (dataPath, zipPath string, err error) {
+	zipPath = fmt.Sprintf("%s/zip/%d/", s.dataRoot, positionID)
 	if err = s.createDirIfNotExist(zipPath); err != nil {
 		return "", "", fmt.Errorf("error create dir for file saving: %w", err)
 	}
-	return fmt.Sprintf("%s/%s/%d/", conf.DataDir, s.nodeID, positionID), fmt.Sprintf("%s%d.zip", zipPath, positionID), nil
+	return fmt.Sprintf("%s/%d/", s.dataRoot, positionID), fmt.Sprintf("%s%d.zip", zipPath, positionID), nil
 }
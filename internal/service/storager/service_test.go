@@ -0,0 +1,269 @@
+package storager_test
+
+import (
+	"context"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/storager"
+	testhelpers "extendable_storage/internal/test_helpers"
+	"extendable_storage/internal/utils"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestService_PurgeFileChunks(t *testing.T) {
+	// given
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	srv := newStorage(t, dataDir)
+	chunks := saveChunks(t, srv, 3)
+	usage, err := srv.GetUsage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, float64(30), usage)
+
+	// when
+	require.NoError(t, srv.PurgeFileChunks(ctx, chunks[:2]))
+
+	// then
+	usage, err = srv.GetUsage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, float64(10), usage)
+	for _, chunk := range chunks[:2] {
+		_, err = srv.GetFile(ctx, chunk)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+	_, err = srv.GetFile(ctx, chunks[2])
+	require.NoError(t, err)
+
+	t.Run("purge should be idempotent", func(t *testing.T) {
+		require.NoError(t, srv.PurgeFileChunks(ctx, chunks))
+		usage, err = srv.GetUsage(ctx)
+		require.NoError(t, err)
+		require.Equal(t, float64(0), usage)
+		require.NoError(t, srv.PurgeFileChunks(ctx, chunks))
+	})
+
+	t.Run("empty hash prefix dirs should be removed", func(t *testing.T) {
+		prefixDirs, errG := filepath.Glob(fmt.Sprintf("%s/NODE_A/*/*", dataDir))
+		require.NoError(t, errG)
+		require.Empty(t, prefixDirs)
+	})
+}
+
+func TestService_SaveFromSource(t *testing.T) {
+	// given
+	ctx := context.Background()
+	sourceDir := t.TempDir()
+	source := newStorage(t, sourceDir)
+	chunks := saveChunks(t, source, 1)
+	sector := chunks[0].Sector(entities.CircleSectors)
+	target := newStorage(t, t.TempDir())
+
+	// when
+	require.NoError(t, target.SaveFromSource(ctx, sector, sector, source))
+
+	// then
+	_, err := target.GetFile(ctx, chunks[0])
+	require.NoError(t, err)
+	archives, err := filepath.Glob(fmt.Sprintf("%s/NODE_A/zip/*/*.zip", sourceDir))
+	require.NoError(t, err)
+	require.Empty(t, archives, "served sector archive is removed when stream is closed")
+}
+
+func TestService_CalculateUsageOnStart(t *testing.T) {
+	// given
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	srv := newStorage(t, dataDir)
+	saveChunks(t, srv, 4)
+	usage, err := srv.GetUsage(ctx)
+	require.NoError(t, err)
+
+	// when
+	restartedSrv := newStorage(t, dataDir)
+
+	// then
+	restartedUsage, err := restartedSrv.GetUsage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, usage, restartedUsage)
+	require.Equal(t, srv.SectorsUsage(), restartedSrv.SectorsUsage())
+}
+
+func TestService_GetCorruptedFile(t *testing.T) {
+	// given
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	srv := newStorage(t, dataDir)
+	chunks := saveChunks(t, srv, 1)
+	files, err := filepath.Glob(fmt.Sprintf("%s/NODE_A/*/*/*", dataDir))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	data[len(data)/2] ^= 0xff
+	require.NoError(t, os.WriteFile(files[0], data, 0600))
+
+	// when
+	_, err = srv.GetFile(ctx, chunks[0])
+
+	// then
+	require.ErrorIs(t, err, storager.ErrChunkCorrupted)
+
+	t.Run("saved chunk should replace corrupted copy", func(t *testing.T) {
+		// given
+		data[len(data)/2] ^= 0xff
+
+		// when
+		require.NoError(t, srv.SaveFile(ctx, chunks[0], data))
+
+		// then
+		receivedData, errG := srv.GetFile(ctx, chunks[0])
+		require.NoError(t, errG)
+		require.Equal(t, data, receivedData)
+		usage, errG := srv.GetUsage(ctx)
+		require.NoError(t, errG)
+		require.Equal(t, float64(10), usage)
+	})
+}
+
+func TestService_Scrub(t *testing.T) {
+	// given
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	srv := storager.NewService(ctx, &storager.Config{
+		MaxLimitMB: 10,
+		NodeID:     "NODE_A",
+		DataDir:    dataDir,
+		Scrub:      storager.ScrubConfig{BytesPerSecond: 4 * 1024 * 1024},
+	}, logger.NewAppSLogger("test"))
+	<-srv.UsageReady()
+	chunks := saveChunks(t, srv, 3)
+	corruptedPath := chunkPath(dataDir, chunks[0])
+	data, err := os.ReadFile(corruptedPath)
+	require.NoError(t, err)
+	data[0] ^= 0xff
+	require.NoError(t, os.WriteFile(corruptedPath, data, 0600))
+	catalog := storager.NewMockChunkCatalog(gomock.NewController(t))
+	catalog.EXPECT().IterateChunks(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func([]*entities.FileChunk) error) error {
+			// chunk of other node is skipped
+			other := &entities.FileChunk{FileID: "other", ChunkID: "other"}
+			for _, batch := range [][]*entities.FileChunk{chunks[:1], {other, chunks[1]}} {
+				if errF := fn(batch); errF != nil {
+					return errF
+				}
+			}
+			return nil
+		})
+
+	// when
+	var issues []storager.ScrubIssue
+	report, err := srv.Scrub(ctx, catalog, func(issue storager.ScrubIssue) {
+		issues = append(issues, issue)
+	})
+
+	// then
+	require.NoError(t, err)
+	require.ElementsMatch(t, []storager.ScrubIssue{
+		{Problem: storager.ScrubCorrupted, Path: corruptedPath, Sector: chunks[0].Sector(entities.CircleSectors), Chunk: chunks[0]},
+		{Problem: storager.ScrubOrphaned, Path: chunkPath(dataDir, chunks[2]), Sector: chunks[2].Sector(entities.CircleSectors)},
+	}, issues)
+	require.Equal(t, 3, report.Files)
+	require.Equal(t, uint64(2*1024*1024), report.Bytes)
+	require.Equal(t, 1, report.Corrupted)
+	require.Equal(t, 1, report.Orphaned)
+	require.Equal(t, report, srv.LastScrub())
+	// 2MB of recorded chunks are read with 4MB/s
+	require.GreaterOrEqual(t, report.Duration, 450*time.Millisecond)
+}
+
+func TestService_LegacyLayout(t *testing.T) {
+	// given
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	legacySrv := newStorage(t, dataDir)
+	chunks := saveChunks(t, legacySrv, 2)
+	data, err := legacySrv.GetFile(ctx, chunks[0])
+	require.NoError(t, err)
+
+	// when
+	srv := newStorageWithSectors(t, dataDir, 1<<12)
+
+	// then
+	usage, err := srv.GetUsage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, float64(20), usage)
+	require.Empty(t, srv.SectorsUsage())
+	receivedData, err := srv.GetFile(ctx, chunks[0])
+	require.NoError(t, err)
+	require.Equal(t, data, receivedData)
+
+	t.Run("saved chunk should replace legacy copy", func(t *testing.T) {
+		// when
+		require.NoError(t, srv.SaveFile(ctx, chunks[0], data))
+
+		// then
+		usage, err = srv.GetUsage(ctx)
+		require.NoError(t, err)
+		require.Equal(t, float64(20), usage)
+		require.Len(t, srv.SectorsUsage(), 1)
+		_, err = legacySrv.GetFile(ctx, chunks[0])
+		require.ErrorIs(t, err, os.ErrNotExist)
+		receivedData, err = srv.GetFile(ctx, chunks[0])
+		require.NoError(t, err)
+		require.Equal(t, data, receivedData)
+	})
+
+	t.Run("purge should remove legacy copy", func(t *testing.T) {
+		// when
+		require.NoError(t, srv.PurgeFileChunks(ctx, chunks[1:]))
+
+		// then
+		usage, err = srv.GetUsage(ctx)
+		require.NoError(t, err)
+		require.Equal(t, float64(10), usage)
+		_, err = srv.GetFile(ctx, chunks[1])
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func newStorage(t *testing.T, dataDir string) *storager.Service {
+	return newStorageWithSectors(t, dataDir, 0)
+}
+
+func newStorageWithSectors(t *testing.T, dataDir string, sectors uint32) *storager.Service {
+	srv := storager.NewService(context.Background(), &storager.Config{
+		MaxLimitMB: 10,
+		NodeID:     "NODE_A",
+		DataDir:    dataDir,
+		Sectors:    sectors,
+	}, logger.NewAppSLogger("test"))
+	<-srv.UsageReady()
+	return srv
+}
+
+func saveChunks(t *testing.T, srv *storager.Service, count int) []*entities.FileChunk {
+	chunks := make([]*entities.FileChunk, 0, count)
+	for i := 0; i < count; i++ {
+		data := testhelpers.GenerateMBData(t, 1)
+		chunk := &entities.FileChunk{
+			FileID:  uuid.NewString(),
+			ChunkID: utils.HashData(data),
+		}
+		require.NoError(t, srv.SaveFile(context.Background(), chunk, data))
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func chunkPath(dataDir string, chunk *entities.FileChunk) string {
+	hash := utils.HashString(chunk.String())
+	return fmt.Sprintf("%s/NODE_A/%d/%s/%s", dataDir, chunk.Sector(entities.CircleSectors), hash[:4], hash)
+}
@@ -0,0 +1,145 @@
+package storager
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// UsageReady is closed when usage calculation of already stored data is finished
+func (s *Service) UsageReady() <-chan struct{} {
+	return s.usageReady
+}
+
+// GetSectorsUsage returns amount of stored bytes per sector, ErrUsageNotReady until usage calculation is finished
+func (s *Service) GetSectorsUsage(_ context.Context) (map[uint32]uint64, error) {
+	select {
+	case <-s.usageReady:
+	default:
+		return nil, ErrUsageNotReady
+	}
+	return s.SectorsUsage(), nil
+}
+
+// SectorsUsage returns amount of stored bytes per sector
+func (s *Service) SectorsUsage() map[uint32]uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make(map[uint32]uint64, len(s.sectorsUsage))
+	for sector, size := range s.sectorsUsage {
+		result[sector] = size
+	}
+	return result
+}
+
+func (s *Service) addUsage(sector uint32, size uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentUsage += size
+	s.sectorsUsage[sector] += size
+}
+
+// subLegacyUsage decreases total usage by size of removed legacy data, which is not counted per sector
+func (s *Service) subLegacyUsage(size uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentUsage -= min(s.currentUsage, size)
+}
+
+// subUsage decreases usage counters. Files removed while usage calculation is in progress
+// may be not counted yet, so counters never go below zero.
+func (s *Service) subUsage(sector uint32, size uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentUsage -= min(s.currentUsage, size)
+	s.sectorsUsage[sector] -= min(s.sectorsUsage[sector], size)
+	if s.sectorsUsage[sector] == 0 {
+		delete(s.sectorsUsage, sector)
+	}
+}
+
+// calculateUsage walks over node data dir and seeds usage counters with already stored files.
+// Files written after calculation start are already counted by SaveFile, so they are skipped.
+// Data of legacy layout is counted in total usage only, it has other sectors.
+func (s *Service) calculateUsage() {
+	defer close(s.usageReady)
+	scanStart := time.Now()
+	s.logger.Info("start usage calculation")
+	files, size := s.calculateRootUsage(s.dataRoot, scanStart, s.addUsage)
+	if s.legacyRoot != "" {
+		legacyFiles, legacySize := s.calculateRootUsage(s.legacyRoot, scanStart, func(_ uint32, size uint64) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.currentUsage += size
+		})
+		files += legacyFiles
+		size += legacySize
+	}
+	s.logger.Info("usage calculated",
+		slog.Int("files", files),
+		slog.Uint64("bytes", size),
+		slog.Duration("duration", time.Since(scanStart)),
+	)
+}
+
+// calculateRootUsage counts files in sector dirs of root with add
+func (s *Service) calculateRootUsage(root string, scanStart time.Time, add func(sector uint32, size uint64)) (totalFiles int, totalSize uint64) {
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		s.logger.Info("no stored data, usage calculation skipped", slog.String("dir", root))
+		return 0, 0
+	}
+	if err != nil {
+		s.logger.Error("error read data dir, usage calculation skipped", err, slog.String("dir", root))
+		return 0, 0
+	}
+	logStep := max(1, len(entries)/10)
+	for i, entry := range entries {
+		if s.ctx.Err() != nil {
+			return totalFiles, totalSize
+		}
+		// skip zip dir and anything else which is not a sector
+		sector, errP := strconv.ParseUint(entry.Name(), 10, 32)
+		if !entry.IsDir() || errP != nil {
+			continue
+		}
+		size, files, errC := calculateFolderSizeBefore(filepath.Join(root, entry.Name()), scanStart)
+		if errC != nil {
+			s.logger.Error("error calculate sector size", errC, slog.String("sector", entry.Name()))
+			continue
+		}
+		add(uint32(sector), size)
+		totalFiles += files
+		totalSize += size
+		if (i+1)%logStep == 0 {
+			s.logger.Info("usage calculation progress",
+				slog.String("dir", root),
+				slog.Int("dirs_done", i+1),
+				slog.Int("dirs", len(entries)),
+				slog.Int("files", totalFiles),
+			)
+		}
+	}
+	return totalFiles, totalSize
+}
+
+// calculateFolderSizeBefore calculates size and count of files modified before given time
+func calculateFolderSizeBefore(folderPath string, before time.Time) (totalSize uint64, files int, err error) {
+	err = filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.ModTime().Before(before) {
+			totalSize += uint64(info.Size())
+			files++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return totalSize, files, nil
+}
@@ -0,0 +1,275 @@
+package disk
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"extendable_storage/internal/storage/cache/lru"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	bytesInMB = 1024 * 1024
+	tmpSuffix = ".tmp"
+)
+
+var (
+	ErrL2CacheIsFull = errors.New("l2 cache is full")
+)
+
+type entry struct {
+	name string
+	size int64 // size of the file on disk
+}
+
+// Cache is an L2 cache which keeps items in files of the bounded directory, least recently used files are removed.
+// Every file starts with SHA-256 of the item, so damaged file is treated as a miss.
+// Only index and recency list are changed under the lock, files are written, read and removed outside of it.
+type Cache struct {
+	dir            string
+	mu             sync.Mutex
+	index          map[string]*list.Element // file name -> element of entry in recency list
+	recency        *list.List               // front is the most recently used
+	pending        map[string]bool          // files being written or removed, true if item is removed meanwhile
+	sizeBytes      int64                    // size of indexed and pending files
+	maxSizeInBytes int64
+}
+
+// CreateL2Cache creates cache in dir. Files left in dir by previous run are kept, older ones are evicted first.
+func CreateL2Cache(dir string, maxSizeMB int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("error create cache dir: %w", err)
+	}
+	c := &Cache{
+		dir:            dir,
+		index:          make(map[string]*list.Element),
+		recency:        list.New(),
+		pending:        make(map[string]bool),
+		maxSizeInBytes: maxSizeMB * bytesInMB,
+	}
+	if err := c.restore(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cache) GetMaxSizeBytes() int64 {
+	return c.maxSizeInBytes
+}
+
+// Drain stores items evicted from L1 cache until evicted is closed or ctx is done.
+// Item which can't be stored is reported to onError and skipped, next items are stored as usual.
+func (c *Cache) Drain(ctx context.Context, evicted <-chan lru.EvictedItem, onError func(key string, err error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item, ok := <-evicted:
+			if !ok {
+				return
+			}
+			if err := c.Store(item.Key, item.Value); err != nil && !errors.Is(err, ErrL2CacheIsFull) {
+				onError(item.Key, err)
+			}
+		}
+	}
+}
+
+// Store writes item to disk, least recently used items are removed to free space.
+// Item which is already stored is only marked as recently used, item which is being stored is skipped.
+// Item bigger than cache returns ErrL2CacheIsFull.
+func (c *Cache) Store(key string, value []byte) error {
+	name := fileName(key)
+	size := int64(sha256.Size + len(value))
+	if size > c.maxSizeInBytes {
+		return ErrL2CacheIsFull
+	}
+	c.mu.Lock()
+	if element, ok := c.index[name]; ok {
+		c.recency.MoveToFront(element)
+		c.mu.Unlock()
+		return nil
+	}
+	if _, ok := c.pending[name]; ok {
+		// file is being written or removed by another call, cache may skip the item
+		c.mu.Unlock()
+		return nil
+	}
+	var evicted []string
+	for c.sizeBytes+size > c.maxSizeInBytes {
+		back := c.recency.Back()
+		if back == nil {
+			// the rest of space is reserved by concurrent writes
+			c.mu.Unlock()
+			_ = c.removeFiles(evicted)
+			return ErrL2CacheIsFull
+		}
+		evicted = append(evicted, c.dropElement(back))
+	}
+	// space is reserved while file is written
+	c.pending[name] = false
+	c.sizeBytes += size
+	c.mu.Unlock()
+
+	err := c.removeFiles(evicted)
+	if err == nil {
+		err = c.writeFile(name, value)
+	}
+	c.mu.Lock()
+	if removed := c.pending[name]; err == nil && !removed {
+		delete(c.pending, name)
+		c.index[name] = c.recency.PushFront(&entry{name: name, size: size})
+		c.mu.Unlock()
+		return nil
+	}
+	c.sizeBytes -= size
+	c.mu.Unlock()
+	// item is removed while it was written or write failed
+	if errR := c.removeFiles([]string{name}); err == nil {
+		err = errR
+	}
+	return err
+}
+
+func (c *Cache) Get(key string) (value []byte, exist bool) {
+	name := fileName(key)
+	c.mu.Lock()
+	element, ok := c.index[name]
+	if ok {
+		c.recency.MoveToFront(element)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err == nil && len(data) >= sha256.Size {
+		sum := sha256.Sum256(data[sha256.Size:])
+		if bytes.Equal(sum[:], data[:sha256.Size]) {
+			return data[sha256.Size:], true
+		}
+	}
+	// damaged file is removed unless it is already evicted or replaced meanwhile
+	c.mu.Lock()
+	if c.index[name] != element {
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.dropElement(element)
+	c.mu.Unlock()
+	_ = c.removeFiles([]string{name})
+	return nil, false
+}
+
+func (c *Cache) Remove(key string) {
+	name := fileName(key)
+	c.mu.Lock()
+	if _, ok := c.pending[name]; ok {
+		// file being written is removed when it is written
+		c.pending[name] = true
+		c.mu.Unlock()
+		return
+	}
+	element, ok := c.index[name]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	c.dropElement(element)
+	c.mu.Unlock()
+	_ = c.removeFiles([]string{name})
+}
+
+// dropElement drops entry from index and marks its file pending until removeFiles, c.mu must be held
+func (c *Cache) dropElement(element *list.Element) string {
+	item := element.Value.(*entry)
+	c.recency.Remove(element)
+	delete(c.index, item.name)
+	c.sizeBytes -= item.size
+	c.pending[item.name] = false
+	return item.name
+}
+
+// removeFiles deletes files of dropped entries, c.mu must not be held
+func (c *Cache) removeFiles(names []string) error {
+	var err error
+	for _, name := range names {
+		if errR := os.Remove(filepath.Join(c.dir, name)); errR != nil && !errors.Is(errR, os.ErrNotExist) && err == nil {
+			err = fmt.Errorf("error remove cache file: %w", errR)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range names {
+		delete(c.pending, name)
+	}
+	return err
+}
+
+// writeFile writes item after its checksum. File is renamed when it is written completely, so Get never reads
+// partial file.
+func (c *Cache) writeFile(name string, value []byte) error {
+	sum := sha256.Sum256(value)
+	path := filepath.Join(c.dir, name)
+	if err := os.WriteFile(path+tmpSuffix, append(sum[:], value...), 0o600); err != nil {
+		_ = os.Remove(path + tmpSuffix)
+		return fmt.Errorf("error write cache file: %w", err)
+	}
+	if err := os.Rename(path+tmpSuffix, path); err != nil {
+		_ = os.Remove(path + tmpSuffix)
+		return fmt.Errorf("error rename cache file: %w", err)
+	}
+	return nil
+}
+
+// restore indexes files of previous run by modification time and removes unfinished writes
+func (c *Cache) restore() error {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("error read cache dir: %w", err)
+	}
+	type stored struct {
+		entry
+		modTime int64
+	}
+	restored := make([]stored, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if filepath.Ext(file.Name()) == tmpSuffix {
+			if err = os.Remove(filepath.Join(c.dir, file.Name())); err != nil {
+				return fmt.Errorf("error remove unfinished cache file: %w", err)
+			}
+			continue
+		}
+		info, errI := file.Info()
+		if errI != nil {
+			return fmt.Errorf("error stat cache file: %w", errI)
+		}
+		restored = append(restored, stored{entry{name: file.Name(), size: info.Size()}, info.ModTime().UnixNano()})
+	}
+	sort.Slice(restored, func(i, j int) bool { return restored[i].modTime < restored[j].modTime })
+	for i := range restored {
+		c.index[restored[i].name] = c.recency.PushFront(&restored[i].entry)
+		c.sizeBytes += restored[i].size
+	}
+	var evicted []string
+	for c.sizeBytes > c.maxSizeInBytes {
+		evicted = append(evicted, c.dropElement(c.recency.Back()))
+	}
+	return c.removeFiles(evicted)
+}
+
+// fileName is a hash of the key, so any key is a valid file name
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,208 @@
+package disk_test
+
+import (
+	"context"
+	"extendable_storage/internal/storage/cache/disk"
+	"extendable_storage/internal/storage/cache/lru"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	kbBytes = 1024
+)
+
+func TestL2Cache_StoreGet(t *testing.T) {
+	// given
+	service, err := disk.CreateL2Cache(t.TempDir(), 1)
+	require.NoError(t, err)
+	data := make([]byte, 100*kbBytes)
+	for i := 0; i < 9; i++ {
+		require.NoError(t, service.Store(fmt.Sprintf("key_%d", i), data))
+	}
+
+	t.Run("evict least recently used", func(t *testing.T) {
+		// given
+		_, ok := service.Get("key_0")
+		require.True(t, ok)
+
+		// when
+		require.NoError(t, service.Store("key_abc", data))
+		require.NoError(t, service.Store("key_def", data))
+
+		// then
+		_, ok = service.Get("key_0")
+		require.True(t, ok, "recently used item is kept")
+		_, ok = service.Get("key_1")
+		require.False(t, ok, "least recently used item is evicted")
+		received, ok := service.Get("key_def")
+		require.True(t, ok)
+		require.Equal(t, data, received)
+	})
+	t.Run("remove item", func(t *testing.T) {
+		// when
+		service.Remove("key_abc")
+
+		// then
+		_, ok := service.Get("key_abc")
+		require.False(t, ok)
+	})
+	t.Run("reject item bigger than cache", func(t *testing.T) {
+		require.ErrorIs(t, service.Store("key_big", make([]byte, 1024*kbBytes)), disk.ErrL2CacheIsFull)
+	})
+}
+
+func TestL2Cache_Restore(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	service, err := disk.CreateL2Cache(dir, 1)
+	require.NoError(t, err)
+	data := make([]byte, 300*kbBytes)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, service.Store(fmt.Sprintf("key_%d", i), data))
+		// modification time orders restored items
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "unfinished.tmp"), data, 0o600))
+
+	// when
+	restored, err := disk.CreateL2Cache(dir, 1)
+	require.NoError(t, err)
+	require.NoError(t, restored.Store("key_abc", data))
+
+	// then
+	_, ok := restored.Get("key_0")
+	require.False(t, ok, "the oldest item is evicted")
+	for _, key := range []string{"key_1", "key_2", "key_abc"} {
+		received, ok := restored.Get(key)
+		require.True(t, ok, key)
+		require.Equal(t, data, received)
+	}
+	_, err = os.Stat(filepath.Join(dir, "unfinished.tmp"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestL2Cache_DamagedFile(t *testing.T) {
+	// given
+	dir := t.TempDir()
+	service, err := disk.CreateL2Cache(dir, 1)
+	require.NoError(t, err)
+	require.NoError(t, service.Store("key_0", []byte("some data")))
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// when
+	require.NoError(t, os.WriteFile(filepath.Join(dir, files[0].Name()), []byte("damaged file content of cache item"), 0o600))
+
+	// then
+	_, ok := service.Get("key_0")
+	require.False(t, ok)
+	files, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, files, "damaged file is removed")
+}
+
+func TestL2Cache_Drain(t *testing.T) {
+	// given
+	service, err := disk.CreateL2Cache(t.TempDir(), 1)
+	require.NoError(t, err)
+	l1, err := lru.CreateL1Cache(1)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	evicted := l1.EvictListener()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.Drain(ctx, evicted, func(key string, err error) {
+			t.Errorf("unexpected error for `%s`: %v", key, err)
+		})
+	}()
+	data := make([]byte, 100*kbBytes)
+
+	// when
+	for i := 0; i < 12; i++ {
+		require.NoError(t, l1.Store(fmt.Sprintf("key_%d", i), data))
+	}
+
+	// then
+	require.Eventually(t, func() bool {
+		_, ok := service.Get("key_0")
+		return ok
+	}, time.Second, 10*time.Millisecond, "item evicted from L1 is stored in L2")
+	cancel()
+	<-done
+}
+
+func TestL2Cache_DrainAfterError(t *testing.T) {
+	// given
+	dir := filepath.Join(t.TempDir(), "cache")
+	service, err := disk.CreateL2Cache(dir, 1)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evicted := make(chan lru.EvictedItem)
+	failed := make(chan string, 1)
+	go service.Drain(ctx, evicted, func(key string, _ error) {
+		failed <- key
+	})
+	require.NoError(t, os.RemoveAll(dir))
+
+	// when
+	evicted <- lru.EvictedItem{Key: "key_0", Value: []byte("some data")}
+	require.Equal(t, "key_0", <-failed)
+	require.NoError(t, os.MkdirAll(dir, 0o750))
+	evicted <- lru.EvictedItem{Key: "key_1", Value: []byte("some data")}
+
+	// then
+	require.Eventually(t, func() bool {
+		_, ok := service.Get("key_1")
+		return ok
+	}, time.Second, 10*time.Millisecond, "items are stored after failed one")
+}
+
+func TestL2Cache_Concurrent(t *testing.T) {
+	// given
+	service, err := disk.CreateL2Cache(t.TempDir(), 1)
+	require.NoError(t, err)
+	data := make([]byte, 100*kbBytes)
+	var wg sync.WaitGroup
+
+	// when
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("key_%d", (worker+i)%20)
+				if err := service.Store(key, data); err != nil {
+					assert.ErrorIs(t, err, disk.ErrL2CacheIsFull)
+				}
+				if value, ok := service.Get(key); ok {
+					assert.Equal(t, data, value)
+				}
+				if i%7 == 0 {
+					service.Remove(key)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	// then
+	stored := 0
+	for i := 0; i < 20; i++ {
+		if _, ok := service.Get(fmt.Sprintf("key_%d", i)); ok {
+			stored++
+		}
+	}
+	require.LessOrEqual(t, int64(stored*(len(data)+32)), service.GetMaxSizeBytes())
+	require.NoError(t, service.Store("key_new", data), "reserved space is released")
+}
@@ -8,6 +8,9 @@ type EvictedItem struct {
 type Cacher interface {
 	Store(key string, value []byte) error
 	Get(key string) (value []byte, exist bool)
+	// Remove drops the item, it is not sent to EvictListener
+	Remove(key string)
+	// EvictListener returns channel of evicted items, items are dropped while its buffer is full
 	EvictListener() <-chan EvictedItem
 	Purge()
 	Resize(sizeMB int64) error
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"extendable_storage/internal/storage/cache/lru/utils"
 	"fmt"
+	"sync"
 
 	lru "github.com/hashicorp/golang-lru"
 )
@@ -21,6 +22,8 @@ type Cache struct {
 	sizerL1        *utils.CacheSizer // LRU library does not track size of items, so we need to track it manually
 	evictedItems   chan EvictedItem
 	maxSizeInBytes int64
+	mu             sync.Mutex     // guards fields below and maxSizeInBytes, lruCache and sizerL1 are safe themselves
+	removing       map[string]int // keys being removed by Remove, they are not sent to EvictListener
 }
 
 func CreateL1Cache(lruMaxSizeMB int64) (*Cache, error) {
@@ -33,6 +36,7 @@ func CreateL1Cache(lruMaxSizeMB int64) (*Cache, error) {
 	l1 := &Cache{
 		sizerL1:        utils.CreateCacheSizer(maxSizeInBytes),
 		maxSizeInBytes: maxSizeInBytes,
+		removing:       make(map[string]int),
 	}
 	lruCache, err := lru.NewWithEvict(int(maxSizeInBytes), l1.onEvict)
 	if err != nil {
@@ -43,10 +47,14 @@ func CreateL1Cache(lruMaxSizeMB int64) (*Cache, error) {
 }
 
 func (s *Cache) GetMaxSizeBytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.maxSizeInBytes
 }
 
 func (s *Cache) EvictListener() <-chan EvictedItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.evictedItems == nil {
 		s.evictedItems = make(chan EvictedItem, 1000)
 	}
@@ -99,6 +107,30 @@ func (s *Cache) Get(key string) (value []byte, exist bool) {
 	return nil, false
 }
 
+// Remove drops item from L1 cache without sending it to EvictListener, as removed data is not valid anymore.
+// Only the removed key is suppressed, items evicted by concurrent Store meanwhile are sent as usual.
+func (s *Cache) Remove(key string) {
+	s.mu.Lock()
+	s.removing[key]++
+	s.mu.Unlock()
+	s.lruCache.Remove(key) // it will call onEvict
+	s.mu.Lock()
+	if s.removing[key]--; s.removing[key] == 0 {
+		delete(s.removing, key)
+	}
+	s.mu.Unlock()
+}
+
+// listener returns channel evicted key is sent to, nil if nobody listens or key is removed by Remove
+func (s *Cache) listener(key string) chan EvictedItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.removing[key] > 0 {
+		return nil
+	}
+	return s.evictedItems
+}
+
 func (s *Cache) onEvict(key, value interface{}) {
 	valueBytes, ok := value.([]byte)
 	if !ok {
@@ -106,21 +138,27 @@ func (s *Cache) onEvict(key, value interface{}) {
 		return
 	}
 	s.sizerL1.Remove(len(valueBytes))
-	if s.evictedItems != nil {
-		s.evictedItems <- EvictedItem{Key: key.(string), Value: valueBytes}
+	keyStr, _ := key.(string)
+	if evictedItems := s.listener(keyStr); evictedItems != nil {
+		// listener which falls behind loses items instead of blocking the caller
+		select {
+		case evictedItems <- EvictedItem{Key: keyStr, Value: valueBytes}:
+		default:
+		}
 	}
 }
 
 func (s *Cache) Resize(sizeMB int64) error {
 	newSizeInBytes := sizeMB * bytesInMB
-	if s.maxSizeInBytes < newSizeInBytes {
+	maxSizeInBytes := s.GetMaxSizeBytes()
+	if maxSizeInBytes < newSizeInBytes {
 		// we simply increase size of L2 cache without any eviction
-		s.maxSizeInBytes = newSizeInBytes
+		s.setMaxSizeBytes(newSizeInBytes)
 		s.sizerL1.Resize(int(newSizeInBytes))
 		return nil
 	}
 	// we need to evict some items from L2 cache
-	delta := s.maxSizeInBytes - newSizeInBytes
+	delta := maxSizeInBytes - newSizeInBytes
 	evictKeysSize := 0
 	for {
 		_, value, ok := s.lruCache.GetOldest()
@@ -140,7 +178,13 @@ func (s *Cache) Resize(sizeMB int64) error {
 			break
 		}
 	}
-	s.maxSizeInBytes = newSizeInBytes
-	s.lruCache.Resize(int(s.maxSizeInBytes))
+	s.setMaxSizeBytes(newSizeInBytes)
+	s.lruCache.Resize(int(newSizeInBytes))
 	return nil
 }
+
+func (s *Cache) setMaxSizeBytes(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxSizeInBytes = size
+}
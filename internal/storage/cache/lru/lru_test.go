@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -155,3 +156,71 @@ func TestL1Cache_Resize(t *testing.T) {
 		}
 	})
 }
+
+func TestL1Cache_Remove(t *testing.T) {
+	// given
+	service, err := lru.CreateL1Cache(1)
+	require.NoError(t, err)
+	evicted := service.EvictListener()
+	payload := make([]byte, 100*kbBytes) // 100kb data
+	storeInL1Cache(t, service, 10, payload)
+
+	// when
+	service.Remove("key_0")
+
+	// then
+	_, ok := service.Get("key_0")
+	require.False(t, ok)
+	require.Empty(t, evicted, "removed item is not evicted")
+	require.NoError(t, service.Store("key_abc", make([]byte, 100*kbBytes+1)), "removed item frees space")
+}
+
+func TestL1Cache_RemoveDuringEviction(t *testing.T) {
+	// given
+	service, err := lru.CreateL1Cache(1)
+	require.NoError(t, err)
+	evicted := service.EvictListener()
+	data := []byte("123456")
+	for round := 0; round < 20; round++ {
+		storeInL1Cache(t, service, 500, data)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 200; i++ {
+				assert.NoError(t, service.Store("removed", data))
+				service.Remove("removed")
+			}
+		}()
+
+		// when
+		service.Purge() // evicts every item while other key is removed
+
+		// then
+		<-done
+		received := make(map[string]struct{})
+		for len(evicted) > 0 {
+			item := <-evicted
+			received[item.Key] = struct{}{}
+		}
+		for i := 0; i < 500; i++ {
+			require.Contains(t, received, fmt.Sprintf("key_%d", i), "evicted item is not lost")
+		}
+	}
+}
+
+func TestL1Cache_EvictListenerIsFull(t *testing.T) {
+	// given
+	service, err := lru.CreateL1Cache(1)
+	require.NoError(t, err)
+	evicted := service.EvictListener()
+	data := []byte("123456")
+
+	// when
+	for i := 0; i < cap(evicted)+10; i++ {
+		require.NoError(t, service.Store(fmt.Sprintf("key_%d", i), data))
+	}
+	service.Purge() // evicts every item
+
+	// then
+	require.Len(t, evicted, cap(evicted), "store doesn't block when nobody reads evicted items")
+}
@@ -0,0 +1,257 @@
+package lru
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	shardsNum       = 16
+	evictedItemsBuf = 1000
+)
+
+var (
+	ErrNotAdmitted = errors.New("item is accessed less often than items it would evict")
+)
+
+type shardEntry struct {
+	key      string
+	value    []byte
+	size     int64
+	accessed uint64 // logical time of the last access, orders entries of different shards
+	// expiresAt is zero if entry never expires
+	expiresAt time.Time
+}
+
+// cacheShard is a part of the keys with its own lock and recency list, front is the most recently used
+type cacheShard struct {
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	recency *list.List
+}
+
+// ShardedCache is a concurrent cache bounded by total size of keys and values in bytes.
+// Keys are spread over shards to reduce lock contention, size limit is shared, so any item up to the limit fits.
+// When the limit is exceeded, least recently used entries of all shards are evicted until new item fits.
+type ShardedCache struct {
+	shards [shardsNum]*cacheShard
+	// clock is advanced by every Store only, so reads don't contend on it. Entries read between two stores
+	// get the same time, they are ordered exactly within a shard and approximately between shards.
+	clock          atomic.Uint64
+	sizeBytes      atomic.Int64
+	maxSizeInBytes atomic.Int64
+	ttl            time.Duration
+	evictedItems   chan EvictedItem
+	sketch         *frequencySketch // nil if every item is admitted
+}
+
+// ShardedOption configures ShardedCache
+type ShardedOption func(c *ShardedCache)
+
+// WithTinyLFU enables admission filter: when cache is full, new item is stored only if it is accessed more often
+// than the least recently used item it would evict, so one pass over many cold items doesn't flush popular ones.
+// expectedItems is a number of items frequency sketch is sized for.
+func WithTinyLFU(expectedItems int) ShardedOption {
+	return func(c *ShardedCache) {
+		c.sketch = newFrequencySketch(expectedItems)
+	}
+}
+
+// CreateShardedCache creates cache of maxSizeMB, items stored by Store expire after ttl, zero ttl means never
+func CreateShardedCache(maxSizeMB int64, ttl time.Duration, opts ...ShardedOption) *ShardedCache {
+	c := &ShardedCache{ttl: ttl, evictedItems: make(chan EvictedItem, evictedItemsBuf)}
+	c.maxSizeInBytes.Store(maxSizeMB * bytesInMB)
+	for i := range c.shards {
+		c.shards[i] = &cacheShard{items: make(map[string]*list.Element), recency: list.New()}
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *ShardedCache) GetMaxSizeBytes() int64 {
+	return c.maxSizeInBytes.Load()
+}
+
+func (c *ShardedCache) EvictListener() <-chan EvictedItem {
+	return c.evictedItems
+}
+
+// Store stores item with cache ttl, see StoreWithTTL
+func (c *ShardedCache) Store(key string, value []byte) error {
+	return c.StoreWithTTL(key, value, c.ttl)
+}
+
+// StoreWithTTL stores item which expires after ttl, zero ttl means never. Least recently used items are evicted
+// to free space, item bigger than the whole cache returns ErrL1CacheIsFull.
+// With admission filter new item which doesn't fit returns ErrNotAdmitted if it is not popular enough.
+func (c *ShardedCache) StoreWithTTL(key string, value []byte, ttl time.Duration) error {
+	size := int64(len(key) + len(value))
+	if size > c.maxSizeInBytes.Load() {
+		return ErrL1CacheIsFull
+	}
+	if c.sketch != nil {
+		c.sketch.increment(key)
+		if !c.admit(key, size) {
+			return ErrNotAdmitted
+		}
+	}
+	item := &shardEntry{key: key, value: value, size: size}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+	shard := c.shard(key)
+	shard.mu.Lock()
+	item.accessed = c.clock.Add(1)
+	if element, ok := shard.items[key]; ok {
+		c.sizeBytes.Add(size - element.Value.(*shardEntry).size)
+		element.Value = item
+		shard.recency.MoveToFront(element)
+	} else {
+		shard.items[key] = shard.recency.PushFront(item)
+		c.sizeBytes.Add(size)
+	}
+	shard.mu.Unlock()
+	c.evict()
+	return nil
+}
+
+// Get returns item and marks it recently used, expired item is removed
+func (c *ShardedCache) Get(key string) (value []byte, exist bool) {
+	if c.sketch != nil {
+		c.sketch.increment(key)
+	}
+	shard := c.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	element, ok := shard.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := element.Value.(*shardEntry)
+	if item.expired() {
+		c.removeElement(shard, element)
+		return nil, false
+	}
+	item.accessed = c.clock.Load()
+	shard.recency.MoveToFront(element)
+	return item.value, true
+}
+
+// Remove drops the item, it is not sent to EvictListener
+func (c *ShardedCache) Remove(key string) {
+	shard := c.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if element, ok := shard.items[key]; ok {
+		c.removeElement(shard, element)
+	}
+}
+
+// Purge removes all items, they are not sent to EvictListener
+func (c *ShardedCache) Purge() {
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+		for shard.recency.Len() > 0 {
+			c.removeElement(shard, shard.recency.Back())
+		}
+		shard.mu.Unlock()
+	}
+}
+
+// Resize changes size limit, least recently used items are evicted to fit the smaller one
+func (c *ShardedCache) Resize(sizeMB int64) error {
+	c.maxSizeInBytes.Store(sizeMB * bytesInMB)
+	c.evict()
+	return nil
+}
+
+// admit compares frequency of new item with the item which would be evicted first (TinyLFU).
+// Item which fits without eviction or replaces stored one is always admitted.
+func (c *ShardedCache) admit(key string, size int64) bool {
+	if c.sizeBytes.Load()+size <= c.maxSizeInBytes.Load() {
+		return true
+	}
+	shard := c.shard(key)
+	shard.mu.Lock()
+	_, stored := shard.items[key]
+	shard.mu.Unlock()
+	if stored {
+		return true
+	}
+	victim, _, victimKey := c.oldest()
+	return victim == nil || c.sketch.estimate(key) > c.sketch.estimate(victimKey)
+}
+
+// evict removes the least recently used items until cache fits its limit
+func (c *ShardedCache) evict() {
+	for c.sizeBytes.Load() > c.maxSizeInBytes.Load() {
+		victim, oldest, _ := c.oldest()
+		if victim == nil {
+			return
+		}
+		victim.mu.Lock()
+		// back could be used or evicted by concurrent call, then the search is repeated
+		if back := victim.recency.Back(); back != nil && back.Value.(*shardEntry).accessed == oldest {
+			item := back.Value.(*shardEntry)
+			c.removeElement(victim, back)
+			if !item.expired() {
+				c.notifyEvicted(item)
+			}
+		}
+		victim.mu.Unlock()
+	}
+}
+
+// oldest returns shard with the least recently used item, its access time and key. Only one shard is locked
+// at a time, so the item is found by comparing the last items of all shards.
+func (c *ShardedCache) oldest() (victim *cacheShard, accessed uint64, key string) {
+	for _, shard := range c.shards {
+		shard.mu.Lock()
+		if back := shard.recency.Back(); back != nil {
+			if item := back.Value.(*shardEntry); victim == nil || item.accessed < accessed {
+				victim, accessed, key = shard, item.accessed, item.key
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return victim, accessed, key
+}
+
+// notifyEvicted sends item to EvictListener, listener which falls behind loses items instead of blocking the caller
+func (c *ShardedCache) notifyEvicted(item *shardEntry) {
+	select {
+	case c.evictedItems <- EvictedItem{Key: item.key, Value: item.value}:
+	default:
+	}
+}
+
+// removeElement drops entry from shard, shard.mu must be held
+func (c *ShardedCache) removeElement(shard *cacheShard, element *list.Element) {
+	item := element.Value.(*shardEntry)
+	shard.recency.Remove(element)
+	delete(shard.items, item.key)
+	c.sizeBytes.Add(-item.size)
+}
+
+// shard returns shard of the key by its FNV-1a hash, calculated inline to avoid allocation
+func (c *ShardedCache) shard(key string) *cacheShard {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	hash := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= prime32
+	}
+	return c.shards[hash%shardsNum]
+}
+
+func (e *shardEntry) expired() bool {
+	return !e.expiresAt.IsZero() && time.Now().After(e.expiresAt)
+}
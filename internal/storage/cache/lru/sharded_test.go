@@ -0,0 +1,195 @@
+package lru_test
+
+import (
+	"extendable_storage/internal/storage/cache/lru"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedCache_Store(t *testing.T) {
+	t.Run("evict as many items as needed", func(t *testing.T) {
+		// given
+		service := lru.CreateShardedCache(1, 0)
+		data := make([]byte, 100*kbBytes) // 100kb data
+		for i := 0; i < 10; i++ {
+			require.NoError(t, service.Store(fmt.Sprintf("key_%d", i), data))
+		}
+		_, ok := service.Get("key_0")
+		require.True(t, ok)
+
+		// when
+		require.NoError(t, service.Store("key_abc", make([]byte, 300*kbBytes)))
+
+		// then
+		_, ok = service.Get("key_0")
+		require.True(t, ok, "recently used item is kept")
+		for i := 1; i < 4; i++ {
+			_, ok = service.Get(fmt.Sprintf("key_%d", i))
+			require.False(t, ok, "key_%d is evicted", i)
+		}
+		for i := 4; i < 10; i++ {
+			_, ok = service.Get(fmt.Sprintf("key_%d", i))
+			require.True(t, ok, "key_%d is kept", i)
+		}
+	})
+	t.Run("reject item bigger than cache", func(t *testing.T) {
+		// given
+		service := lru.CreateShardedCache(1, 0)
+
+		// when
+		err := service.Store("key_abc", make([]byte, 1024*kbBytes))
+
+		// then
+		require.ErrorIs(t, err, lru.ErrL1CacheIsFull)
+	})
+	t.Run("replace item", func(t *testing.T) {
+		// given
+		service := lru.CreateShardedCache(1, 0)
+		require.NoError(t, service.Store("key_0", make([]byte, 600*kbBytes)))
+
+		// when
+		require.NoError(t, service.Store("key_0", make([]byte, 100*kbBytes)))
+		require.NoError(t, service.Store("key_1", make([]byte, 800*kbBytes)))
+
+		// then
+		data, ok := service.Get("key_0")
+		require.True(t, ok, "replaced item size is not counted")
+		require.Len(t, data, 100*kbBytes)
+	})
+	t.Run("send evicted items to listener", func(t *testing.T) {
+		// given
+		service := lru.CreateShardedCache(1, 0)
+		evicted := service.EvictListener()
+		data := make([]byte, 400*kbBytes)
+
+		// when
+		for i := 0; i < 3; i++ {
+			require.NoError(t, service.Store(fmt.Sprintf("key_%d", i), data))
+		}
+		service.Remove("key_1")
+
+		// then
+		require.Len(t, evicted, 1)
+		require.Equal(t, "key_0", (<-evicted).Key)
+	})
+}
+
+func TestShardedCache_TTL(t *testing.T) {
+	// given
+	service := lru.CreateShardedCache(1, 50*time.Millisecond)
+	evicted := service.EvictListener()
+
+	// when
+	require.NoError(t, service.Store("key_0", []byte("123456")))
+	require.NoError(t, service.StoreWithTTL("key_1", []byte("123456"), time.Hour))
+	time.Sleep(100 * time.Millisecond)
+
+	// then
+	_, ok := service.Get("key_0")
+	require.False(t, ok, "item expires after cache ttl")
+	_, ok = service.Get("key_1")
+	require.True(t, ok, "item expires after its own ttl")
+	require.Empty(t, evicted, "expired item is not evicted")
+}
+
+func TestShardedCache_Resize(t *testing.T) {
+	// given
+	service := lru.CreateShardedCache(2, 0)
+	payload := make([]byte, 100*kbBytes) // 100kb data
+	for i := 0; i < 20; i++ {
+		require.NoError(t, service.Store(fmt.Sprintf("key%d", i), payload))
+	}
+
+	// when
+	require.NoError(t, service.Resize(1))
+
+	// then
+	require.Equal(t, int64(1024*kbBytes), service.GetMaxSizeBytes())
+	for i := 0; i < 10; i++ {
+		_, ok := service.Get(fmt.Sprintf("key%d", i))
+		require.False(t, ok, "key%d is evicted", i)
+	}
+	for i := 10; i < 20; i++ {
+		_, ok := service.Get(fmt.Sprintf("key%d", i))
+		require.True(t, ok, "key%d is kept", i)
+	}
+}
+
+func TestShardedCache_Concurrent(t *testing.T) {
+	// given
+	service := lru.CreateShardedCache(1, 0)
+	data := make([]byte, 10*kbBytes)
+	var wg sync.WaitGroup
+
+	// when
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key_%d", (w*1000+i)%300)
+				require.NoError(t, service.Store(key, data))
+				service.Get(key)
+				if i%10 == 0 {
+					service.Remove(key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// then
+	stored := 0
+	for i := 0; i < 300; i++ {
+		if _, ok := service.Get(fmt.Sprintf("key_%d", i)); ok {
+			stored++
+		}
+	}
+	require.LessOrEqual(t, stored*(len("key_000")+len(data)), 1024*kbBytes)
+	require.Greater(t, stored, 90, "cache keeps items up to its size")
+}
+
+// BenchmarkService_GetPieceFromL1_WithShardedLRU repeats BenchmarkService_GetPieceFromL1_WithLRU.
+// Sharded cache counts bytes of keys and values, so it is sized to keep all b.N items.
+func BenchmarkService_GetPieceFromL1_WithShardedLRU(b *testing.B) {
+	service := lru.CreateShardedCache(1024, 0)
+	for i := 0; i < b.N; i++ {
+		if err := service.Store(fmt.Sprintf("key%d", i), []byte("123456")); err != nil {
+			b.Fatal(err)
+		}
+	}
+	for i := 0; i < b.N; i++ {
+		data, ok := service.Get(fmt.Sprintf("key%d", i))
+		if !ok {
+			b.Fatal("not found")
+		}
+		if data == nil {
+			b.Fatal("data is nil")
+		}
+	}
+}
+
+func BenchmarkService_GetPieceFromL1_Parallel(b *testing.B) {
+	lruCache, err := lru.CreateL1Cache(1024)
+	require.NoError(b, err)
+	for name, service := range map[string]lru.Cacher{"LRU": lruCache, "ShardedLRU": lru.CreateShardedCache(1024, 0)} {
+		for i := 0; i < 1000; i++ {
+			require.NoError(b, service.Store(fmt.Sprintf("key%d", i), []byte("123456")))
+		}
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if _, ok := service.Get(fmt.Sprintf("key%d", i%1000)); !ok {
+						b.Fatal("not found")
+					}
+					i++
+				}
+			})
+		})
+	}
+}
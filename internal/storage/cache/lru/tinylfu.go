@@ -0,0 +1,87 @@
+package lru
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+const (
+	sketchDepth    = 4
+	sketchMaxCount = 15 // counters saturate like 4-bit ones, so old popularity fades quickly after reset
+	sampleFactor   = 10 // counters are halved after sampleFactor * width increments
+)
+
+// frequencySketch is a count-min sketch of key access frequency (TinyLFU). Counters are updated atomically,
+// so the sketch is shared by all shards without a lock.
+type frequencySketch struct {
+	counters  []atomic.Uint32 // sketchDepth rows of width counters
+	mask      uint64
+	additions atomic.Int64
+	sample    int64
+	resetting atomic.Bool
+}
+
+func newFrequencySketch(expectedItems int) *frequencySketch {
+	width := uint64(1) << bits.Len64(uint64(max(expectedItems, 16)-1)) // next power of two
+	return &frequencySketch{
+		counters: make([]atomic.Uint32, sketchDepth*width),
+		mask:     width - 1,
+		sample:   sampleFactor * int64(width),
+	}
+}
+
+// increment counts access to key, counters are halved once in a sample, so frequency reflects recent accesses
+func (s *frequencySketch) increment(key string) {
+	hash := keyHash64(key)
+	for i := uint64(0); i < sketchDepth; i++ {
+		counter := &s.counters[s.index(hash, i)]
+		for {
+			value := counter.Load()
+			if value >= sketchMaxCount || counter.CompareAndSwap(value, value+1) {
+				break
+			}
+		}
+	}
+	if s.additions.Add(1) >= s.sample && s.resetting.CompareAndSwap(false, true) {
+		s.reset()
+		s.resetting.Store(false)
+	}
+}
+
+// estimate returns approximate access frequency of key, the minimum of its counters
+func (s *frequencySketch) estimate(key string) uint32 {
+	hash := keyHash64(key)
+	result := uint32(sketchMaxCount)
+	for i := uint64(0); i < sketchDepth; i++ {
+		result = min(result, s.counters[s.index(hash, i)].Load())
+	}
+	return result
+}
+
+// reset halves all counters, concurrent increments during reset can be lost
+func (s *frequencySketch) reset() {
+	for i := range s.counters {
+		s.counters[i].Store(s.counters[i].Load() / 2)
+	}
+	s.additions.Store(0)
+}
+
+// index of key counter in row, rows use different hashes derived from one (double hashing)
+func (s *frequencySketch) index(hash, row uint64) uint64 {
+	h1, h2 := hash, hash>>32|hash<<32
+	return row*(s.mask+1) + (h1+row*h2)&s.mask
+}
+
+// keyHash64 is FNV-1a hash of the key
+func keyHash64(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	hash := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= prime64
+	}
+	return hash
+}
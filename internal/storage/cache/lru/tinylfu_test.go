@@ -0,0 +1,114 @@
+package lru_test
+
+import (
+	"extendable_storage/internal/storage/cache/lru"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedCache_TinyLFU_ScanResistance(t *testing.T) {
+	// given
+	data := make([]byte, 100*kbBytes) // 100kb data, cache keeps 10 items
+	popular := func(service lru.Cacher) {
+		for n := 0; n < 10; n++ {
+			for i := 0; i < 5; i++ {
+				key := fmt.Sprintf("popular_%d", i)
+				if _, ok := service.Get(key); !ok {
+					require.NoError(t, service.Store(key, data))
+				}
+			}
+		}
+	}
+	scan := func(service lru.Cacher) {
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("scan_%d", i)
+			if _, ok := service.Get(key); !ok {
+				_ = service.Store(key, data)
+			}
+		}
+	}
+	kept := func(service lru.Cacher) int {
+		result := 0
+		for i := 0; i < 5; i++ {
+			if _, ok := service.Get(fmt.Sprintf("popular_%d", i)); ok {
+				result++
+			}
+		}
+		return result
+	}
+
+	t.Run("plain LRU flushes popular items", func(t *testing.T) {
+		// given
+		service := lru.CreateShardedCache(1, 0)
+		popular(service)
+
+		// when
+		scan(service)
+
+		// then
+		require.Zero(t, kept(service))
+	})
+	t.Run("TinyLFU keeps popular items", func(t *testing.T) {
+		// given
+		service := lru.CreateShardedCache(1, 0, lru.WithTinyLFU(100))
+		popular(service)
+
+		// when
+		scan(service)
+
+		// then
+		require.Equal(t, 5, kept(service))
+		_, ok := service.Get("scan_4")
+		require.True(t, ok, "free space is used by scanned items")
+	})
+	t.Run("TinyLFU admits item which became popular", func(t *testing.T) {
+		// given
+		service := lru.CreateShardedCache(1, 0, lru.WithTinyLFU(100))
+		popular(service)
+		scan(service)
+
+		// when
+		for n := 0; n < 20; n++ {
+			if _, ok := service.Get("scan_0"); !ok {
+				_ = service.Store("scan_0", data)
+			}
+		}
+
+		// then
+		_, ok := service.Get("scan_0")
+		require.True(t, ok)
+	})
+}
+
+func TestShardedCache_TinyLFU_ZipfHitRate(t *testing.T) {
+	// given
+	const (
+		keys     = 1000
+		accesses = 100_000
+	)
+	data := make([]byte, 10*kbBytes) // cache keeps about 100 of 1000 items
+	hitRate := func(service lru.Cacher) float64 {
+		zipf := rand.NewZipf(rand.New(rand.NewSource(42)), 1.1, 1, keys-1) //nolint:gosec // reproducible workload
+		hits := 0
+		for i := 0; i < accesses; i++ {
+			key := fmt.Sprintf("key_%d", zipf.Uint64())
+			if _, ok := service.Get(key); ok {
+				hits++
+				continue
+			}
+			_ = service.Store(key, data)
+		}
+		return float64(hits) / accesses
+	}
+
+	// when
+	lruRate := hitRate(lru.CreateShardedCache(1, 0))
+	tinyLFURate := hitRate(lru.CreateShardedCache(1, 0, lru.WithTinyLFU(keys)))
+
+	// then
+	t.Logf("hit rate: LRU %.3f, TinyLFU %.3f", lruRate, tinyLFURate)
+	require.Greater(t, tinyLFURate, lruRate)
+}
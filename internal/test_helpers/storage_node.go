@@ -0,0 +1,35 @@
+package testhelpers
+
+import (
+	"context"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/storager"
+	"extendable_storage/internal/transport/keeper"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// NodeSecret is shared by test gateway and storage nodes
+const NodeSecret = "test-node-secret"
+
+// StartStorageNode runs storage node on random localhost port and returns client for it
+func StartStorageNode(t *testing.T, ctx context.Context, log logger.AppLogger, name string, maxLimitMB int) *keeper.Client {
+	srv := storager.NewService(ctx, &storager.Config{
+		MaxLimitMB: maxLimitMB,
+		NodeID:     name,
+		DataDir:    t.TempDir(),
+	}, log)
+	<-srv.UsageReady()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	nodeServer := keeper.NewServer(log, srv, ln.Addr().String(), NodeSecret)
+	go func() {
+		_ = nodeServer.Serve(ln)
+	}()
+	t.Cleanup(func() {
+		require.NoError(t, nodeServer.Stop())
+	})
+	return keeper.NewClient(ln.Addr().String(), NodeSecret)
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"extendable_storage/internal/config"
 	"extendable_storage/internal/logger"
+	"extendable_storage/internal/repository/bucket"
 	"extendable_storage/internal/repository/file"
+	"extendable_storage/internal/repository/tenant"
+	"extendable_storage/internal/repository/topology"
+	"extendable_storage/internal/repository/upload"
 	"extendable_storage/internal/service/orchestrator"
 	"extendable_storage/internal/service/receiver"
 	"extendable_storage/internal/storage/database"
+	"extendable_storage/internal/transport/keeper"
 	"fmt"
 	"os"
 	"time"
@@ -23,13 +28,24 @@ type TestContainer struct {
 	Ctx    context.Context
 	Logger logger.AppLogger
 
-	RepoFile *file.Repo
+	ReplicationConf *orchestrator.Config
+
+	RepoFile     *file.Repo
+	RepoTopology *topology.Repo
+	RepoBucket   *bucket.Repo
+	RepoUpload   *upload.Repo
+	RepoTenant   *tenant.Repo
 
 	ServiceOrchestrator orchestrator.DataRouter
 	ServiceReceiver     receiver.DataReceiver
 }
 
 func GetClean(t *testing.T) *TestContainer {
+	return GetCleanReplicated(t, nil)
+}
+
+// GetCleanReplicated prepares container with orchestrator which keeps chunks copies according to replicationConf
+func GetCleanReplicated(t *testing.T, replicationConf *orchestrator.Config) *TestContainer {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	conf := getTestConfig()
 	prepareTestDB(t, &conf.ConfigDB)
@@ -44,10 +60,15 @@ func GetClean(t *testing.T) *TestContainer {
 	appLog := logger.NewAppSLogger("test")
 	// repo init
 	repoFile := file.InitRepo(dbConnect)
+	repoTopology := topology.InitRepo(dbConnect)
+	repoBucket := bucket.InitRepo(dbConnect)
+	repoUpload := upload.InitRepo(dbConnect)
+	repoTenant := tenant.InitRepo(dbConnect)
 
 	// service init
-	serviceDataOrchestrator := orchestrator.NewService(ctx, appLog)
-	serviceDataReceiver := receiver.NewService(ctx, appLog, serviceDataOrchestrator, repoFile)
+	serviceDataOrchestrator, err := orchestrator.NewService(ctx, appLog, replicationConf, repoTopology, keeper.Connector(NodeSecret))
+	require.NoError(t, err)
+	serviceDataReceiver := receiver.NewService(ctx, appLog, serviceDataOrchestrator, repoFile, repoBucket, repoUpload)
 	t.Cleanup(func() {
 		cancel()
 		serviceDataReceiver.Stop()
@@ -56,7 +77,13 @@ func GetClean(t *testing.T) *TestContainer {
 		Ctx:    ctx,
 		Logger: appLog,
 
-		RepoFile: repoFile,
+		ReplicationConf: replicationConf,
+
+		RepoFile:     repoFile,
+		RepoTopology: repoTopology,
+		RepoBucket:   repoBucket,
+		RepoUpload:   repoUpload,
+		RepoTenant:   repoTenant,
 
 		ServiceOrchestrator: serviceDataOrchestrator,
 		ServiceReceiver:     serviceDataReceiver,
@@ -118,7 +145,7 @@ func guessMigrationDir(t *testing.T) string {
 }
 
 func cleanupDB(t *testing.T, connector database.DBConnector) {
-	tables := []string{"files"}
+	tables := []string{"files", "ring_nodes", "rebalance_jobs", "buckets", "uploads", "tenants"}
 	for _, table := range tables {
 		_, err := connector.Client().Exec(fmt.Sprintf("TRUNCATE %s CASCADE", table))
 		require.NoError(t, err)
@@ -0,0 +1,225 @@
+package keeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/service/storager"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+var (
+	ErrSourceNotRemote = errors.New("source data keeper is not remote")
+	ErrEmptyAddress    = errors.New("storage node address is empty")
+)
+
+// Client is storager.DataKeeper which calls storage node over HTTP
+type Client struct {
+	address string
+	secret  string
+	client  *http.Client
+}
+
+var _ storager.DataKeeper = (*Client)(nil)
+
+// NewClient creates client for storage node listening on address (host:port), secret is shared by all nodes
+func NewClient(address, secret string) *Client {
+	return &Client{
+		address: address,
+		secret:  secret,
+		client:  &http.Client{},
+	}
+}
+
+// Connector returns function which creates client for storage node, it matches orchestrator.KeeperConnector
+func Connector(secret string) func(serverID, address string) (storager.DataKeeper, error) {
+	return func(_, address string) (storager.DataKeeper, error) {
+		if address == "" {
+			return nil, ErrEmptyAddress
+		}
+		return NewClient(address, secret), nil
+	}
+}
+
+// Close drops idle connections to the storage node, so it can shut down without waiting for them
+func (c *Client) Close() {
+	c.client.CloseIdleConnections()
+}
+
+// Address returns address of the storage node
+func (c *Client) Address() string {
+	return c.address
+}
+
+func (c *Client) GetUsage(ctx context.Context) (float64, error) {
+	data, _, err := c.do(ctx, http.MethodGet, "/usage", nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error get usage: %w", err)
+	}
+	var resp usageResponse
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return 0, fmt.Errorf("error decode usage: %w", err)
+	}
+	return resp.Usage, nil
+}
+
+func (c *Client) GetCapacity(ctx context.Context) (uint64, error) {
+	data, _, err := c.do(ctx, http.MethodGet, "/capacity", nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error get capacity: %w", err)
+	}
+	var resp capacityResponse
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return 0, fmt.Errorf("error decode capacity: %w", err)
+	}
+	return resp.Capacity, nil
+}
+
+func (c *Client) GetSectorsUsage(ctx context.Context) (map[uint32]uint64, error) {
+	data, _, err := c.do(ctx, http.MethodGet, "/usage/sectors", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error get sectors usage: %w", err)
+	}
+	var resp sectorsUsageResponse
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("error decode sectors usage: %w", err)
+	}
+	return resp.Sectors, nil
+}
+
+func (c *Client) GetFile(ctx context.Context, chunk *entities.FileChunk) ([]byte, error) {
+	data, _, err := c.do(ctx, http.MethodGet, "/chunks", chunkQuery(chunk), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error get file: %w", err)
+	}
+	return data, nil
+}
+
+func (c *Client) SaveFile(ctx context.Context, chunk *entities.FileChunk, data []byte) error {
+	if _, _, err := c.do(ctx, http.MethodPut, "/chunks", chunkQuery(chunk), data); err != nil {
+		return fmt.Errorf("error save file: %w", err)
+	}
+	return nil
+}
+
+// SaveFromSource asks storage node to load sectors from source node directly, so data is not proxied through caller.
+// Source must be remote data keeper as well.
+func (c *Client) SaveFromSource(ctx context.Context, chunksFrom, chunksTo uint32, source storager.DataKeeper) error {
+	remoteSource, ok := source.(*Client)
+	if !ok {
+		return ErrSourceNotRemote
+	}
+	payload, err := json.Marshal(saveFromSourceRequest{
+		From:   chunksFrom,
+		To:     chunksTo,
+		Source: remoteSource.Address(),
+	})
+	if err != nil {
+		return fmt.Errorf("error encode request: %w", err)
+	}
+	if _, _, err = c.do(ctx, http.MethodPost, "/sectors/load", nil, payload); err != nil {
+		return fmt.Errorf("error save from source: %w", err)
+	}
+	return nil
+}
+
+// ServeChunksInRange returns body of the response as data, sector archive is read while caller consumes it
+func (c *Client) ServeChunksInRange(ctx context.Context, chunksRange uint32) (data io.ReadCloser, checkSum int32, err error) {
+	resp, err := c.send(ctx, http.MethodGet, "/sectors/"+strconv.FormatUint(uint64(chunksRange), 10), nil, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error serve chunks in range: %w", err)
+	}
+	checkSumHeader := resp.Header.Get(headerChecksum)
+	if checkSumHeader == "" {
+		_ = resp.Body.Close()
+		return nil, -1, nil // no data in this range
+	}
+	checkSumTmp, err := strconv.ParseInt(checkSumHeader, 10, 32)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, 0, fmt.Errorf("error parse checksum: %w", err)
+	}
+	return resp.Body, int32(checkSumTmp), nil
+}
+
+func (c *Client) DropChunksInRange(ctx context.Context, chunksFrom, chunksTo uint32) error {
+	query := url.Values{}
+	query.Set(paramFrom, strconv.FormatUint(uint64(chunksFrom), 10))
+	query.Set(paramTo, strconv.FormatUint(uint64(chunksTo), 10))
+	if _, _, err := c.do(ctx, http.MethodDelete, "/sectors", query, nil); err != nil {
+		return fmt.Errorf("error drop chunks in range: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) PurgeFileChunks(ctx context.Context, chunks []*entities.FileChunk) error {
+	payload, err := json.Marshal(purgeRequest{Chunks: chunks})
+	if err != nil {
+		return fmt.Errorf("error encode request: %w", err)
+	}
+	if _, _, err = c.do(ctx, http.MethodPost, "/chunks/purge", nil, payload); err != nil {
+		return fmt.Errorf("error purge file chunks: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte) ([]byte, http.Header, error) {
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return data, resp.Header, nil
+}
+
+// send calls storage node and returns successful response with unread body, caller must close it
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	target := url.URL{Scheme: "http", Host: c.address, Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set(headerSecret, c.secret)
+	if body != nil && method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to call node %s: %w", c.address, err)
+	}
+	if resp.StatusCode < http.StatusMultipleChoices {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", os.ErrNotExist, data)
+	case http.StatusServiceUnavailable:
+		return nil, fmt.Errorf("%w: %s", storager.ErrUsageNotReady, data)
+	case http.StatusUnprocessableEntity:
+		return nil, fmt.Errorf("%w: %s", storager.ErrChunkCorrupted, data)
+	}
+	return nil, fmt.Errorf("node %s responded with %d: %s", c.address, resp.StatusCode, data)
+}
+
+func chunkQuery(chunk *entities.FileChunk) url.Values {
+	query := url.Values{}
+	query.Set(paramFileID, chunk.FileID)
+	query.Set(paramChunkID, chunk.ChunkID)
+	return query
+}
@@ -0,0 +1,36 @@
+package keeper
+
+import "extendable_storage/internal/entities"
+
+const (
+	headerChecksum = "X-Checksum"
+	// headerSecret carries secret shared by gateway and storage nodes, node rejects calls without it
+	headerSecret = "X-Node-Secret"
+
+	paramFileID  = "file_id"
+	paramChunkID = "chunk_id"
+	paramFrom    = "from"
+	paramTo      = "to"
+)
+
+type usageResponse struct {
+	Usage float64 `json:"usage"`
+}
+
+type capacityResponse struct {
+	Capacity uint64 `json:"capacity"`
+}
+
+type sectorsUsageResponse struct {
+	Sectors map[uint32]uint64 `json:"sectors"`
+}
+
+type saveFromSourceRequest struct {
+	From   uint32 `json:"from"`
+	To     uint32 `json:"to"`
+	Source string `json:"source"`
+}
+
+type purgeRequest struct {
+	Chunks []*entities.FileChunk `json:"chunks"`
+}
@@ -0,0 +1,98 @@
+package keeper_test
+
+import (
+	"context"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/logger"
+	testhelpers "extendable_storage/internal/test_helpers"
+	"extendable_storage/internal/transport/keeper"
+	"extendable_storage/internal/utils"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_DataKeeper(t *testing.T) {
+	// given
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	appLog := logger.NewAppSLogger("test")
+	nodeA := testhelpers.StartStorageNode(t, ctx, appLog, "NODE_A", 10)
+	nodeB := testhelpers.StartStorageNode(t, ctx, appLog, "NODE_B", 10)
+	data := testhelpers.GenerateMBData(t, 1)
+	chunk := &entities.FileChunk{
+		FileID:  uuid.NewString(),
+		ChunkID: utils.HashData(data),
+	}
+
+	// when
+	require.NoError(t, nodeA.SaveFile(ctx, chunk, data))
+
+	// then
+	receivedData, err := nodeA.GetFile(ctx, chunk)
+	require.NoError(t, err)
+	require.Equal(t, data, receivedData)
+	usage, err := nodeA.GetUsage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, float64(10), usage)
+	capacity, err := nodeA.GetCapacity(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10*1024*1024), capacity)
+	sectors, err := nodeA.GetSectorsUsage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[uint32]uint64{chunk.Sector(entities.CircleSectors): uint64(len(data))}, sectors)
+
+	t.Run("should return not exist error for unknown chunk", func(t *testing.T) {
+		_, err = nodeB.GetFile(ctx, chunk)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("should serve empty sector", func(t *testing.T) {
+		_, checkSum, errS := nodeB.ServeChunksInRange(ctx, 0)
+		require.NoError(t, errS)
+		require.Equal(t, int32(-1), checkSum)
+	})
+
+	t.Run("should reject calls without node secret", func(t *testing.T) {
+		// given
+		stranger := keeper.NewClient(nodeA.Address(), "wrong secret")
+
+		// when
+		errD := stranger.DropChunksInRange(ctx, 0, entities.CircleSectors-1)
+		_, errG := stranger.GetFile(ctx, chunk)
+
+		// then
+		require.ErrorContains(t, errD, "401")
+		require.ErrorContains(t, errG, "401")
+		receivedData, err = nodeA.GetFile(ctx, chunk)
+		require.NoError(t, err)
+		require.Equal(t, data, receivedData)
+	})
+
+	t.Run("should move sectors between nodes", func(t *testing.T) {
+		// when
+		require.NoError(t, nodeB.SaveFromSource(ctx, 0, entities.CircleSectors-1, nodeA))
+		require.NoError(t, nodeA.DropChunksInRange(ctx, 0, entities.CircleSectors-1))
+
+		// then
+		receivedData, err = nodeB.GetFile(ctx, chunk)
+		require.NoError(t, err)
+		require.Equal(t, data, receivedData)
+		_, err = nodeA.GetFile(ctx, chunk)
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("should purge chunks", func(t *testing.T) {
+		// when
+		require.NoError(t, nodeB.PurgeFileChunks(ctx, []*entities.FileChunk{chunk}))
+
+		// then
+		_, err = nodeB.GetFile(ctx, chunk)
+		require.ErrorIs(t, err, os.ErrNotExist)
+		usage, err = nodeB.GetUsage(ctx)
+		require.NoError(t, err)
+		require.Equal(t, float64(0), usage)
+	})
+}
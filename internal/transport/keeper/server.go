@@ -0,0 +1,197 @@
+package keeper
+
+import (
+	"crypto/subtle"
+	"errors"
+	"extendable_storage/internal/entities"
+	"extendable_storage/internal/logger"
+	"extendable_storage/internal/service/storager"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+const (
+	bodyLimit = entities.MaxChunkSize + 1024*1024 // chunk and small json requests, sector archives are only served
+)
+
+// Server exposes storager.DataKeeper over HTTP, so storage node can run as separate process
+type Server struct {
+	appAddr    string
+	secret     string
+	log        logger.AppLogger
+	storage    storager.DataKeeper
+	httpEngine *fiber.App
+}
+
+// NewServer initializes the storage node HTTP Server. Only calls with secret in X-Node-Secret header are served,
+// the same secret is used to load sectors from other nodes.
+func NewServer(log logger.AppLogger, storage storager.DataKeeper, address, secret string) *Server {
+	srv := &Server{
+		appAddr:    address,
+		secret:     secret,
+		httpEngine: fiber.New(fiber.Config{BodyLimit: bodyLimit}),
+		storage:    storage,
+		log:        log.With(slog.String("service", "storage_node_http")),
+	}
+	srv.httpEngine.Use(recover.New(), srv.authorize)
+	srv.initRoutes()
+	return srv
+}
+
+func (s *Server) initRoutes() {
+	s.httpEngine.Get("/usage", s.getUsage)
+	s.httpEngine.Get("/usage/sectors", s.getSectorsUsage)
+	s.httpEngine.Get("/capacity", s.getCapacity)
+	s.httpEngine.Get("/chunks", s.getFile)
+	s.httpEngine.Put("/chunks", s.saveFile)
+	s.httpEngine.Post("/chunks/purge", s.purgeFileChunks)
+	s.httpEngine.Get("/sectors/:sector", s.serveChunksInRange)
+	s.httpEngine.Post("/sectors/load", s.saveFromSource)
+	s.httpEngine.Delete("/sectors", s.dropChunksInRange)
+}
+
+// authorize rejects calls without node secret, so only gateway and other nodes can read, write or drop data
+func (s *Server) authorize(ctx *fiber.Ctx) error {
+	if subtle.ConstantTimeCompare([]byte(ctx.Get(headerSecret)), []byte(s.secret)) != 1 {
+		return ctx.Status(fiber.StatusUnauthorized).SendString("invalid node secret")
+	}
+	return ctx.Next()
+}
+
+// Run starts the HTTP Server.
+func (s *Server) Run() error {
+	s.log.Info("Starting storage node HTTP server", slog.String("port", s.appAddr))
+	return s.httpEngine.Listen(s.appAddr)
+}
+
+// Serve starts the HTTP Server on given listener.
+func (s *Server) Serve(ln net.Listener) error {
+	s.log.Info("Starting storage node HTTP server", slog.String("port", ln.Addr().String()))
+	return s.httpEngine.Listener(ln)
+}
+
+func (s *Server) Stop() error {
+	return s.httpEngine.Shutdown()
+}
+
+func (s *Server) getUsage(ctx *fiber.Ctx) error {
+	usage, err := s.storage.GetUsage(ctx.UserContext())
+	if err != nil {
+		return s.handleError(ctx, err)
+	}
+	return ctx.JSON(usageResponse{Usage: usage})
+}
+
+func (s *Server) getSectorsUsage(ctx *fiber.Ctx) error {
+	sectors, err := s.storage.GetSectorsUsage(ctx.UserContext())
+	if err != nil {
+		return s.handleError(ctx, err)
+	}
+	return ctx.JSON(sectorsUsageResponse{Sectors: sectors})
+}
+
+func (s *Server) getCapacity(ctx *fiber.Ctx) error {
+	capacity, err := s.storage.GetCapacity(ctx.UserContext())
+	if err != nil {
+		return s.handleError(ctx, err)
+	}
+	return ctx.JSON(capacityResponse{Capacity: capacity})
+}
+
+func (s *Server) getFile(ctx *fiber.Ctx) error {
+	data, err := s.storage.GetFile(ctx.UserContext(), chunkFromQuery(ctx))
+	if err != nil {
+		return s.handleError(ctx, err)
+	}
+	return ctx.Send(data)
+}
+
+func (s *Server) saveFile(ctx *fiber.Ctx) error {
+	if err := s.storage.SaveFile(ctx.UserContext(), chunkFromQuery(ctx), ctx.Body()); err != nil {
+		return s.handleError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (s *Server) purgeFileChunks(ctx *fiber.Ctx) error {
+	var req purgeRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if err := s.storage.PurgeFileChunks(ctx.UserContext(), req.Chunks); err != nil {
+		return s.handleError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (s *Server) serveChunksInRange(ctx *fiber.Ctx) error {
+	sector, err := strconv.ParseUint(ctx.Params("sector"), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	data, checkSum, err := s.storage.ServeChunksInRange(ctx.UserContext(), uint32(sector))
+	if err != nil {
+		return s.handleError(ctx, err)
+	}
+	if checkSum == -1 {
+		// no data in this sector
+		return ctx.SendStatus(fiber.StatusNoContent)
+	}
+	ctx.Set(headerChecksum, strconv.FormatInt(int64(checkSum), 10))
+	// archive is streamed from disk, data is closed when it is sent
+	return ctx.SendStream(data)
+}
+
+func (s *Server) saveFromSource(ctx *fiber.Ctx) error {
+	var req saveFromSourceRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	source := NewClient(req.Source, s.secret)
+	defer source.Close()
+	if err := s.storage.SaveFromSource(ctx.UserContext(), req.From, req.To, source); err != nil {
+		return s.handleError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (s *Server) dropChunksInRange(ctx *fiber.Ctx) error {
+	from, err := strconv.ParseUint(ctx.Query(paramFrom), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	to, err := strconv.ParseUint(ctx.Query(paramTo), 10, 32)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+	if err = s.storage.DropChunksInRange(ctx.UserContext(), uint32(from), uint32(to)); err != nil {
+		return s.handleError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (s *Server) handleError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return ctx.Status(fiber.StatusNotFound).SendString(err.Error())
+	case errors.Is(err, storager.ErrUsageNotReady):
+		return ctx.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+	case errors.Is(err, storager.ErrChunkCorrupted):
+		s.log.Error("corrupted chunk is requested", err, slog.String("path", ctx.Path()))
+		return ctx.Status(fiber.StatusUnprocessableEntity).SendString(err.Error())
+	}
+	s.log.Error("error process storage node request", err, slog.String("path", ctx.Path()))
+	return ctx.Status(fiber.StatusInternalServerError).SendString(err.Error())
+}
+
+func chunkFromQuery(ctx *fiber.Ctx) *entities.FileChunk {
+	return &entities.FileChunk{
+		FileID:  ctx.Query(paramFileID),
+		ChunkID: ctx.Query(paramChunkID),
+	}
+}
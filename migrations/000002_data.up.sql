@@ -0,0 +1,7 @@
+CREATE TABLE ring_nodes (
+   position BIGINT PRIMARY KEY,
+   server_id VARCHAR(255) NOT NULL,
+   state VARCHAR(255) NOT NULL,
+   address VARCHAR(255) NOT NULL DEFAULT '',
+   updated_at TIMESTAMPTZ
+);
@@ -0,0 +1,2 @@
+ALTER TABLE files DROP COLUMN IF EXISTS parity_shards;
+ALTER TABLE files DROP COLUMN IF EXISTS data_shards;
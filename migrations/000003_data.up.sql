@@ -0,0 +1,3 @@
+-- erasure coding scheme of the file, zero for plain files
+ALTER TABLE files ADD COLUMN data_shards INT NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN parity_shards INT NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE ring_nodes DROP COLUMN IF EXISTS sectors;
@@ -0,0 +1,2 @@
+-- number of sectors on the circle, positions of different circles are not comparable
+ALTER TABLE ring_nodes ADD COLUMN sectors BIGINT NOT NULL DEFAULT 360;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS rebalance_sectors;
+DROP TABLE IF EXISTS rebalance_jobs;
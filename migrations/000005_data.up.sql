@@ -0,0 +1,20 @@
+-- last rebalance job of every node, unfinished jobs are resumed on start
+CREATE TABLE rebalance_jobs (
+   server_id VARCHAR(255) PRIMARY KEY,
+   kind VARCHAR(255) NOT NULL,
+   address VARCHAR(255) NOT NULL DEFAULT '',
+   done BOOLEAN NOT NULL DEFAULT FALSE,
+   created_at TIMESTAMPTZ,
+   updated_at TIMESTAMPTZ
+);
+
+CREATE TABLE rebalance_sectors (
+   server_id VARCHAR(255) NOT NULL REFERENCES rebalance_jobs(server_id) ON DELETE CASCADE,
+   sector BIGINT NOT NULL,
+   source_id VARCHAR(255) NOT NULL,
+   targets JSONB NOT NULL,
+   drops JSONB NOT NULL,
+   state VARCHAR(255) NOT NULL,
+   updated_at TIMESTAMPTZ,
+   PRIMARY KEY (server_id, sector)
+);
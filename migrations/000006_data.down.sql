@@ -0,0 +1,4 @@
+ALTER TABLE files DROP COLUMN IF EXISTS md5;
+ALTER TABLE files DROP COLUMN IF EXISTS sha256;
+ALTER TABLE files DROP COLUMN IF EXISTS content_type;
+ALTER TABLE files DROP COLUMN IF EXISTS size;
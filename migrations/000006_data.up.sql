@@ -0,0 +1,5 @@
+-- size, content type and digests of the whole file, sha256 is served as ETag
+ALTER TABLE files ADD COLUMN size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE files ADD COLUMN content_type VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN sha256 VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE files ADD COLUMN md5 VARCHAR(32) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- buckets of S3 API, objects are files with id <bucket>/<key>
+CREATE TABLE buckets (
+   name VARCHAR(63) PRIMARY KEY,
+   created_at TIMESTAMPTZ
+);
@@ -0,0 +1,3 @@
+ALTER TABLE files ALTER COLUMN md5 TYPE VARCHAR(32) USING CASE WHEN length(md5) > 32 THEN '' ELSE md5 END;
+DROP TABLE IF EXISTS upload_parts;
+DROP TABLE IF EXISTS uploads;
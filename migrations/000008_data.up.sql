@@ -0,0 +1,31 @@
+-- multipart uploads, every part is chunked and placed as a small file of its own until upload is completed
+CREATE TABLE uploads (
+   id VARCHAR(36) PRIMARY KEY,
+   file_id VARCHAR(255) NOT NULL,
+   status VARCHAR(255) NOT NULL,
+   content_type VARCHAR(255) NOT NULL DEFAULT '',
+   created_at TIMESTAMPTZ,
+   updated_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_uploads_status ON uploads(status, updated_at);
+
+-- part can be uploaded again, previous copy of the part is purged when the new one is completed
+CREATE TABLE upload_parts (
+   id VARCHAR(36) PRIMARY KEY,
+   upload_id VARCHAR(36) NOT NULL REFERENCES uploads(id),
+   part_number INT NOT NULL,
+   status VARCHAR(255) NOT NULL,
+   size BIGINT NOT NULL DEFAULT 0,
+   sha256 VARCHAR(64) NOT NULL DEFAULT '',
+   md5 VARCHAR(32) NOT NULL DEFAULT '',
+   chunks JSONB,
+   created_at TIMESTAMPTZ,
+   updated_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_upload_parts_upload ON upload_parts(upload_id, part_number);
+CREATE INDEX idx_upload_parts_status ON upload_parts(status, updated_at);
+
+-- md5 of multipart file is S3 style "<md5 of part digests>-<parts>"
+ALTER TABLE files ALTER COLUMN md5 TYPE VARCHAR(64);
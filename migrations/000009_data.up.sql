@@ -0,0 +1,16 @@
+-- tenants own files with ids "<namespace>:<name>", keys authenticate requests of the tenant
+CREATE TABLE tenants (
+   namespace VARCHAR(63) PRIMARY KEY,
+   created_at TIMESTAMPTZ
+);
+
+CREATE TABLE api_keys (
+   id VARCHAR(32) PRIMARY KEY,
+   namespace VARCHAR(63) NOT NULL REFERENCES tenants(namespace),
+   secret_hash VARCHAR(64) NOT NULL,
+   scope VARCHAR(16) NOT NULL,
+   created_at TIMESTAMPTZ,
+   revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_namespace ON api_keys(namespace);